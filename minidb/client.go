@@ -140,7 +140,7 @@ func printRecord(record *storage.RecordBatch) {
 
 func printMsg(msg protocol.Msg) {
 	switch msg.TP {
-	case protocol.OkMsgType, protocol.ErrMsgType:
+	case protocol.OkMsgType, protocol.ErrMsgType, protocol.MoreResultsMsgType:
 		println("server: ", msg.Msg.(protocol.ErrMsg).Msg)
 	default:
 		panic("cannot print such message")
@@ -158,6 +158,13 @@ func handleResp(packetCounter byte, conn net.Conn) error {
 		if msg.TP == protocol.DataMsgType && msg.Msg.(*storage.RecordBatch) == nil {
 			break
 		}
+		if msg.TP == protocol.MoreResultsMsgType {
+			// The query has more statements, print the results of the finished one.
+			printRecord(records)
+			printMsg(msg)
+			records = nil
+			continue
+		}
 		if msg.TP != protocol.DataMsgType {
 			break
 		}
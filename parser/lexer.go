@@ -132,9 +132,13 @@ const (
 	// Second DML
 	// Insert statement is like:
 	// * insert into tb_name [( col_name... )] values (expression...)
+	// [on duplicate key update assignments...]
+	// * replace into tb_name [( col_name... )] values (expression...)
 	INSERT
 	INTO
 	VALUES
+	DUPLICATE
+	REPLACE
 
 	// Update statement is like:
 	// * update table_reference set assignments... WhereStm OrderByStm LimitStm
@@ -180,6 +184,11 @@ const (
 	IN
 	SHARE
 	MODE
+	// Set operations are like:
+	// * select ... {union | intersect | except} [all | distinct] select ...
+	UNION
+	INTERSECT
+	EXCEPT
 
 	// use database
 	USE
@@ -193,6 +202,8 @@ const (
 	// It can be either a numerical value such as an integer or a float (start with a number or a .), true or false.
 	// Or a value with 'xxx', or "xxx", which can interpreted to other type according to the format of the column.
 	VALUE
+	// A ? placeholder of prepared statements.
+	PARAM
 
 	// There are some special tokens like operations used in expressions
 	// condition expression.
@@ -203,6 +214,40 @@ const (
 	LESSEQUAL  // <=
 	AND
 	OR
+	BETWEEN
+	ESCAPE
+	// Case expression is like:
+	// * case [value] when expr then expr [when expr then expr...] [else expr] end
+	CASE
+	WHEN
+	THEN
+	ELSE
+	END
+	// Window function is like:
+	// * func(...) over ([partition by expr...] [order by ...] [{rows | range} frame])
+	OVER
+	PARTITION
+	ROWS
+	RANGE
+	UNBOUNDED
+	PRECEDING
+	FOLLOWING
+	CURRENT
+	ROW
+	// Common table expression is like:
+	// * with [recursive] name [(col_name...)] as (select ...) [, ...] select ...
+	WITH
+	RECURSIVE
+	// Join types besides left, right and inner:
+	// * cross join, natural [left|right] [outer] join, full [outer] join
+	CROSS
+	NATURAL
+	FULL
+	// Operations made of two tokens, they are not returned by lexer.
+	ISNOT      // is not
+	NOTIN      // not in
+	NOTBETWEEN // not between
+	NOTLIKE    // not like
 
 	// math expression
 	PLUS   // +
@@ -215,11 +260,42 @@ const (
 	SEMICOLON
 	COMMA
 
+	// An optimizer hint comment like /*+ hint */, it's kept in Lexer.Hints instead of the tokens
+	// parsed by parser.
+	HINT
+
 	// Transaction related commands
 	BEGIN
 	ROLLBACK
 	COMMIT
 
+	// Kill statement is like:
+	// * kill query connection_id;
+	// Set statement is like:
+	// * set variable_name = value;
+	KILL
+	QUERY
+
+	// Prepared statements are like:
+	// * prepare stm_name from 'statement';
+	// * execute stm_name [using expr [, expr...]];
+	// * {deallocate | drop} prepare stm_name;
+	PREPARE
+	EXECUTE
+	DEALLOCATE
+
+	// Explain statement is like:
+	// * explain [analyze] [format = {traditional | json}] select_statement;
+	EXPLAIN
+	FORMAT
+	ANALYZE
+
+	// Analyze table statement is like:
+	// * analyze table tb_name[,tb_name...];
+	// And the statistics can be shown by:
+	// * show stats tb_name;
+	STATS
+
 	// Character set and collate
 	DEFAULT
 	UTF8
@@ -238,8 +314,10 @@ type Token struct {
 
 type Lexer struct {
 	Tokens []Token
-	Data   []byte
-	pos    int
+	// The hint comments, the position of a hint is its content without /*+ and */.
+	Hints []Token
+	Data  []byte
+	pos   int
 }
 
 func NewLexer() *Lexer {
@@ -317,9 +395,12 @@ var (
 		"ADD":              ADD,
 		"COLUMN":           COLUMN,
 		"MODIFY":           MODIFY,
+		"CHANGE":           CHANGE,
 		"INSERT":           INSERT,
 		"INTO":             INTO,
 		"VALUES":           VALUES,
+		"DUPLICATE":        DUPLICATE,
+		"REPLACE":          REPLACE,
 		"IS":               IS,
 		"WHERE":            WHERE,
 		"AS":               AS,
@@ -340,6 +421,9 @@ var (
 		"ALL":              ALL,
 		"DISTINCT":         DISTINCT,
 		"DISTINCTROW":      DISTINCTROW,
+		"UNION":            UNION,
+		"INTERSECT":        INTERSECT,
+		"EXCEPT":           EXCEPT,
 		"GROUP":            GROUP,
 		"HAVING":           HAVING,
 		"FOR":              FOR,
@@ -350,6 +434,27 @@ var (
 		"VALUE":            VALUE,
 		"AND":              AND,
 		"OR":               OR,
+		"BETWEEN":          BETWEEN,
+		"ESCAPE":           ESCAPE,
+		"CASE":             CASE,
+		"WHEN":             WHEN,
+		"THEN":             THEN,
+		"ELSE":             ELSE,
+		"END":              END,
+		"OVER":             OVER,
+		"PARTITION":        PARTITION,
+		"ROWS":             ROWS,
+		"RANGE":            RANGE,
+		"UNBOUNDED":        UNBOUNDED,
+		"PRECEDING":        PRECEDING,
+		"FOLLOWING":        FOLLOWING,
+		"CURRENT":          CURRENT,
+		"ROW":              ROW,
+		"WITH":             WITH,
+		"RECURSIVE":        RECURSIVE,
+		"CROSS":            CROSS,
+		"NATURAL":          NATURAL,
+		"FULL":             FULL,
 		"USE":              USE,
 		"SHOW":             SHOW,
 		"TABLES":           TABLES,
@@ -359,6 +464,15 @@ var (
 		"BEGIN":            BEGIN,
 		"ROLLBACK":         ROLLBACK,
 		"COMMIT":           COMMIT,
+		"KILL":             KILL,
+		"QUERY":            QUERY,
+		"PREPARE":          PREPARE,
+		"EXECUTE":          EXECUTE,
+		"DEALLOCATE":       DEALLOCATE,
+		"EXPLAIN":          EXPLAIN,
+		"FORMAT":           FORMAT,
+		"ANALYZE":          ANALYZE,
+		"STATS":            STATS,
 		"UTF8":             UTF8,
 		"UTF16":            UTF16,
 		"UTF32":            UTF32,
@@ -389,11 +503,25 @@ func (l *Lexer) Lex(data []byte) ([]Token, error) {
 func (l *Lexer) read() (t []Token, err error) {
 	for l.pos < len(l.Data) {
 		switch l.Data[l.pos] {
-		case '!', '=', '>', '<', '+', '-', '*', '/', '%', '(', ')', ',', ';':
+		case '#':
+			l.readLineComment()
+		case '-':
+			if l.isLineComment() {
+				l.readLineComment()
+			} else {
+				err = l.readSpecialCharacters()
+			}
+		case '/':
+			if l.matchToken('*') {
+				err = l.readBlockComment()
+			} else {
+				err = l.readSpecialCharacters()
+			}
+		case '!', '=', '>', '<', '+', '*', '%', '(', ')', ',', ';', '?':
 			err = l.readSpecialCharacters()
 		case '`':
 			err = l.readIdent()
-		case '\t', ' ', '\n':
+		case '\t', ' ', '\n', '\r', '\v', '\f':
 			l.readContinuousSpace()
 		case '"', '\'':
 			err = l.readValue()
@@ -470,6 +598,9 @@ func (l *Lexer) readSpecialCharacters() error {
 	case ',':
 		l.pos++
 		l.Tokens = append(l.Tokens, Token{Tp: COMMA, StartPos: l.pos - 1, EndPos: l.pos})
+	case '?':
+		l.pos++
+		l.Tokens = append(l.Tokens, Token{Tp: PARAM, StartPos: l.pos - 1, EndPos: l.pos})
 	default:
 		return l.MakeLexerError(1, l.pos)
 	}
@@ -492,16 +623,41 @@ func (l *Lexer) hasRemain() bool {
 
 // readContinuousSpace
 func (l *Lexer) readContinuousSpace() {
-	for ; l.pos < len(l.Data); l.pos++ {
-		if l.Data[l.pos] == ' ' || l.Data[l.pos] == '\t' ||
-			l.Data[l.pos] == '\n' {
-			continue
-		} else {
-			break
-		}
+	for l.pos < len(l.Data) && isSpace(l.Data[l.pos]) {
+		l.pos++
 	}
 }
 
+// Like mysql, -- starts a comment only when followed by a space or the end, so 1--1 is 1 - -1.
+func (l *Lexer) isLineComment() bool {
+	if !l.matchToken('-') {
+		return false
+	}
+	return l.pos+2 >= len(l.Data) || isSpace(l.Data[l.pos+2])
+}
+
+// readLineComment skips a comment starting with # or -- until the end of line.
+func (l *Lexer) readLineComment() {
+	for l.pos < len(l.Data) && l.Data[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+// readBlockComment skips a comment like /* comment */, and keeps a hint comment like /*+ hint */
+// in the hints.
+func (l *Lexer) readBlockComment() error {
+	startPos := l.pos
+	end := bytes.Index(l.Data[startPos+2:], []byte("*/"))
+	if end < 0 {
+		return l.MakeLexerError(1, startPos)
+	}
+	l.pos = startPos + 2 + end + 2
+	if startPos+2 < len(l.Data) && l.Data[startPos+2] == '+' {
+		l.Hints = append(l.Hints, Token{Tp: HINT, StartPos: startPos + 3, EndPos: l.pos - 2})
+	}
+	return nil
+}
+
 var wordPattern = regexp.MustCompile("^[_A-Za-z]+[_A-Za-z0-9]*")
 
 func (l *Lexer) matchDot() bool {
@@ -248,3 +248,35 @@ func generateDropSql() string {
 	//Todo
 	return ""
 }
+
+func TestComments(t *testing.T) {
+	lexer := NewLexer()
+	tokens, err := lexer.Lex([]byte("-- a comment\r\nselect # another comment\r\n id /* a block\r\n comment */ from t;\r\n--"))
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenType{SELECT, WORD, FROM, WORD, SEMICOLON}, tokenTypes(tokens))
+	// -- without a space after it is minus.
+	lexer = NewLexer()
+	tokens, err = lexer.Lex([]byte("select 1--1, 2 -- 1\n/2;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenType{SELECT, VALUE, MINUS, MINUS, VALUE, COMMA, VALUE, DIVIDE, VALUE, SEMICOLON}, tokenTypes(tokens))
+	// Hints are kept.
+	lexer = NewLexer()
+	data := []byte("select /*+ hash_join(t1, t2) */ * from t1, t2 /*+*/;")
+	tokens, err = lexer.Lex(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenType{SELECT, MUL, FROM, WORD, COMMA, WORD, SEMICOLON}, tokenTypes(tokens))
+	assert.Len(t, lexer.Hints, 2)
+	assert.Equal(t, HINT, lexer.Hints[0].Tp)
+	assert.Equal(t, " hash_join(t1, t2) ", string(data[lexer.Hints[0].StartPos:lexer.Hints[0].EndPos]))
+	assert.Equal(t, "", string(data[lexer.Hints[1].StartPos:lexer.Hints[1].EndPos]))
+
+	testOneSqlButErr(t, "select * from t /* not closed;")
+	testOneSqlButErr(t, "select * from t /*/;")
+}
+
+func tokenTypes(tokens []Token) (ret []TokenType) {
+	for _, token := range tokens {
+		ret = append(ret, token.Tp)
+	}
+	return
+}
@@ -12,7 +12,10 @@ var parserLog = util.GetLog("Parser")
 type Parser struct {
 	pos    int
 	Tokens []Token
+	Hints  []Token // The optimizer hint comments, they are not used for now.
 	Data   []byte
+	// The number of ? placeholders, a statement having placeholders can only be prepared.
+	ParamCount int
 }
 
 func NewParser() *Parser {
@@ -37,18 +40,46 @@ func (parser *Parser) MakeSyntaxError(startPos int) error {
 }
 
 func (parser *Parser) Parse(data []byte) (stm Stm, err error) {
-	lex := NewLexer()
-	tokens, err := lex.Lex(data)
+	err = parser.Set(data)
 	if err != nil {
 		return nil, err
 	}
-	parser.Tokens = tokens
-	parser.Data = data
-	parser.pos = 0
-	token, ok := parser.NextToken()
-	if !ok {
+	if len(parser.Tokens) == 0 {
+		return nil, errors.New("syntax err: please input query")
+	}
+	stm, err = parser.parseStm()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.Tokens) {
+		return nil, parser.MakeSyntaxError(parser.pos)
+	}
+	return
+}
+
+// ParseScript parses a script of statements separated by semicolons, it fails if any of them
+// has syntax errors.
+func (parser *Parser) ParseScript(data []byte) (stms []Stm, err error) {
+	err = parser.Set(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(parser.Tokens) == 0 {
 		return nil, errors.New("syntax err: please input query")
 	}
+	for parser.pos < len(parser.Tokens) {
+		stm, err := parser.parseStm()
+		if err != nil {
+			return nil, err
+		}
+		stms = append(stms, stm)
+	}
+	return
+}
+
+// parseStm parses the statement starting from the current token.
+func (parser *Parser) parseStm() (stm Stm, err error) {
+	token, _ := parser.NextToken()
 	switch token.Tp {
 	case CREATE:
 		parser.UnReadToken()
@@ -60,13 +91,12 @@ func (parser *Parser) Parse(data []byte) (stm Stm, err error) {
 		parser.UnReadToken()
 		stm, err = parser.resolveRenameStm()
 	case ALTER:
-		return nil, errors.New("syntax err: Alter is not supported now")
-		// parser.UnReadToken()
-		// stm, err = parser.resolveAlterStm()
+		parser.UnReadToken()
+		stm, err = parser.resolveAlterStm()
 	case TRUNCATE:
 		parser.UnReadToken()
 		stm, err = parser.resolveTruncate()
-	case INSERT:
+	case INSERT, REPLACE:
 		parser.UnReadToken()
 		stm, err = parser.resolveInsertStm()
 	case DELETE:
@@ -75,7 +105,7 @@ func (parser *Parser) Parse(data []byte) (stm Stm, err error) {
 	case UPDATE:
 		parser.UnReadToken()
 		stm, err = parser.resolveUpdateStm()
-	case SELECT:
+	case SELECT, WITH:
 		parser.UnReadToken()
 		stm, err = parser.resolveSelectStm(true)
 	case USE:
@@ -84,6 +114,27 @@ func (parser *Parser) Parse(data []byte) (stm Stm, err error) {
 	case SHOW:
 		parser.UnReadToken()
 		stm, err = parser.resolveShowStm()
+	case EXPLAIN:
+		parser.UnReadToken()
+		stm, err = parser.resolveExplainStm()
+	case ANALYZE:
+		parser.UnReadToken()
+		stm, err = parser.resolveAnalyzeTableStm()
+	case KILL:
+		parser.UnReadToken()
+		stm, err = parser.resolveKillStm()
+	case PREPARE:
+		parser.UnReadToken()
+		stm, err = parser.resolvePrepareStm()
+	case EXECUTE:
+		parser.UnReadToken()
+		stm, err = parser.resolveExecuteStm()
+	case DEALLOCATE:
+		parser.UnReadToken()
+		stm, err = parser.resolveDeallocatePrepareStm()
+	case SET:
+		parser.UnReadToken()
+		stm, err = parser.resolveSetStm()
 	case BEGIN, COMMIT, ROLLBACK:
 		parser.UnReadToken()
 		stm, err = parser.ParseTransStm()
@@ -93,9 +144,6 @@ func (parser *Parser) Parse(data []byte) (stm Stm, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if parser.pos < len(parser.Tokens) {
-		return nil, parser.MakeSyntaxError(parser.pos)
-	}
 	return
 }
 
@@ -106,8 +154,15 @@ func (parser *Parser) Set(data []byte) error {
 		return err
 	}
 	parser.Tokens = tokens
+	parser.Hints = lexer.Hints
 	parser.Data = data
 	parser.pos = 0
+	parser.ParamCount = 0
+	for _, token := range tokens {
+		if token.Tp == PARAM {
+			parser.ParamCount++
+		}
+	}
 	return nil
 }
 
@@ -249,7 +304,7 @@ func (parser *Parser) parseValue(ifNotRollback bool) ([]byte, bool) {
 }
 
 func (parser *Parser) parseCharacterSet() (CharacterSetTP, error) {
-	if !parser.matchTokenTypes(true, CHARACTER, SET, EQUAL) {
+	if !parser.matchTokenTypes(true, CHARACTER, SET, EQUAL) && !parser.matchTokenTypes(true, DEFAULT, CHARACTER, SET, EQUAL) {
 		return DEFAULTCHARACTERSETTP, nil
 	}
 	token, ok := parser.NextToken()
@@ -264,7 +319,7 @@ func (parser *Parser) parseCharacterSet() (CharacterSetTP, error) {
 }
 
 func (parser *Parser) parseCollate() (CollateTP, error) {
-	if !parser.matchTokenTypes(true, COLLATE, EQUAL) {
+	if !parser.matchTokenTypes(true, COLLATE, EQUAL) && !parser.matchTokenTypes(true, DEFAULT, COLLATE, EQUAL) {
 		return DEFAULTCOLLATETP, nil
 	}
 	token, ok := parser.NextToken()
@@ -43,10 +43,14 @@ func (parser *Parser) resolveAlterStm() (stm Stm, err error) {
 	case ENGINE:
 		stm, err = parser.parseAlterEngineStm(string(tableName))
 	case DEFAULT, CHARACTER, COLLATE:
+		parser.UnReadToken()
 		stm, err = parser.parseAlterCharsetCollateStm(string(tableName))
 	default:
 		stm, err = parser.parseAlterColumnOrIndexConstraintStm(t.Tp, string(tableName))
 	}
+	if err != nil {
+		return nil, err
+	}
 	if !parser.matchTokenTypes(false, SEMICOLON) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
@@ -61,9 +65,12 @@ func (parser *Parser) parseAlterDatabaseStm() (Stm, error) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
 	charset, collate, err := parser.parseCharsetAndCollate()
-	if !ok {
+	if err != nil {
 		return nil, err
 	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
 	return &AlterDatabaseStm{DatabaseName: string(dbName), Charset: charset, Collate: collate}, nil
 }
 
@@ -142,7 +149,7 @@ func (parser *Parser) parseAlterTableAddConstraintStm(tableName string) (*AlterT
 }
 
 // drop {index|key} index_name |
-func (parser *Parser) parseAlterTableDropForeignKeyStm(tableName string) (*AlterTableDropIndexOrConstraintStm, error) {
+func (parser *Parser) parseAlterTableDropIndexStm(tableName string) (*AlterTableDropIndexOrConstraintStm, error) {
 	indexName, ok := parser.parseIdentOrWord(false)
 	if !ok {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
@@ -159,7 +166,7 @@ func (parser *Parser) parseAlterTableDropPrimaryKeyStm(tableName string) (*Alter
 }
 
 // drop foreign key key_name
-func (parser *Parser) parseAlterTableDropIndexStm(tableName string) (*AlterTableDropIndexOrConstraintStm, error) {
+func (parser *Parser) parseAlterTableDropForeignKeyStm(tableName string) (*AlterTableDropIndexOrConstraintStm, error) {
 	if !parser.matchTokenTypes(false, KEY) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
@@ -217,9 +224,13 @@ func (parser *Parser) parseAlterColumnStm(alterTp TokenType, tableName string) (
 	}, nil
 }
 
-// engine=value
+// engine [=] value
 func (parser *Parser) parseAlterEngineStm(tableName string) (*AlterTableAlterEngineStm, error) {
-	engine, ok := parser.parseIdentOrWord(false)
+	parser.matchTokenTypes(true, EQUAL)
+	engine, ok := parser.parseIdentOrWord(true)
+	if !ok {
+		engine, ok = parser.parseValue(false)
+	}
 	if !ok {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
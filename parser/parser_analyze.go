@@ -0,0 +1,24 @@
+package parser
+
+// Analyze table statement is like:
+// * analyze table tb_name[,tb_name...];
+func (parser *Parser) resolveAnalyzeTableStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, ANALYZE, TABLE) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	var tableNames []string
+	for {
+		name, ret := parser.parseIdentOrWord(false)
+		if !ret {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		tableNames = append(tableNames, string(name))
+		if !parser.matchTokenTypes(true, COMMA) {
+			break
+		}
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &AnalyzeTableStm{TableNames: tableNames}, nil
+}
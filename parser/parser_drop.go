@@ -5,6 +5,8 @@ package parser
 // * drop {database | schema} [if exists] db_name;
 // Drop table statement is like:
 // * drop table [if exists] tb_name[,tb_name...] [RESTRICT|CASCADE];
+// Drop prepare statement is like:
+// * drop prepare stm_name;
 
 // parseDropStm parses a drop statement and return it.
 func (parser *Parser) parseDropStm() (stm Stm, err error) {
@@ -20,6 +22,8 @@ func (parser *Parser) parseDropStm() (stm Stm, err error) {
 		stm, err = parser.parseDropTableStm()
 	case DATABASE, SCHEMA:
 		stm, err = parser.parseDropDatabaseStm()
+	case PREPARE:
+		stm, err = parser.parsePreparedStmName()
 	default:
 		err = parser.MakeSyntaxError(parser.pos - 1)
 	}
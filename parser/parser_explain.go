@@ -0,0 +1,32 @@
+package parser
+
+import "strings"
+
+// explain [analyze] [format = {traditional | json}] select_statement;
+func (parser *Parser) resolveExplainStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, EXPLAIN) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	stm := &ExplainStm{Format: ExplainTraditionalFormat}
+	stm.Analyze = parser.matchTokenTypes(true, ANALYZE)
+	if parser.matchTokenTypes(true, FORMAT, EQUAL) {
+		format, ok := parser.parseIdentOrWord(false)
+		if !ok {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		switch strings.ToUpper(string(format)) {
+		case "TRADITIONAL":
+			stm.Format = ExplainTraditionalFormat
+		case "JSON":
+			stm.Format = ExplainJSONFormat
+		default:
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+	}
+	selectStm, err := parser.resolveSelectStm(true)
+	if err != nil {
+		return nil, err
+	}
+	stm.Select = selectStm.(*SelectStm)
+	return stm, nil
+}
@@ -4,14 +4,22 @@ package parser
 // are supported. An expression statement is like:
 // term (ope term)
 // a term can be:
-// * literal | (expr) | identifier | functionCall |
+// * literal | NULL | (expr) | identifier | functionCall | NOT expr | caseExpr | (select ...) | [NOT] EXISTS (select ...)
 // where functionCall is like:
-// funcName(expr,...)
+// funcName([distinct] expr,...)
+// and caseExpr is like:
+// case [value] when expr then expr [when expr then expr...] [else expr] end
 // where ope supports:
 // +, -, *, /, %, =, IS, !=, IS NOT, >, >=, <, <=, AND, OR,
-// Note: currently we don't consider [NOT] IN, [NOT] LIKE
+// [NOT] IN (expr, ...), [NOT] IN (select ...), [NOT] BETWEEN expr AND expr, [NOT] LIKE expr [ESCAPE expr]
 // Note: literal can be -5
 func (parser *Parser) resolveExpression() (expr *ExpressionStm, err error) {
+	return parser.resolveExpressionWithPriority(0)
+}
+
+// resolveExpressionWithPriority parses an expression whose operations have a priority not less
+// than minPriority, it stops before the first operation having a lower priority.
+func (parser *Parser) resolveExpressionWithPriority(minPriority int) (expr *ExpressionStm, err error) {
 	exprTerm, err := parser.parseExpressionTerm()
 	if err != nil {
 		return nil, err
@@ -20,21 +28,123 @@ func (parser *Parser) resolveExpression() (expr *ExpressionStm, err error) {
 	exprs = append(exprs, exprTerm)
 	var ops []*ExpressionOp
 	for {
-		token, ok := parser.NextToken()
-		if !ok || !isTokenAOpe(token) {
-			parser.UnReadToken()
+		pos := parser.pos
+		op, ok := parser.parseExpressionOp()
+		if !ok || op.Priority < minPriority {
+			parser.pos = pos
 			break
 		}
-		rightExprTerm, err := parser.parseExpressionTerm()
+		rightExprTerm, err := parser.parseRightExpressionTerm(op)
 		if err != nil {
 			return nil, err
 		}
-		ops = append(ops, parser.LexerOpToExpressionOp(token.Tp))
+		if rightExprTerm.Tp == ListExpressionTermTP {
+			// A list cannot be the left operand of another operation, like a in (1, 2) + 1.
+			pos = parser.pos
+			next, ok := parser.parseExpressionOp()
+			parser.pos = pos
+			if ok && next.Priority > op.Priority {
+				return nil, parser.MakeSyntaxError(pos)
+			}
+		}
+		ops = append(ops, op)
 		exprs = append(exprs, rightExprTerm)
 	}
 	return parser.buildExpressionsTree(ops, exprs), nil
 }
 
+// parseExpressionOp parses an operation, which can be made of two tokens like not in.
+func (parser *Parser) parseExpressionOp() (*ExpressionOp, bool) {
+	token, ok := parser.NextToken()
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case token.Tp == IS && parser.matchTokenTypes(true, NOT):
+		return OperationIsNot, true
+	case token.Tp == NOT:
+		next, ok := parser.NextToken()
+		if !ok {
+			return nil, false
+		}
+		switch next.Tp {
+		case IN:
+			return OperationNotIn, true
+		case BETWEEN:
+			return OperationNotBetween, true
+		case LIKE:
+			return OperationNotLike, true
+		}
+		return nil, false
+	case isTokenAOpe(token):
+		return parser.LexerOpToExpressionOp(token.Tp), true
+	}
+	return nil, false
+}
+
+// parseRightExpressionTerm parses the right operand of op, which is an ExpressionListStm for in,
+// between and like.
+func (parser *Parser) parseRightExpressionTerm(op *ExpressionOp) (*ExpressionTerm, error) {
+	var list ExpressionListStm
+	switch op.Tp {
+	case IN, NOTIN:
+		if !parser.matchTokenTypes(false, LEFTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		if parser.isSelectStart() {
+			// Must be in (select ...)
+			return parser.parseSubQueryExpressionTerm()
+		}
+		for {
+			value, err := parser.resolveExpression()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
+		}
+		if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+	case BETWEEN, NOTBETWEEN:
+		// The and here is not a logic operation, so the bounds can only have math operations.
+		low, err := parser.resolveExpressionWithPriority(OperationAdd.Priority)
+		if err != nil {
+			return nil, err
+		}
+		if !parser.matchTokenTypes(false, AND) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		high, err := parser.resolveExpressionWithPriority(OperationAdd.Priority)
+		if err != nil {
+			return nil, err
+		}
+		list = ExpressionListStm{low, high}
+	case LIKE, NOTLIKE:
+		pattern, err := parser.resolveExpressionWithPriority(OperationAdd.Priority)
+		if err != nil {
+			return nil, err
+		}
+		list = ExpressionListStm{pattern}
+		if parser.matchTokenTypes(true, ESCAPE) {
+			escape, err := parser.parseExpressionTerm()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, &ExpressionStm{LeftExpr: escape})
+		}
+	default:
+		return parser.parseExpressionTerm()
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NoneUnaryOpTp,
+		Tp:           ListExpressionTermTP,
+		RealExprTerm: list,
+	}, nil
+}
+
 func (parser *Parser) LexerOpToExpressionOp(op TokenType) *ExpressionOp {
 	switch op {
 	case PLUS:
@@ -65,8 +175,12 @@ func (parser *Parser) LexerOpToExpressionOp(op TokenType) *ExpressionOp {
 		return OperationAnd
 	case OR:
 		return OperationOr
-	// case lexer.OR + 1:
-	//	return ast.OperationISNot
+	case IN:
+		return OperationIn
+	case BETWEEN:
+		return OperationBetween
+	case LIKE:
+		return OperationLike
 	// case lexer.DOT:
 	//  return ast.OperationDot
 	default:
@@ -141,14 +255,43 @@ func (parser *Parser) parseExpressionTerm() (expr *ExpressionTerm, err error) {
 		// Must be literal
 		parser.UnReadToken()
 		expr, err = parser.parseLiteralExpressionTerm()
-	// case lexer.NOT, lexer.EXIST:
-	//	// Must be not exist subquery
-	//	parser.UnReadToken()
-	//	expr, err = parser.parseExistsSubQueryExpression()
+	case NULL:
+		// Null is a literal having an empty value.
+		expr = &ExpressionTerm{
+			UnaryOp:      NoneUnaryOpTp,
+			Tp:           LiteralExpressionTermTP,
+			RealExprTerm: LiteralExpressionStm(nil),
+		}
+	case PARAM:
+		expr = parser.parseParamExpressionTerm()
+	case VALUES:
+		// Must be values(col_name)
+		parser.UnReadToken()
+		expr, err = parser.parseValuesFunctionCall()
+	case IF:
+		// Must be if(condition, expr, expr)
+		parser.UnReadToken()
+		expr, err = parser.parseFunctionCallExpression()
+	case CASE:
+		expr, err = parser.parseCaseExpressionTerm()
+	case EXISTS:
+		expr, err = parser.parseExistsSubQueryExpressionTerm(true)
 	case MINUS:
 		parser.UnReadToken()
 		expr, err = parser.parseUnaryExpressionTerm()
+	case NOT:
+		if parser.matchTokenTypes(true, EXISTS) {
+			// Must be not exists subquery
+			expr, err = parser.parseExistsSubQueryExpressionTerm(false)
+			break
+		}
+		expr, err = parser.parseNotExpressionTerm()
 	case LEFTBRACKET:
+		if parser.isSelectStart() {
+			// Must be (select ...)
+			expr, err = parser.parseSubQueryExpressionTerm()
+			break
+		}
 		expr, err = parser.parseSubExpressionTerm()
 	default:
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
@@ -168,6 +311,20 @@ func (parser *Parser) parseUnaryExpressionTerm() (expr *ExpressionTerm, err erro
 	return
 }
 
+// parseNotExpressionTerm parses the expression after not. Like mysql, not has a lower priority
+// than comparisons, so not a = 1 is not (a = 1).
+func (parser *Parser) parseNotExpressionTerm() (*ExpressionTerm, error) {
+	expr, err := parser.resolveExpressionWithPriority(OperationEqual.Priority)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NotUnaryOpTp,
+		Tp:           SubExpressionTermTP,
+		RealExprTerm: expr,
+	}, nil
+}
+
 func (parser *Parser) parseFunctionCallOrIdentifierStm() (expr *ExpressionTerm, err error) {
 	if parser.matchTokenTypes(true, LEFTBRACKET) {
 		// Must be functionCall
@@ -197,11 +354,43 @@ func (parser *Parser) parseSubExpressionTerm() (expr *ExpressionTerm, err error)
 	}, nil
 }
 
+// parseSubQueryExpressionTerm parses select ...) after the left bracket.
+func (parser *Parser) parseSubQueryExpressionTerm() (*ExpressionTerm, error) {
+	query, err := parser.resolveSelectStm(false)
+	if err != nil {
+		return nil, err
+	}
+	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NoneUnaryOpTp,
+		Tp:           SubQueryExpressionTermTP,
+		RealExprTerm: SubQueryStm(query.(*SelectStm)),
+	}, nil
+}
+
+// parseExistsSubQueryExpressionTerm parses (select ...) after [not] exists.
+func (parser *Parser) parseExistsSubQueryExpressionTerm(exists bool) (*ExpressionTerm, error) {
+	if !parser.matchTokenTypes(false, LEFTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	subQuery, err := parser.parseSubQueryExpressionTerm()
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NoneUnaryOpTp,
+		Tp:           ExistsSubQueryExpressionTermTP,
+		RealExprTerm: ExistsSubQueryExpressionStm{Exists: exists, SubQuery: subQuery.RealExprTerm.(SubQueryStm)},
+	}, nil
+}
+
 func isTokenAOpe(token Token) bool {
 	switch token.Tp {
 	case PLUS, MINUS, MUL, DIVIDE, MOD, EQUAL, IS,
 		NOTEQUAL, GREAT, GREATEQUAL, LESS, LESSEQUAL, AND,
-		OR:
+		OR, IN, BETWEEN, LIKE:
 		return true
 	default:
 		return false
@@ -220,6 +409,22 @@ func (parser *Parser) parseLiteralExpressionTerm() (*ExpressionTerm, error) {
 	}, nil
 }
 
+// parseParamExpressionTerm makes the term of the ? placeholder just read. Since expressions can be
+// parsed again after rolling back, the index is counted by the placeholders before it.
+func (parser *Parser) parseParamExpressionTerm() *ExpressionTerm {
+	index := 0
+	for _, token := range parser.Tokens[:parser.pos-1] {
+		if token.Tp == PARAM {
+			index++
+		}
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NoneUnaryOpTp,
+		Tp:           ParamExpressionTermTP,
+		RealExprTerm: ParamMarkerStm(index),
+	}
+}
+
 func (parser *Parser) parseIdentifierExpressionTerm() (*ExpressionTerm, error) {
 	name, ok := parser.parseIdentOrWord(false)
 	if !ok {
@@ -233,50 +438,128 @@ func (parser *Parser) parseIdentifierExpressionTerm() (*ExpressionTerm, error) {
 }
 
 func (parser *Parser) parseFunctionCallExpression() (*ExpressionTerm, error) {
-	funcName, ok := parser.parseIdentOrWord(false)
+	funcName, ok := parser.parseIdentOrWord(true)
+	// If is a keyword, but it's also a function name.
+	if !ok && parser.matchTokenTypes(true, IF) {
+		token := parser.Tokens[parser.pos-1]
+		funcName, ok = parser.Data[token.StartPos:token.EndPos], true
+	}
 	if !ok {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
 	if !parser.matchTokenTypes(false, LEFTBRACKET) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
-	// count(*)
-	if parser.matchTokenTypes(true, MUL, RIGHTBRACKET) {
-		return &ExpressionTerm{
-			UnaryOp: NoneUnaryOpTp,
-			Tp: FuncCallExpressionTermTP,
-			RealExprTerm: FunctionCallExpressionStm{
-				FuncName: string(funcName),
-				Params: []*ExpressionStm{
-					{LeftExpr: &ExpressionTerm{Tp: AllExpressionTermTP}},
-				},
-			},
-		}, nil
-	}
+	distinct := parser.matchTokenTypes(true, DISTINCT)
 	var params []*ExpressionStm
-	for {
-		paramExpression, err := parser.resolveExpression()
-		if err != nil {
-			return nil, err
+	switch {
+	// count(*)
+	case !distinct && parser.matchTokenTypes(true, MUL, RIGHTBRACKET):
+		params = []*ExpressionStm{{LeftExpr: &ExpressionTerm{Tp: AllExpressionTermTP}}}
+	// Function without params, like row_number().
+	case !distinct && parser.matchTokenTypes(true, RIGHTBRACKET):
+	default:
+		for {
+			paramExpression, err := parser.resolveExpression()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, paramExpression)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
 		}
-		params = append(params, paramExpression)
-		if !parser.matchTokenTypes(true, COMMA) {
-			break
+		if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
 		}
 	}
-	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
-		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	over, err := parser.parseWindowSpec()
+	if err != nil {
+		return nil, err
 	}
 	return &ExpressionTerm{
 		UnaryOp: NoneUnaryOpTp,
 		Tp:      FuncCallExpressionTermTP,
 		RealExprTerm: FunctionCallExpressionStm{
 			FuncName: string(funcName),
+			Distinct: distinct,
 			Params:   params,
+			Over:     over,
+		},
+	}, nil
+}
+
+// parseValuesFunctionCall parses values(col_name) which references the inserted value of column
+// col_name in on duplicate key update.
+func (parser *Parser) parseValuesFunctionCall() (*ExpressionTerm, error) {
+	if !parser.matchTokenTypes(false, VALUES, LEFTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	param, err := parser.parseIdentifierExpressionTerm()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &ExpressionTerm{
+		UnaryOp: NoneUnaryOpTp,
+		Tp:      FuncCallExpressionTermTP,
+		RealExprTerm: FunctionCallExpressionStm{
+			FuncName: "VALUES",
+			Params:   []*ExpressionStm{{LeftExpr: param}},
 		},
 	}, nil
 }
 
+// parseCaseExpressionTerm parses the case expression after case.
+func (parser *Parser) parseCaseExpressionTerm() (*ExpressionTerm, error) {
+	stm := CaseExpressionStm{}
+	if !parser.matchTokenTypes(true, WHEN) {
+		value, err := parser.resolveExpression()
+		if err != nil {
+			return nil, err
+		}
+		stm.Value = value
+		if !parser.matchTokenTypes(false, WHEN) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+	}
+	for {
+		when, err := parser.resolveExpression()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.matchTokenTypes(false, THEN) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		then, err := parser.resolveExpression()
+		if err != nil {
+			return nil, err
+		}
+		stm.Whens = append(stm.Whens, when)
+		stm.Thens = append(stm.Thens, then)
+		if !parser.matchTokenTypes(true, WHEN) {
+			break
+		}
+	}
+	if parser.matchTokenTypes(true, ELSE) {
+		elseExpr, err := parser.resolveExpression()
+		if err != nil {
+			return nil, err
+		}
+		stm.Else = elseExpr
+	}
+	if !parser.matchTokenTypes(false, END) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &ExpressionTerm{
+		UnaryOp:      NoneUnaryOpTp,
+		Tp:           CaseExpressionTermTP,
+		RealExprTerm: stm,
+	}, nil
+}
+
 //func (parser *Parser) parseInOrLikeExpressions(token lexer.Token, leftExpr *ast.ExpressionStm) (expr *ast.ExpressionStm, err error) {
 //	switch token.Tp {
 //	case lexer.NOT:
@@ -378,30 +661,3 @@ func (parser *Parser) parseFunctionCallExpression() (*ExpressionTerm, error) {
 //		},
 //	}, nil
 //}
-
-//func (parser *Parser) parseExistsSubQueryExpression() (*ast.ExpressionStm, error) {
-//	nonExists := parser.matchTokenTypes(true, lexer.NOT)
-//	if !parser.matchTokenTypes(true, lexer.EXIST) {
-//		return nil, parser.MakeSyntaxError(parser.pos)
-//	}
-//	if !parser.matchTokenTypes(false, lexer.LEFTBRACKET) {
-//		return nil, parser.MakeSyntaxError(parser.pos-1)
-//	}
-//	query, err := parser.resolveSelectStm(false)
-//	if err != nil {
-//		return nil, err
-//	}
-//	if !parser.matchTokenTypes(false, lexer.RIGHTBRACKET) {
-//		return nil, parser.MakeSyntaxError(parser.pos-1)
-//	}
-//	return &ast.ExpressionStm{
-//		Tp: ast.VariableExpressionTp,
-//		Expr: ast.VariableExpressionStm{
-//			Tp: ast.ExistsSubQueryExpressionTp,
-//			Variable: ast.ExistsSubQueryExpressionStm{
-//				Exists:   !nonExists,
-//				SubQuery: query,
-//			},
-//		},
-//	}, nil
-//}
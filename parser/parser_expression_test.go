@@ -31,3 +31,171 @@ func TestParser_Expression(t *testing.T) {
 	sql = " id = 1 * 1 + 1 or id = 1"
 	testOneExpression(t, []byte(sql))
 }
+
+func parseTestExpression(sql string) (*ExpressionStm, error) {
+	parser := NewParser()
+	tokens, err := NewLexer().Lex([]byte(sql))
+	if err != nil {
+		return nil, err
+	}
+	parser.Tokens = tokens
+	parser.Data = []byte(sql)
+	expr, err := parser.resolveExpression()
+	if err == nil && parser.pos != len(tokens) {
+		return nil, parser.MakeSyntaxError(parser.pos)
+	}
+	return expr, err
+}
+
+func TestParser_PredicateExpression(t *testing.T) {
+	expr, err := parseTestExpression("a + 1 in (1, 2 * 3) and b not like 'x%' escape '!'")
+	assert.Nil(t, err)
+	assert.Equal(t, AND, expr.Op.Tp)
+	in := expr.LeftExpr.(*ExpressionStm)
+	assert.Equal(t, IN, in.Op.Tp)
+	assert.Equal(t, ADD, in.LeftExpr.(*ExpressionStm).Op.Tp)
+	assert.Len(t, in.RightExpr.(*ExpressionTerm).RealExprTerm.(ExpressionListStm), 2)
+	like := expr.RightExpr.(*ExpressionStm)
+	assert.Equal(t, NOTLIKE, like.Op.Tp)
+	assert.Len(t, like.RightExpr.(*ExpressionTerm).RealExprTerm.(ExpressionListStm), 2)
+
+	// The and of between isn't a logic and.
+	expr, err = parseTestExpression("a not between 1 and b + 1 and c is not true")
+	assert.Nil(t, err)
+	assert.Equal(t, AND, expr.Op.Tp)
+	assert.Equal(t, NOTBETWEEN, expr.LeftExpr.(*ExpressionStm).Op.Tp)
+	assert.Equal(t, ISNOT, expr.RightExpr.(*ExpressionStm).Op.Tp)
+
+	// null is a literal having an empty value.
+	expr, err = parseTestExpression("a is null or b is not null")
+	assert.Nil(t, err)
+	assert.Equal(t, OR, expr.Op.Tp)
+	is := expr.LeftExpr.(*ExpressionStm)
+	assert.Equal(t, IS, is.Op.Tp)
+	null := is.RightExpr.(*ExpressionTerm)
+	assert.Equal(t, LiteralExpressionTermTP, null.Tp)
+	assert.Empty(t, null.RealExprTerm.(LiteralExpressionStm))
+	assert.Equal(t, ISNOT, expr.RightExpr.(*ExpressionStm).Op.Tp)
+
+	// not has a lower priority than comparisons.
+	expr, err = parseTestExpression("not a = 1 or b")
+	assert.Nil(t, err)
+	assert.Equal(t, OR, expr.Op.Tp)
+	not := expr.LeftExpr.(*ExpressionTerm)
+	assert.Equal(t, NotUnaryOpTp, not.UnaryOp)
+	assert.Equal(t, EQUAL, not.RealExprTerm.(*ExpressionStm).Op.Tp)
+
+	for _, sql := range []string{"a in ()", "a in 1", "a in (1) + 1", "a between 1", "a between 1 or 2", "a not 1",
+		"a like", "a is not", "not"} {
+		_, err = parseTestExpression(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestParser_CaseExpression(t *testing.T) {
+	expr, err := parseTestExpression("case when a > 1 then 'x' when a > 0 then 'y' else 'z' end = 'x'")
+	assert.Nil(t, err)
+	assert.Equal(t, EQUAL, expr.Op.Tp)
+	caseTerm := expr.LeftExpr.(*ExpressionTerm)
+	assert.Equal(t, CaseExpressionTermTP, caseTerm.Tp)
+	caseExpr := caseTerm.RealExprTerm.(CaseExpressionStm)
+	assert.Nil(t, caseExpr.Value)
+	assert.Len(t, caseExpr.Whens, 2)
+	assert.Len(t, caseExpr.Thens, 2)
+	assert.NotNil(t, caseExpr.Else)
+
+	expr, err = parseTestExpression("case a + 1 when 1 then b end")
+	assert.Nil(t, err)
+	caseExpr = expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(CaseExpressionStm)
+	assert.Equal(t, ADD, caseExpr.Value.Op.Tp)
+	assert.Len(t, caseExpr.Whens, 1)
+	assert.Nil(t, caseExpr.Else)
+
+	// If is a keyword, but it's also a function.
+	expr, err = parseTestExpression("if(a > 1, a, ifnull(b, 0))")
+	assert.Nil(t, err)
+	call := expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm)
+	assert.Equal(t, "if", call.FuncName)
+	assert.Len(t, call.Params, 3)
+
+	for _, sql := range []string{"case end", "case when a end", "case when a then 1", "case a then 1 end",
+		"case when a then 1 else end", "if a"} {
+		_, err = parseTestExpression(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestParser_SubQueryExpression(t *testing.T) {
+	expr, err := parseTestExpression("id = (select max(id) from t2)")
+	assert.Nil(t, err)
+	subQueryTerm := expr.RightExpr.(*ExpressionTerm)
+	assert.Equal(t, SubQueryExpressionTermTP, subQueryTerm.Tp)
+	assert.NotNil(t, subQueryTerm.RealExprTerm.(SubQueryStm))
+
+	expr, err = parseTestExpression("id not in (select id from t2 where t2.a = t1.a)")
+	assert.Nil(t, err)
+	assert.Equal(t, NOTIN, expr.Op.Tp)
+	assert.Equal(t, SubQueryExpressionTermTP, expr.RightExpr.(*ExpressionTerm).Tp)
+
+	expr, err = parseTestExpression("not exists (select * from t2) and exists (select 1 from t3)")
+	assert.Nil(t, err)
+	exists := expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(ExistsSubQueryExpressionStm)
+	assert.False(t, exists.Exists)
+	exists = expr.RightExpr.(*ExpressionTerm).RealExprTerm.(ExistsSubQueryExpressionStm)
+	assert.True(t, exists.Exists)
+
+	// A parenthesized expression is not a subquery.
+	expr, err = parseTestExpression("(id + 1) in (1, 2)")
+	assert.Nil(t, err)
+	assert.Equal(t, SubExpressionTermTP, expr.LeftExpr.(*ExpressionTerm).Tp)
+
+	for _, sql := range []string{"(select id from t2", "exists select id from t2", "exists (id)", "id in (select id from t2"} {
+		_, err = parseTestExpression(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestParser_WindowFunctionCall(t *testing.T) {
+	expr, err := parseTestExpression("row_number() over (partition by a, b order by c desc)")
+	assert.Nil(t, err)
+	call := expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm)
+	assert.Len(t, call.Params, 0)
+	assert.Len(t, call.Over.PartitionBy, 2)
+	assert.False(t, call.Over.OrderBy.Expressions[0].Asc)
+	assert.Nil(t, call.Over.Frame)
+
+	expr, err = parseTestExpression("sum(a) over (order by a rows between 2 preceding and unbounded following)")
+	assert.Nil(t, err)
+	frame := expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm).Over.Frame
+	assert.Equal(t, WindowFrameStm{Tp: RowsFrameTp, Start: FrameBoundStm{Tp: PrecedingTp, Offset: 2},
+		End: FrameBoundStm{Tp: UnboundedFollowingTp}}, *frame)
+
+	expr, err = parseTestExpression("count(*) over (range unbounded preceding)")
+	assert.Nil(t, err)
+	frame = expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm).Over.Frame
+	assert.Equal(t, WindowFrameStm{Tp: RangeFrameTp, Start: FrameBoundStm{Tp: UnboundedPrecedingTp},
+		End: FrameBoundStm{Tp: CurrentRowTp}}, *frame)
+
+	for _, sql := range []string{"sum(a) over", "sum(a) over (partition a)", "sum(a) over (rows unbounded following)",
+		"sum(a) over (rows between current row and 1 preceding)", "sum(a) over (rows between 1 following and current row)"} {
+		_, err = parseTestExpression(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestParser_DistinctFunctionCall(t *testing.T) {
+	expr, err := parseTestExpression("count(distinct a + 1)")
+	assert.Nil(t, err)
+	call := expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm)
+	assert.True(t, call.Distinct)
+	assert.Len(t, call.Params, 1)
+
+	expr, err = parseTestExpression("count(*)")
+	assert.Nil(t, err)
+	assert.False(t, expr.LeftExpr.(*ExpressionTerm).RealExprTerm.(FunctionCallExpressionStm).Distinct)
+
+	for _, sql := range []string{"count(distinct)", "count(distinct *)"} {
+		_, err = parseTestExpression(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
@@ -10,6 +10,9 @@ func (parser *Parser) parseIndexDef() (*IndexDefStm, error) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
 	indexName, _ := parser.parseIdentOrWord(true)
+	if !parser.matchTokenTypes(false, LEFTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
 	var colNames []string
 	for {
 		colName, ok := parser.parseIdentOrWord(false)
@@ -21,5 +24,8 @@ func (parser *Parser) parseIndexDef() (*IndexDefStm, error) {
 			break
 		}
 	}
+	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
 	return &IndexDefStm{IndexName: string(indexName), ColNames: colNames}, nil
 }
@@ -1,10 +1,16 @@
 package parser
 
 // Insert statement is like:
-// * insert into tb_name [( col_name... )] values (expression...)
+// * insert into tb_name [( col_name... )] values (expression...) [, (expression...)...] [on_duplicate]
+// * insert into tb_name [( col_name... )] select_statement [on_duplicate]
+// * replace into tb_name [( col_name... )] {values (expression...) [, (expression...)...] | select_statement}
+// where on_duplicate is like:
+// * on duplicate key update assignment [, assignment...]
+// and values(col_name) can be used in assignment to reference the inserted value.
 
 func (parser *Parser) resolveInsertStm() (Stm, error) {
-	if !parser.matchTokenTypes(false, INSERT) {
+	replace := parser.matchTokenTypes(true, REPLACE)
+	if !replace && !parser.matchTokenTypes(false, INSERT) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
 	if !parser.matchTokenTypes(false, INTO) {
@@ -32,23 +38,67 @@ func (parser *Parser) resolveInsertStm() (Stm, error) {
 			return nil, parser.MakeSyntaxError(parser.pos - 1)
 		}
 	}
-	// should be values (
-	if !parser.matchTokenTypes(false, VALUES, LEFTBRACKET) {
-		return nil, parser.MakeSyntaxError(parser.pos - 2)
-	}
-	var valueExpressions []*ExpressionStm
-	for {
-		valueExpression, err := parser.resolveExpression()
+	stm := &InsertIntoStm{TableName: string(tableName), Cols: colNames, Replace: replace}
+	if parser.isSelectStart() {
+		selectStm, err := parser.resolveSelectStm(false)
 		if err != nil {
 			return nil, err
 		}
-		valueExpressions = append(valueExpressions, valueExpression)
+		stm.Select = selectStm.(*SelectStm)
+		return parser.parseOnDuplicateUpdate(stm)
+	}
+	if !parser.matchTokenTypes(false, VALUES) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	var rows [][]*ExpressionStm
+	for {
+		// should be (
+		if !parser.matchTokenTypes(false, LEFTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		var valueExpressions []*ExpressionStm
+		for {
+			valueExpression, err := parser.resolveExpression()
+			if err != nil {
+				return nil, err
+			}
+			valueExpressions = append(valueExpressions, valueExpression)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
+		}
+		if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		rows = append(rows, valueExpressions)
 		if !parser.matchTokenTypes(true, COMMA) {
 			break
 		}
 	}
-	if !parser.matchTokenTypes(false, RIGHTBRACKET, SEMICOLON) {
-		return nil, parser.MakeSyntaxError(parser.pos - 2)
+	stm.Values = rows
+	return parser.parseOnDuplicateUpdate(stm)
+}
+
+// parseOnDuplicateUpdate parses the optional on duplicate key update assignments and the
+// ending semicolon.
+func (parser *Parser) parseOnDuplicateUpdate(stm *InsertIntoStm) (Stm, error) {
+	if !stm.Replace && parser.matchTokenTypes(true, ON) {
+		if !parser.matchTokenTypes(false, DUPLICATE, KEY, UPDATE) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		for {
+			assignment, err := parser.parseAssignmentStm()
+			if err != nil {
+				return nil, err
+			}
+			stm.OnDuplicateUpdate = append(stm.OnDuplicateUpdate, assignment)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
+		}
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
-	return &InsertIntoStm{TableName: string(tableName), Cols: colNames, Values: valueExpressions}, nil
+	return stm, nil
 }
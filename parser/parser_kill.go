@@ -0,0 +1,22 @@
+package parser
+
+import "strconv"
+
+// kill query connection_id;
+func (parser *Parser) resolveKillStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, KILL, QUERY) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	value, ok := parser.parseValue(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	id, err := strconv.ParseUint(string(value), 10, 32)
+	if err != nil {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &KillStm{ConnectionID: uint32(id)}, nil
+}
@@ -0,0 +1,75 @@
+package parser
+
+// prepare stm_name from 'statement';
+func (parser *Parser) resolvePrepareStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, PREPARE) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	name, ok := parser.parseIdentOrWord(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if !parser.matchTokenTypes(false, FROM) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	value, ok := parser.parseValue(false)
+	if !ok || (value[0] != '\'' && value[0] != '"') {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &PrepareStm{Name: string(name), Query: value[1 : len(value)-1]}, nil
+}
+
+// execute stm_name [using expr [, expr...]];
+func (parser *Parser) resolveExecuteStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, EXECUTE) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	name, ok := parser.parseIdentOrWord(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	stm := &ExecuteStm{Name: string(name)}
+	if parser.matchTokenTypes(true, USING) {
+		for {
+			param, err := parser.resolveExpression()
+			if err != nil {
+				return nil, err
+			}
+			stm.Params = append(stm.Params, param)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
+		}
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return stm, nil
+}
+
+// deallocate prepare stm_name;
+func (parser *Parser) resolveDeallocatePrepareStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, DEALLOCATE, PREPARE) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	stm, err := parser.parsePreparedStmName()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return stm, nil
+}
+
+// parsePreparedStmName parses the stm_name after {deallocate | drop} prepare.
+func (parser *Parser) parsePreparedStmName() (*DeallocatePrepareStm, error) {
+	name, ok := parser.parseIdentOrWord(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &DeallocatePrepareStm{Name: string(name)}, nil
+}
@@ -3,11 +3,138 @@ package parser
 // Select statement is like:
 // * select [all | distinct | distinctrow] select_expression... from table_reference... [WhereStm] [GroupByStm] [HavingStm]
 // [OrderByStm] [LimitStm] [for update | lock in share mode]
+// * select ... {union | intersect | except} [all | distinct] select ... [OrderByStm] [LimitStm]
+// * with [recursive] name [(col_name...)] as (select ...) [, ...] select ...
 // select_expression could be:
 // expr [as] alias
 // *
 
-func (parser *Parser) resolveSelectStm(needCheckSemicolon bool) (stm Stm, err error) {
+func (parser *Parser) resolveSelectStm(needCheckSemicolon bool) (Stm, error) {
+	with, err := parser.parseWith()
+	if err != nil {
+		return nil, err
+	}
+	stm, err := parser.resolveSimpleSelectStm()
+	if err != nil {
+		return nil, err
+	}
+	stm.With = with
+	err = parser.parseSetOperations(stm)
+	if err != nil {
+		return nil, err
+	}
+	if needCheckSemicolon && !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if parser.matchTokenTypes(true, FOR, UPDATE) {
+		stm.LockTp = ForUpdateLockTp
+	}
+	if parser.matchTokenTypes(true, LOCK, IN, SHARE, MOD) {
+		stm.LockTp = LockInShareModeTp
+	}
+	return stm, nil
+}
+
+// parseSetOperations parses the set operations following stm. Only the last select can have order by
+// and limit, which are moved to stm since they apply to the whole result.
+func (parser *Parser) parseSetOperations(stm *SelectStm) error {
+	last := stm
+	for {
+		tp, ok := parser.parseSetOperationTp()
+		if !ok {
+			break
+		}
+		if last.OrderBy != nil || last.LimitStm != nil {
+			return parser.MakeSyntaxError(parser.pos - 1)
+		}
+		all := parser.matchTokenTypes(true, ALL)
+		if !all {
+			parser.matchTokenTypes(true, DISTINCT)
+		}
+		selectStm, err := parser.resolveSimpleSelectStm()
+		if err != nil {
+			return err
+		}
+		stm.SetOperations = append(stm.SetOperations, SetOperationStm{Tp: tp, All: all, Select: selectStm})
+		last = selectStm
+	}
+	if last != stm {
+		stm.OrderBy, stm.LimitStm = last.OrderBy, last.LimitStm
+		last.OrderBy, last.LimitStm = nil, nil
+	}
+	return nil
+}
+
+// parseWith parses the common table expressions before select, it returns nil if there is no with.
+func (parser *Parser) parseWith() (*WithStm, error) {
+	if !parser.matchTokenTypes(true, WITH) {
+		return nil, nil
+	}
+	stm := &WithStm{Recursive: parser.matchTokenTypes(true, RECURSIVE)}
+	for {
+		name, ok := parser.parseIdentOrWord(false)
+		if !ok {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		cte := CommonTableExprStm{Name: string(name)}
+		if parser.matchTokenTypes(true, LEFTBRACKET) {
+			for {
+				col, ok := parser.parseIdentOrWord(false)
+				if !ok {
+					return nil, parser.MakeSyntaxError(parser.pos - 1)
+				}
+				cte.Cols = append(cte.Cols, string(col))
+				if !parser.matchTokenTypes(true, COMMA) {
+					break
+				}
+			}
+			if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+				return nil, parser.MakeSyntaxError(parser.pos - 1)
+			}
+		}
+		if !parser.matchTokenTypes(false, AS, LEFTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		selectStm, err := parser.resolveSelectStm(false)
+		if err != nil {
+			return nil, err
+		}
+		if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		cte.Select = selectStm.(*SelectStm)
+		stm.CTEs = append(stm.CTEs, cte)
+		if !parser.matchTokenTypes(true, COMMA) {
+			return stm, nil
+		}
+	}
+}
+
+// isSelectStart returns whether the next token starts a select, which is select or with.
+func (parser *Parser) isSelectStart() bool {
+	token, ok := parser.NextToken()
+	if !ok {
+		return false
+	}
+	parser.UnReadToken()
+	return token.Tp == SELECT || token.Tp == WITH
+}
+
+func (parser *Parser) parseSetOperationTp() (SetOperationTp, bool) {
+	switch {
+	case parser.matchTokenTypes(true, UNION):
+		return UnionTp, true
+	case parser.matchTokenTypes(true, INTERSECT):
+		return IntersectTp, true
+	case parser.matchTokenTypes(true, EXCEPT):
+		return ExceptTp, true
+	default:
+		return UnionTp, false
+	}
+}
+
+// resolveSimpleSelectStm resolves a select without set operations.
+func (parser *Parser) resolveSimpleSelectStm() (*SelectStm, error) {
 	if !parser.matchTokenTypes(false, SELECT) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
@@ -26,6 +153,7 @@ func (parser *Parser) resolveSelectStm(needCheckSemicolon bool) (stm Stm, err er
 		parser.UnReadToken()
 	}
 	var selectExpressionStm *SelectExpressionStm
+	var err error
 	if parser.matchTokenTypes(true, MUL) {
 		selectExpressionStm, err = parser.parseStarSelectExpression()
 	} else {
@@ -34,8 +162,7 @@ func (parser *Parser) resolveSelectStm(needCheckSemicolon bool) (stm Stm, err er
 	if err != nil {
 		return nil, err
 	}
-	stm, err = parser.resolveRemainingSelectStm(selectTp, selectExpressionStm, needCheckSemicolon)
-	return
+	return parser.resolveRemainingSelectStm(selectTp, selectExpressionStm)
 }
 
 func (parser *Parser) parseStarSelectExpression() (*SelectExpressionStm, error) {
@@ -72,7 +199,7 @@ func (parser *Parser) parseExprSelectExpression() (*SelectExpressionStm, error)
 	}, nil
 }
 
-func (parser *Parser) resolveRemainingSelectStm(selectTp SelectTp, expr *SelectExpressionStm, needCheckSemicolon bool) (*SelectStm, error) {
+func (parser *Parser) resolveRemainingSelectStm(selectTp SelectTp, expr *SelectExpressionStm) (*SelectStm, error) {
 	if !parser.matchTokenTypes(false, FROM) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
@@ -107,16 +234,6 @@ func (parser *Parser) resolveRemainingSelectStm(selectTp SelectTp, expr *SelectE
 	if err != nil {
 		return nil, err
 	}
-	if needCheckSemicolon && !parser.matchTokenTypes(false, SEMICOLON) {
-		return nil, parser.MakeSyntaxError(parser.pos - 1)
-	}
-	lockTp := NoneLockTp
-	if parser.matchTokenTypes(true, FOR, UPDATE) {
-		lockTp = ForUpdateLockTp
-	}
-	if parser.matchTokenTypes(true, LOCK, IN, SHARE, MOD) {
-		lockTp = LockInShareModeTp
-	}
 	return &SelectStm{
 		Tp:                selectTp,
 		SelectExpressions: expr,
@@ -126,6 +243,5 @@ func (parser *Parser) resolveRemainingSelectStm(selectTp SelectTp, expr *SelectE
 		Groupby:           groupByStm,
 		Having:            havingStm,
 		LimitStm:          limitStm,
-		LockTp:            lockTp,
 	}, nil
 }
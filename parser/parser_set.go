@@ -0,0 +1,23 @@
+package parser
+
+// set variable_name = value;
+func (parser *Parser) resolveSetStm() (Stm, error) {
+	if !parser.matchTokenTypes(false, SET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	name, ok := parser.parseIdentOrWord(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if !parser.matchTokenTypes(false, EQUAL) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	value, ok := parser.parseValue(false)
+	if !ok {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	if !parser.matchTokenTypes(false, SEMICOLON) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return &SetVariableStm{Name: string(name), Value: value}, nil
+}
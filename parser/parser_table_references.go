@@ -5,15 +5,18 @@ package parser
 // A table reference statement is like:
 // table_factor | joined_table
 // where table_factor can be:
-// * {tb_name [[as] alias] | (table_subquery) as alias} | (tableRef)
+// * {tb_name [[as] alias] | (table_subquery) [as] alias} | (tableRef)
 // and joined_table is like:
-// * table_factor { {left|right} [outer] join table_reference join_specification | inner join table_factor [join_specification] } *
+// * table_factor { {left|right|full} [outer] join table_factor join_specification | [inner|cross] join table_factor [join_specification]
+//   | natural [{left|right} [outer]] join table_factor } *
 // join_specification is like:
 // on where_condition | using (col...)
 
 // Diff with mysql
 // * index_hint are not supported.
-// * cross join, straight join and natural join keywords are not supported.
+// * straight join is not supported.
+// * full [outer] join is supported.
+// * the right side of a join is a table factor, nested joins need parentheses like t1 join (t2 join t3 on ...).
 
 var (
 	emptyTableRefStm            = TableReferenceStm{}
@@ -21,26 +24,14 @@ var (
 )
 
 func (parser *Parser) parseTableReferenceStm() (stm TableReferenceStm, err error) {
-	token, ok := parser.NextToken()
-	if !ok {
-		return emptyTableRefStm, parser.MakeSyntaxError(parser.pos - 1)
-	}
-	var tableFactorStm TableReferenceTableFactorStm
-	switch token.Tp {
-	case LEFTBRACKET:
-		parser.UnReadToken()
-		tableFactorStm, err = parser.parseSubTableRefOrTableSubQuery()
-	case IDENT, WORD:
-		parser.UnReadToken()
-		tableFactorStm, err = parser.parseTableAsStm()
-	default:
-		return emptyTableRefStm, parser.MakeSyntaxError(parser.pos - 1)
+	tableFactorStm, err := parser.parseTableFactor()
+	if err != nil {
+		return emptyTableRefStm, err
 	}
-
 	// Also need to check join type, because maybe a joined_table reference.
-	token, ok = parser.NextToken()
+	token, _ := parser.NextToken()
 	switch token.Tp {
-	case LEFT, RIGHT, INNER, JOIN:
+	case LEFT, RIGHT, INNER, JOIN, CROSS, NATURAL, FULL:
 		parser.UnReadToken()
 		stm, err = parser.ParseJoinStm(tableFactorStm)
 	default:
@@ -53,26 +44,37 @@ func (parser *Parser) parseTableReferenceStm() (stm TableReferenceStm, err error
 		return stm, err
 	}
 	return
-
 }
 
-func (parser *Parser) parseSubTableRefOrTableSubQuery() (stm TableReferenceTableFactorStm, err error) {
-	if !parser.matchTokenTypes(false, LEFTBRACKET) {
-		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
-	}
+func (parser *Parser) parseTableFactor() (TableReferenceTableFactorStm, error) {
 	token, ok := parser.NextToken()
 	if !ok {
 		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
 	}
 	switch token.Tp {
-	case SELECT:
+	case LEFTBRACKET:
 		parser.UnReadToken()
-		stm, err = parser.parseTableSubQuery()
-	default:
+		return parser.parseSubTableRefOrTableSubQuery()
+	case IDENT, WORD:
 		parser.UnReadToken()
-		stm, err = parser.parseSubTableRefStm()
+		return parser.parseTableAsStm()
+	default:
+		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
+	}
+}
+
+func (parser *Parser) parseSubTableRefOrTableSubQuery() (stm TableReferenceTableFactorStm, err error) {
+	pos := parser.pos
+	if !parser.matchTokenTypes(false, LEFTBRACKET) {
+		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	isSubQuery := parser.isSelectStart()
+	// Back to the left bracket.
+	parser.pos = pos
+	if isSubQuery {
+		return parser.parseTableSubQuery()
 	}
-	return stm, err
+	return parser.parseSubTableRefStm()
 }
 
 func (parser *Parser) parseSubTableRefStm() (stm TableReferenceTableFactorStm, err error) {
@@ -119,7 +121,8 @@ func (parser *Parser) parseTableAsStm() (TableReferenceTableFactorStm, error) {
 	}, nil
 }
 
-// * table_sub_query := (selectStm) as alias
+// * table_sub_query := (selectStm) [as] alias
+// Like mysql, the alias is required.
 func (parser *Parser) parseTableSubQuery() (TableReferenceTableFactorStm, error) {
 	if !parser.matchTokenTypes(false, LEFTBRACKET) {
 		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
@@ -128,16 +131,14 @@ func (parser *Parser) parseTableSubQuery() (TableReferenceTableFactorStm, error)
 	if err != nil {
 		return emptyTableRefTableFactorStm, err
 	}
-	if parser.matchTokenTypes(false, AS) {
+	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
 		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
 	}
+	parser.matchTokenTypes(true, AS)
 	alias, ok := parser.parseIdentOrWord(false)
 	if !ok {
 		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
 	}
-	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
-		return emptyTableRefTableFactorStm, parser.MakeSyntaxError(parser.pos - 1)
-	}
 	return TableReferenceTableFactorStm{
 		Tp: TableReferenceTableSubQueryTp,
 		TableFactorReference: TableSubQueryStm{
@@ -170,8 +171,9 @@ func (parser *Parser) parseJoinSpecification(joinType JoinType) (*JoinSpecificat
 	return nil, parser.MakeSyntaxError(parser.pos - 1)
 }
 
+// Cross join is the same as inner join like mysql.
 func (parser *Parser) parseJoinType() (JoinType, error) {
-	if parser.matchTokenTypes(true, JOIN) || parser.matchTokenTypes(true, INNER, JOIN) {
+	if parser.matchTokenTypes(true, JOIN) || parser.matchTokenTypes(true, INNER, JOIN) || parser.matchTokenTypes(true, CROSS, JOIN) {
 		return InnerJoin, nil
 	}
 	if parser.matchTokenTypes(true, LEFT, JOIN) || parser.matchTokenTypes(true, LEFT, OUTER, JOIN) {
@@ -180,14 +182,32 @@ func (parser *Parser) parseJoinType() (JoinType, error) {
 	if parser.matchTokenTypes(true, RIGHT, JOIN) || parser.matchTokenTypes(true, RIGHT, OUTER, JOIN) {
 		return RightOuterJoin, nil
 	}
+	if parser.matchTokenTypes(true, FULL, JOIN) || parser.matchTokenTypes(true, FULL, OUTER, JOIN) {
+		return FullOuterJoin, nil
+	}
+	return 0, parser.MakeSyntaxError(parser.pos)
+}
+
+// natural [{left|right} [outer]] join
+func (parser *Parser) parseNaturalJoinType() (JoinType, error) {
+	if parser.matchTokenTypes(true, NATURAL, JOIN) || parser.matchTokenTypes(true, NATURAL, INNER, JOIN) {
+		return InnerJoin, nil
+	}
+	if parser.matchTokenTypes(true, NATURAL, LEFT, JOIN) || parser.matchTokenTypes(true, NATURAL, LEFT, OUTER, JOIN) {
+		return LeftOuterJoin, nil
+	}
+	if parser.matchTokenTypes(true, NATURAL, RIGHT, JOIN) || parser.matchTokenTypes(true, NATURAL, RIGHT, OUTER, JOIN) {
+		return RightOuterJoin, nil
+	}
 	return 0, parser.MakeSyntaxError(parser.pos)
 }
 
 func isJoinTypeToken(t Token) bool {
 	tp := t.Tp
-	return tp == LEFT || tp == RIGHT || tp == INNER || tp == JOIN
+	return tp == LEFT || tp == RIGHT || tp == INNER || tp == JOIN || tp == CROSS || tp == NATURAL || tp == FULL
 }
 
+// The joined table of every join factor is a table factor, so joins are evaluated from left to right.
 func (parser *Parser) ParseJoinFactors() (factors []JoinFactor, err error) {
 	for {
 		token, ok := parser.NextToken()
@@ -196,22 +216,35 @@ func (parser *Parser) ParseJoinFactors() (factors []JoinFactor, err error) {
 			break
 		}
 		parser.UnReadToken()
-		joinType, err := parser.parseJoinType()
-		if err != nil {
-			return nil, err
+		natural := token.Tp == NATURAL
+		var joinType JoinType
+		if natural {
+			joinType, err = parser.parseNaturalJoinType()
+		} else {
+			joinType, err = parser.parseJoinType()
 		}
-		joinedTableRef, err := parser.parseTableReferenceStm()
 		if err != nil {
 			return nil, err
 		}
-		joinSpec, err := parser.parseJoinSpecification(joinType)
+		tableFactorStm, err := parser.parseTableFactor()
 		if err != nil {
 			return nil, err
 		}
+		// A natural join doesn't have join specification.
+		joinSpec := &JoinSpecification{Tp: JoinSpecificationNatural}
+		if !natural {
+			joinSpec, err = parser.parseJoinSpecification(joinType)
+			if err != nil {
+				return nil, err
+			}
+		}
 		joinFactor := JoinFactor{
-			JoinTp:               joinType,
-			JoinedTableReference: joinedTableRef,
-			JoinSpec:             joinSpec,
+			JoinTp: joinType,
+			JoinedTableReference: TableReferenceStm{
+				Tp:             TableReferenceTableFactorTp,
+				TableReference: tableFactorStm,
+			},
+			JoinSpec: joinSpec,
 		}
 		factors = append(factors, joinFactor)
 	}
@@ -66,6 +66,97 @@ func TestParser_Select(t *testing.T) {
 	testSql(t, sql)
 	sql = "select c1 from where i = 1;"
 	testSqlFail(t, sql)
+	parse := NewParser()
+	_, err := parse.Parse([]byte("select /*+ no_index(test) */ c1 from test;"))
+	assert.Nil(t, err)
+	assert.Len(t, parse.Hints, 1)
+}
+
+func TestParser_SelectFromSubQuery(t *testing.T) {
+	parse := NewParser()
+	stm, err := parse.Parse([]byte("select t.id from (select id from test1 where id > 1) as t, (test2 join test3 on a = b);"))
+	assert.Nil(t, err)
+	tableRefs := stm.(*SelectStm).TableReferences
+	assert.Len(t, tableRefs, 2)
+	subQuery := tableRefs[0].TableReference.(TableReferenceTableFactorStm)
+	assert.Equal(t, TableReferenceTableSubQueryTp, subQuery.Tp)
+	assert.Equal(t, "t", subQuery.TableFactorReference.(TableSubQueryStm).Alias)
+	subTableRef := tableRefs[1].TableReference.(TableReferenceTableFactorStm)
+	assert.Equal(t, TableReferenceSubTableReferenceStmTP, subTableRef.Tp)
+	assert.Equal(t, TableReferenceJoinTableTp, subTableRef.TableFactorReference.(TableReferenceStm).Tp)
+
+	testSql(t, "select * from (select * from (select id from test1) a) b join (test2) on b.id = test2.id;")
+	// The alias is required.
+	testSqlFail(t, "select * from (select id from test1);")
+	testSqlFail(t, "select * from (select id from test1 as t);")
+	testSqlFail(t, "select * from (test1 join test2;")
+}
+
+func TestParser_SelectJoinTypes(t *testing.T) {
+	parse := NewParser()
+	stm, err := parse.Parse([]byte("select * from t1 cross join t2 natural left join t3 full outer join t4 on t1.id = t4.id;"))
+	assert.Nil(t, err)
+	joinTable := stm.(*SelectStm).TableReferences[0].TableReference.(JoinedTableStm)
+	assert.Len(t, joinTable.JoinFactors, 3)
+	assert.Equal(t, InnerJoin, joinTable.JoinFactors[0].JoinTp)
+	assert.Nil(t, joinTable.JoinFactors[0].JoinSpec)
+	assert.Equal(t, LeftOuterJoin, joinTable.JoinFactors[1].JoinTp)
+	assert.Equal(t, JoinSpecificationNatural, joinTable.JoinFactors[1].JoinSpec.Tp)
+	assert.Equal(t, FullOuterJoin, joinTable.JoinFactors[2].JoinTp)
+	assert.Equal(t, JoinSpecificationON, joinTable.JoinFactors[2].JoinSpec.Tp)
+
+	testSql(t, "select * from t1 natural join t2;")
+	testSql(t, "select * from t1 natural right outer join (select id from t2) a;")
+	testSql(t, "select * from t1 full join t2 using (id);")
+	testSqlFail(t, "select * from t1 full join t2;")
+	testSqlFail(t, "select * from t1 natural full join t2;")
+	testSqlFail(t, "select * from t1 natural join t2 on t1.id = t2.id;")
+}
+
+func TestParser_SelectSetOperation(t *testing.T) {
+	parse := NewParser()
+	stm, err := parse.Parse([]byte("select id from t1 union all select id from t2 intersect select id from t3 except distinct select id from t4 order by id limit 2;"))
+	assert.Nil(t, err)
+	selectStm := stm.(*SelectStm)
+	assert.Len(t, selectStm.SetOperations, 3)
+	assert.Equal(t, UnionTp, selectStm.SetOperations[0].Tp)
+	assert.True(t, selectStm.SetOperations[0].All)
+	assert.Equal(t, IntersectTp, selectStm.SetOperations[1].Tp)
+	assert.Equal(t, ExceptTp, selectStm.SetOperations[2].Tp)
+	assert.False(t, selectStm.SetOperations[2].All)
+	// The order by and limit apply to the whole result.
+	assert.NotNil(t, selectStm.OrderBy)
+	assert.NotNil(t, selectStm.LimitStm)
+	assert.Nil(t, selectStm.SetOperations[2].Select.OrderBy)
+	assert.Nil(t, selectStm.SetOperations[2].Select.LimitStm)
+
+	testSql(t, "select * from (select id from t1 union select id from t2) t where id in (select id from t3 except select id from t4);")
+	testSqlFail(t, "select id from t1 order by id union select id from t2;")
+	testSqlFail(t, "select id from t1 limit 1 union select id from t2;")
+	testSqlFail(t, "select id from t1 union;")
+	testSqlFail(t, "select id from t1 union all all select id from t2;")
+}
+
+func TestParser_SelectWith(t *testing.T) {
+	parse := NewParser()
+	stm, err := parse.Parse([]byte("with recursive c1 (a, b) as (select id, 1 from t1 union all select a + 1, b from c1), c2 as (select * from c1) select * from c2;"))
+	assert.Nil(t, err)
+	with := stm.(*SelectStm).With
+	assert.True(t, with.Recursive)
+	assert.Len(t, with.CTEs, 2)
+	assert.Equal(t, "c1", with.CTEs[0].Name)
+	assert.Equal(t, []string{"a", "b"}, with.CTEs[0].Cols)
+	assert.Len(t, with.CTEs[0].Select.SetOperations, 1)
+	assert.Nil(t, with.CTEs[1].Cols)
+
+	testSql(t, "with c as (select id from t1) select id from c union select id from t2;")
+	testSql(t, "select * from (with c as (select id from t1) select * from c) t where id in (with c as (select id from t2) select id from c);")
+	testSql(t, "insert into t1 with c as (select id from t2) select id from c;")
+	testSql(t, "explain with c as (select id from t1) select * from c;")
+	testSqlFail(t, "with c select id from t1;")
+	testSqlFail(t, "with c as select id from t1;")
+	testSqlFail(t, "with c () as (select id from t1) select * from c;")
+	testSqlFail(t, "with c as (select id from t1);")
 }
 
 func TestParser_Delete(t *testing.T) {
@@ -93,6 +184,37 @@ func TestParser_Insert(t *testing.T) {
 	testSql(t, sql)
 	sql = "insert into test values();"
 	testSqlFail(t, sql)
+	sql = "insert into test(c1, c2) values(10, 100), (11, 101), (12, 102);"
+	testSql(t, sql)
+	sql = "insert into test values(10, 100), ;"
+	testSqlFail(t, sql)
+	sql = "insert into test values(10, 100) (11, 101);"
+	testSqlFail(t, sql)
+	sql = "insert into test(c1, c2) select id, name from test1 where id > 1;"
+	testSql(t, sql)
+	sql = "insert into test select * from test1;"
+	testSql(t, sql)
+	sql = "insert into test select * from test1"
+	testSqlFail(t, sql)
+}
+
+func TestParser_Upsert(t *testing.T) {
+	sql := "insert into test(c1, c2) values(10, 100) on duplicate key update c2 = values(c2) + c2, c1 = 1;"
+	testSql(t, sql)
+	sql = "insert into test select * from test1 on duplicate key update c2 = values(c2);"
+	testSql(t, sql)
+	sql = "insert into test values(10, 100) on duplicate key update;"
+	testSqlFail(t, sql)
+	sql = "insert into test values(10, 100) on duplicate update c2 = 1;"
+	testSqlFail(t, sql)
+	sql = "insert into test values(10, 100) on duplicate key update c2 = values(1);"
+	testSqlFail(t, sql)
+	sql = "replace into test(c1, c2) values(10, 100), (11, 101);"
+	testSql(t, sql)
+	sql = "replace into test select * from test1;"
+	testSql(t, sql)
+	sql = "replace into test values(10, 100) on duplicate key update c2 = 1;"
+	testSqlFail(t, sql)
 }
 
 func TestParser_CreateDatabase(t *testing.T) {
@@ -108,6 +230,19 @@ func TestParser_CreateDatabase(t *testing.T) {
 	testSqlFail(t, sql)
 	sql = "create database db1 character set;"
 	testSqlFail(t, sql)
+	sql = "create database db1 default character set = utf8 default collate = utf8_general_ci;"
+	testSql(t, sql)
+}
+
+func TestParser_AlterDatabase(t *testing.T) {
+	sql := "alter database db1 character set = utf16 collate = utf16_general_ci;"
+	testSql(t, sql)
+	sql = "alter schema db1 default collate = utf8_general_ci;"
+	testSql(t, sql)
+	sql = "alter database db1 character set = utf16"
+	testSqlFail(t, sql)
+	sql = "alter database character set = utf16;"
+	testSqlFail(t, sql)
 }
 
 func TestParser_Truncate(t *testing.T) {
@@ -119,6 +254,33 @@ func TestParser_Truncate(t *testing.T) {
 	testSqlFail(t, sql)
 }
 
+func TestParser_ParseScript(t *testing.T) {
+	parse := NewParser()
+	stms, err := parse.ParseScript([]byte("use db1; create table t (id int);\ninsert into t values (1); select * from t;"))
+	assert.Nil(t, err)
+	assert.Len(t, stms, 4)
+	assert.IsType(t, &UseDatabaseStm{}, stms[0])
+	assert.IsType(t, &SelectStm{}, stms[3])
+	stms, err = parse.ParseScript([]byte("select * from t;"))
+	assert.Nil(t, err)
+	assert.Len(t, stms, 1)
+	_, err = parse.ParseScript([]byte("use db1; selec * from t;"))
+	assert.NotNil(t, err)
+	_, err = parse.ParseScript([]byte("use db1 select * from t;"))
+	assert.NotNil(t, err)
+	_, err = parse.ParseScript([]byte(" "))
+	assert.NotNil(t, err)
+	// Comments and CRLF line endings.
+	stms, err = parse.ParseScript([]byte("-- create a table\r\ncreate table t (id int); # no rows\r\n/* query it */ select * from t;\r\n"))
+	assert.Nil(t, err)
+	assert.Len(t, stms, 2)
+	_, err = parse.ParseScript([]byte("-- only comments\r\n/* nothing */"))
+	assert.NotNil(t, err)
+	// Parse only accepts one statement.
+	_, err = parse.Parse([]byte("use db1; use db2;"))
+	assert.NotNil(t, err)
+}
+
 func TestParser_Use(t *testing.T) {
 	sql := "use db1;"
 	testSql(t, sql)
@@ -133,6 +295,10 @@ func TestParser_Show(t *testing.T) {
 	testSql(t, sql)
 	sql = "show create table tb1;"
 	testSql(t, sql)
+	sql = "show create database db1;"
+	testSql(t, sql)
+	sql = "show create schema;"
+	testSqlFail(t, sql)
 }
 
 func TestParser_Rename(t *testing.T) {
@@ -164,3 +330,95 @@ func TestParser_Drop(t *testing.T) {
 	sql = "drop database if exists db1; t"
 	testSqlFail(t, sql)
 }
+
+func TestParser_KillAndSet(t *testing.T) {
+	sql := "kill query 1;"
+	testSql(t, sql)
+	sql = "kill query;"
+	testSqlFail(t, sql)
+	sql = "kill 1;"
+	testSqlFail(t, sql)
+	sql = "kill query -1;"
+	testSqlFail(t, sql)
+	sql = "set max_execution_time = 1000;"
+	testSql(t, sql)
+	sql = "set max_execution_time 1000;"
+	testSqlFail(t, sql)
+	sql = "set max_execution_time = ;"
+	testSqlFail(t, sql)
+}
+
+func TestParser_Explain(t *testing.T) {
+	sql := "explain select * from test1 where id > 1;"
+	testSql(t, sql)
+	sql = "explain format = json select * from test1 where id > 1;"
+	testSql(t, sql)
+	sql = "explain format = traditional select * from test1;"
+	testSql(t, sql)
+	sql = "explain format = xml select * from test1;"
+	testSqlFail(t, sql)
+	sql = "explain;"
+	testSqlFail(t, sql)
+	sql = "explain update test1 set id = 1;"
+	testSqlFail(t, sql)
+	sql = "explain analyze select * from test1 where id > 1;"
+	testSql(t, sql)
+	sql = "explain analyze format = json select * from test1;"
+	testSql(t, sql)
+	sql = "explain format = json analyze select * from test1;"
+	testSqlFail(t, sql)
+}
+
+func TestParser_AnalyzeTable(t *testing.T) {
+	sql := "analyze table test1;"
+	testSql(t, sql)
+	sql = "analyze table test1, db1.test2;"
+	testSql(t, sql)
+	sql = "analyze test1;"
+	testSqlFail(t, sql)
+	sql = "analyze table;"
+	testSqlFail(t, sql)
+	sql = "show stats test1;"
+	testSql(t, sql)
+	sql = "show stats;"
+	testSqlFail(t, sql)
+}
+
+func TestParser_AlterTable(t *testing.T) {
+	sql := "alter table test1 add column age int default 1;"
+	testSql(t, sql)
+	sql = "alter table test1 add name varchar(10) null;"
+	testSql(t, sql)
+	sql = "alter table test1 drop column age;"
+	testSql(t, sql)
+	sql = "alter test1 modify column age float;"
+	testSql(t, sql)
+	sql = "alter table test1 change age new_age bigint;"
+	testSql(t, sql)
+	sql = "alter table test1 add index idx_age (age, name);"
+	testSql(t, sql)
+	sql = "alter table test1 add unique key uk_age (age);"
+	testSql(t, sql)
+	sql = "alter table test1 add constraint primary key (id);"
+	testSql(t, sql)
+	sql = "alter table test1 drop index idx_age;"
+	testSql(t, sql)
+	sql = "alter table test1 drop primary key;"
+	testSql(t, sql)
+	sql = "alter table test1 drop foreign key fk_age;"
+	testSql(t, sql)
+	sql = "alter table test1 engine = memory;"
+	testSql(t, sql)
+	sql = "alter table test1 character set = utf8 collate = utf8_general_ci;"
+	testSql(t, sql)
+	sql = "alter table test1 default character set = utf16;"
+	testSql(t, sql)
+	sql = "alter table test1 add column;"
+	testSqlFail(t, sql)
+	sql = "alter table test1 add index idx_age age;"
+	testSqlFail(t, sql)
+	sql = "alter table test1 drop foreign fk_age;"
+	testSqlFail(t, sql)
+	sql = "alter table test1 drop column age"
+	testSqlFail(t, sql)
+}
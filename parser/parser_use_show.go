@@ -15,7 +15,7 @@ func (parser *Parser) resolveUseStm() (Stm, error) {
 	return &UseDatabaseStm{DatabaseName: string(databaseName)}, nil
 }
 
-// show databases | tables | create table tableName ;
+// show databases | tables | create table tableName | create {database|schema} dbName | stats tableName ;
 func (parser *Parser) resolveShowStm() (Stm, error) {
 	if !parser.matchTokenTypes(false, SHOW) {
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
@@ -31,6 +31,15 @@ func (parser *Parser) resolveShowStm() (Stm, error) {
 	case TABLES:
 		stm.TP = ShowTableTP
 	case CREATE:
+		if parser.matchTokenTypes(true, DATABASE) || parser.matchTokenTypes(true, SCHEMA) {
+			dbName, success := parser.parseIdentOrWord(false)
+			if !success {
+				return nil, parser.MakeSyntaxError(parser.pos - 1)
+			}
+			stm.TP = ShowCreateDatabaseTP
+			stm.Database = string(dbName)
+			break
+		}
 		// Show create table stm.
 		if !parser.matchTokenTypes(false, TABLE) {
 			return nil, parser.MakeSyntaxError(parser.pos - 1)
@@ -41,6 +50,13 @@ func (parser *Parser) resolveShowStm() (Stm, error) {
 		}
 		stm.TP = ShowCreateTableTP
 		stm.Table = string(tableName)
+	case STATS:
+		tableName, success := parser.parseIdentOrWord(false)
+		if !success {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		stm.TP = ShowStatsTP
+		stm.Table = string(tableName)
 	default:
 		return nil, parser.MakeSyntaxError(parser.pos - 1)
 	}
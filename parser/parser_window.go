@@ -0,0 +1,106 @@
+package parser
+
+// Window spec is like:
+// over ([partition by expression...] [OrderByStm] [frame])
+// where frame could be:
+// * {rows | range} frame_bound
+// * {rows | range} between frame_bound and frame_bound
+// and frame_bound could be:
+// unbounded preceding | n preceding | current row | n following | unbounded following
+
+// parseWindowSpec parses the over clause following a function call, it returns nil if there is none.
+func (parser *Parser) parseWindowSpec() (*WindowSpecStm, error) {
+	if !parser.matchTokenTypes(true, OVER) {
+		return nil, nil
+	}
+	if !parser.matchTokenTypes(false, LEFTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	spec := &WindowSpecStm{}
+	if parser.matchTokenTypes(true, PARTITION, BY) {
+		for {
+			expr, err := parser.resolveExpression()
+			if err != nil {
+				return nil, err
+			}
+			spec.PartitionBy = append(spec.PartitionBy, expr)
+			if !parser.matchTokenTypes(true, COMMA) {
+				break
+			}
+		}
+	}
+	orderBy, err := parser.ParseOrderByStm()
+	if err != nil {
+		return nil, err
+	}
+	spec.OrderBy = orderBy
+	spec.Frame, err = parser.parseWindowFrame()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.matchTokenTypes(false, RIGHTBRACKET) {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return spec, nil
+}
+
+func (parser *Parser) parseWindowFrame() (*WindowFrameStm, error) {
+	frame := &WindowFrameStm{}
+	switch {
+	case parser.matchTokenTypes(true, ROWS):
+		frame.Tp = RowsFrameTp
+	case parser.matchTokenTypes(true, RANGE):
+		frame.Tp = RangeFrameTp
+	default:
+		return nil, nil
+	}
+	var err error
+	if parser.matchTokenTypes(true, BETWEEN) {
+		frame.Start, err = parser.parseFrameBound()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.matchTokenTypes(false, AND) {
+			return nil, parser.MakeSyntaxError(parser.pos - 1)
+		}
+		frame.End, err = parser.parseFrameBound()
+	} else {
+		frame.Start, err = parser.parseFrameBound()
+		frame.End = FrameBoundStm{Tp: CurrentRowTp}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The frame cannot start after it ends.
+	if frame.Start.Tp == UnboundedFollowingTp || frame.End.Tp == UnboundedPrecedingTp || frame.Start.Tp > frame.End.Tp {
+		return nil, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	return frame, nil
+}
+
+func (parser *Parser) parseFrameBound() (FrameBoundStm, error) {
+	if parser.matchTokenTypes(true, UNBOUNDED, PRECEDING) {
+		return FrameBoundStm{Tp: UnboundedPrecedingTp}, nil
+	}
+	if parser.matchTokenTypes(true, UNBOUNDED, FOLLOWING) {
+		return FrameBoundStm{Tp: UnboundedFollowingTp}, nil
+	}
+	if parser.matchTokenTypes(true, CURRENT, ROW) {
+		return FrameBoundStm{Tp: CurrentRowTp}, nil
+	}
+	ret, ok := parser.parseValue(false)
+	if !ok {
+		return FrameBoundStm{}, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	offset, ok := DecodeInt(ret)
+	if !ok || offset < 0 {
+		return FrameBoundStm{}, parser.MakeSyntaxError(parser.pos - 1)
+	}
+	switch {
+	case parser.matchTokenTypes(true, PRECEDING):
+		return FrameBoundStm{Tp: PrecedingTp, Offset: offset}, nil
+	case parser.matchTokenTypes(true, FOLLOWING):
+		return FrameBoundStm{Tp: FollowingTp, Offset: offset}, nil
+	}
+	return FrameBoundStm{}, parser.MakeSyntaxError(parser.pos - 1)
+}
@@ -258,24 +258,31 @@ type AlterDatabaseStm struct {
 
 // Second DML
 // Insert statement is like:
-// * insert into tb_name [( col_name,... )] values (expression,...)
+// * insert into tb_name [( col_name,... )] values (expression,...) [, (expression,...)...]
+// * insert into tb_name [( col_name,... )] select_statement
 type InsertIntoStm struct {
 	TableName string
 	Cols      []string
-	Values    []*ExpressionStm
+	Values    [][]*ExpressionStm // One expression list per row.
+	Select    *SelectStm         // Not nil if the rows are from a select statement.
+	Replace   bool               // True for replace into, the old rows having duplicate keys are deleted.
+	// The assignments of on duplicate key update.
+	OnDuplicateUpdate []*AssignmentStm
 }
 
 // For expression, compared to mysql, we use a simplified version and only a subset expressions of mysql
 // are supported. An expression statement is like:
 // term (ope term)
 // a term can be:
-// * literal | (expr) | identifier | functionCall |
+// * literal | ? | (expr) | identifier | functionCall | caseExpr | (select ...) | [NOT] EXISTS (select ...)
 // where functionCall is like:
 // funcName(expr,...)
+// and caseExpr is like:
+// case [value] when expr then expr [when expr then expr...] [else expr] end
 // where ope supports:
 // +, -, *, /, %, =, IS, !=, IS NOT, >, >=, <, <=, AND, OR,
-// Note: currently we don't consider [NOT] IN, [NOT] LIKE
-// Note: literal can be -5
+// [NOT] IN (expr, ...), [NOT] IN (select ...), [NOT] BETWEEN expr AND expr, [NOT] LIKE expr [ESCAPE expr]
+// Note: literal can be -5, and a term can be NOT expr.
 type ExpressionStm struct {
 	LeftExpr  interface{}   `json:"left"` // can be ExpressionTerm or ExpressionStm
 	Op        *ExpressionOp `json:"op"`
@@ -285,7 +292,7 @@ type ExpressionStm struct {
 type ExpressionTerm struct {
 	UnaryOp      UnaryOpTp        `json:"unary"`
 	Tp           ExpressionTermTP `json:"tp"`
-	RealExprTerm interface{}      `json:"real_expr"` // can be LiteralExpressionStm, IdentifierExpression, FunctionCallExpressionStm, SubExpressionTerm
+	RealExprTerm interface{}      `json:"real_expr"` // can be LiteralExpressionStm, IdentifierExpression, FunctionCallExpressionStm, SubExpressionTerm, ExpressionListStm, CaseExpressionStm, SubQueryStm, ExistsSubQueryExpressionStm, ParamMarkerStm
 }
 
 type UnaryOpTp byte
@@ -293,6 +300,7 @@ type UnaryOpTp byte
 const (
 	NoneUnaryOpTp UnaryOpTp = iota
 	NegativeUnaryOpTp
+	NotUnaryOpTp
 )
 
 // Todo.
@@ -308,7 +316,12 @@ const (
 	SubExpressionTermTP
 	IdentifierExpressionTermTP // should be column
 	FuncCallExpressionTermTP
-	AllExpressionTermTP // for count(*)
+	AllExpressionTermTP  // for count(*)
+	ListExpressionTermTP // The right operand of in, between and like.
+	CaseExpressionTermTP
+	SubQueryExpressionTermTP       // (select ...), also the right operand of [not] in (select ...).
+	ExistsSubQueryExpressionTermTP // [not] exists (select ...)
+	ParamExpressionTermTP          // ? placeholder of prepared statements.
 )
 
 type ExpressionOp struct {
@@ -332,7 +345,13 @@ var (
 	OperationLessEqual  = &ExpressionOp{Tp: LESSEQUAL, Priority: 1, Name: "<="}
 	OperationAnd        = &ExpressionOp{Tp: AND, Priority: 0, Name: "and"}
 	OperationOr         = &ExpressionOp{Tp: OR, Priority: 0, Name: "or"}
-	// OperationISNot      ExpressionOp = ExpressionOp{Tp: lexer.OR + 1, Priority: 1}
+	OperationIsNot      = &ExpressionOp{Tp: ISNOT, Priority: 1, Name: "is not"}
+	OperationIn         = &ExpressionOp{Tp: IN, Priority: 1, Name: "in"}
+	OperationNotIn      = &ExpressionOp{Tp: NOTIN, Priority: 1, Name: "not in"}
+	OperationBetween    = &ExpressionOp{Tp: BETWEEN, Priority: 1, Name: "between"}
+	OperationNotBetween = &ExpressionOp{Tp: NOTBETWEEN, Priority: 1, Name: "not between"}
+	OperationLike       = &ExpressionOp{Tp: LIKE, Priority: 1, Name: "like"}
+	OperationNotLike    = &ExpressionOp{Tp: NOTLIKE, Priority: 1, Name: "not like"}
 	// OperationDot ExpressionOp = ExpressionOp{Tp: lexer.DOT, Priority: 2}
 )
 
@@ -354,6 +373,12 @@ var (
 //	Variable *ExpressionStm
 //}
 
+// ExpressionListStm is the right operand of:
+// * [not] in (expr, ...): the values.
+// * [not] between low and high: low and high.
+// * [not] like pattern [escape escape_char]: the pattern and the optional escape char.
+type ExpressionListStm []*ExpressionStm
+
 type LiteralExpressionStm ColumnValue
 
 func (stm LiteralExpressionStm) MarshalJSON() ([]byte, error) {
@@ -361,6 +386,10 @@ func (stm LiteralExpressionStm) MarshalJSON() ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// ParamMarkerStm is a ? placeholder of prepared statements, it's the index of the placeholder in
+// the statement, counted from 0.
+type ParamMarkerStm int
+
 type IdentifierExpression []byte
 
 func (ident IdentifierExpression) MarshalJSON() ([]byte, error) {
@@ -371,16 +400,71 @@ func (ident IdentifierExpression) MarshalJSON() ([]byte, error) {
 // Name(params...)
 type FunctionCallExpressionStm struct {
 	FuncName string
+	Distinct bool // Like count(distinct id).
 	Params   []*ExpressionStm
+	Over     *WindowSpecStm // Not nil for window functions.
+}
+
+// WindowSpecStm is the over clause of a window function, like:
+// over ([partition by expr...] [OrderByStm] [{rows | range} {frame_start | between frame_start and frame_end}])
+type WindowSpecStm struct {
+	PartitionBy []*ExpressionStm
+	OrderBy     *OrderByStm
+	Frame       *WindowFrameStm // nil for the default frame.
+}
+
+type WindowFrameTp byte
+
+const (
+	RowsFrameTp WindowFrameTp = iota
+	RangeFrameTp
+)
+
+// WindowFrameStm is the rows of a partition used by a window function for the current row.
+// A frame with only start ends at the current row.
+type WindowFrameStm struct {
+	Tp    WindowFrameTp
+	Start FrameBoundStm
+	End   FrameBoundStm
+}
+
+type FrameBoundTp byte
+
+// In the order of position.
+const (
+	UnboundedPrecedingTp FrameBoundTp = iota
+	PrecedingTp
+	CurrentRowTp
+	FollowingTp
+	UnboundedFollowingTp
+)
+
+// FrameBoundStm is like: unbounded preceding | n preceding | current row | n following | unbounded following.
+type FrameBoundStm struct {
+	Tp     FrameBoundTp
+	Offset int
 }
 
 type SubExpressionTerm ExpressionTerm
 
+// CaseExpressionStm is like:
+// * case value when expr then expr [when expr then expr...] [else expr] end
+// * case when condition then expr [when condition then expr...] [else expr] end
+// Value is nil for the second one, and Else is nil if not given.
+type CaseExpressionStm struct {
+	Value *ExpressionStm
+	Whens []*ExpressionStm
+	Thens []*ExpressionStm
+	Else  *ExpressionStm
+}
+
+// ExistsSubQueryExpressionStm is [not] exists (select ...), Exists is false for not exists.
 type ExistsSubQueryExpressionStm struct {
 	Exists   bool
 	SubQuery SubQueryStm
 }
 
+// SubQueryStm is a select used as an expression.
 type SubQueryStm *SelectStm
 
 // Update statement is like:
@@ -403,15 +487,18 @@ type MultiUpdateStm struct {
 // A table reference statement is like:
 // table_factor | joined_table
 // where table_factor can be:
-// * {tb_name [as alias] | (table_subquery) as alias} | (tableRef)
+// * {tb_name [as alias] | (table_subquery) [as] alias} | (tableRef)
 // and joined_table is like:
-// * table_factor { {left|right} [outer] join table_reference join_specification | inner join table_factor [join_specification] } *
+// * table_factor { {left|right|full} [outer] join table_factor join_specification | [inner|cross] join table_factor [join_specification]
+//   | natural [{left|right} [outer]] join table_factor } *
 // join_specification is like:
 // on where_condition | using (col...)
 
 // Diff with mysql
 // * index_hint are not supported.
-// * cross join, straight join and natural join keywords are not supported.
+// * straight join is not supported.
+// * full [outer] join is supported.
+// * the right side of a join is a table factor, nested joins need parentheses like t1 join (t2 join t3 on ...).
 type TableReferenceStm struct {
 	Tp TableReferenceType
 	// Can be JoinedTableStm or TableFactorStm
@@ -444,7 +531,8 @@ type TableReferencePureTableRefStm struct {
 }
 
 // where joined_table is like:
-// * table_factor { {left|right} [outer] join table_reference join_specification | inner join table_factor [join_specification] } *
+// * table_factor { {left|right|full} [outer] join table_factor join_specification | [inner|cross] join table_factor [join_specification]
+//   | natural [{left|right} [outer]] join table_factor } *
 // join_specification is like:
 // on where_condition | using (col...)
 
@@ -465,10 +553,13 @@ const (
 	LeftOuterJoin JoinType = iota
 	RightOuterJoin
 	InnerJoin
+	FullOuterJoin
 )
 
 // join_specification is like:
 // on where_condition | using (col,...)
+// A natural join has a JoinSpecificationNatural specification with a nil condition, its tables
+// are joined by the columns having the same names.
 type JoinSpecification struct {
 	Tp        JoinSpecificationTp
 	Condition interface{}
@@ -479,6 +570,7 @@ type JoinSpecificationTp byte
 const (
 	JoinSpecificationON JoinSpecificationTp = iota
 	JoinSpecificationUsing
+	JoinSpecificationNatural
 )
 
 type TableSubQueryStm struct {
@@ -526,6 +618,8 @@ type MultiDeleteStm struct {
 // Select statement is like:
 // * select [all | distinct | distinctrow] select_expression... from table_reference... [WhereStm] [GroupByStm] [HavingStm]
 // [OrderByStm] [LimitStm] [for update | lock in share mode]
+// * select ... {union | intersect | except} [all | distinct] select ... [OrderByStm] [LimitStm]
+// With set operations, OrderBy and LimitStm apply to the whole result.
 type SelectStm struct {
 	Tp                SelectTp
 	SelectExpressions *SelectExpressionStm
@@ -536,6 +630,38 @@ type SelectStm struct {
 	Having            HavingStm
 	LimitStm          *LimitStm
 	LockTp            SelectLockTp
+	SetOperations     []SetOperationStm
+	With              *WithStm // The common table expressions visible to the whole statement.
+}
+
+// WithStm is like: with [recursive] cte [, cte...].
+type WithStm struct {
+	Recursive bool
+	CTEs      []CommonTableExprStm
+}
+
+// CommonTableExprStm is like: name [(col_name...)] as (select ...). The columns are named by Cols
+// if given.
+type CommonTableExprStm struct {
+	Name   string
+	Cols   []string
+	Select *SelectStm
+}
+
+type SetOperationTp byte
+
+const (
+	UnionTp SetOperationTp = iota
+	IntersectTp
+	ExceptTp
+)
+
+// SetOperationStm combines the result so far with the result of Select, like union all select ....
+// The Select doesn't have order by and limit.
+type SetOperationStm struct {
+	Tp     SetOperationTp
+	All    bool
+	Select *SelectStm
 }
 
 // selectExpr can use alias.
@@ -589,12 +715,64 @@ const (
 	ShowTableTP ShowStmTp = iota
 	ShowDatabaseTP
 	ShowCreateTableTP
+	ShowStatsTP
+	ShowCreateDatabaseTP
 )
 
-// show tables, show databases, show create table table_name
+// show tables, show databases, show create table table_name, show create database db_name,
+// show stats table_name
 type ShowStm struct {
-	TP    ShowStmTp
-	Table string
+	TP       ShowStmTp
+	Table    string
+	Database string
+}
+
+// kill query connection_id;
+type KillStm struct {
+	ConnectionID uint32
+}
+
+// set variable_name = value;
+type SetVariableStm struct {
+	Name  string
+	Value []byte
+}
+
+// analyze table tb_name[,tb_name...];
+type AnalyzeTableStm struct {
+	TableNames []string
+}
+
+// prepare stm_name from 'statement';
+type PrepareStm struct {
+	Name  string
+	Query []byte // The statement without quotes.
+}
+
+// execute stm_name [using expr [, expr...]];
+type ExecuteStm struct {
+	Name   string
+	Params []*ExpressionStm
+}
+
+// {deallocate | drop} prepare stm_name;
+type DeallocatePrepareStm struct {
+	Name string
+}
+
+type ExplainFormatTp byte
+
+const (
+	ExplainTraditionalFormat ExplainFormatTp = iota
+	ExplainJSONFormat
+)
+
+// explain [analyze] [format = {traditional | json}] select_statement;
+// With analyze, the select statement is executed to collect runtime statistics.
+type ExplainStm struct {
+	Analyze bool
+	Format  ExplainFormatTp
+	Select  *SelectStm
 }
 
 type TransStm string
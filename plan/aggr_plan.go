@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/xiaobogaga/minidb/parser"
@@ -86,9 +87,9 @@ func (groupBy *GroupByPlan) TypeCheck() error {
 	return nil
 }
 
-func (groupBy *GroupByPlan) Execute() *storage.RecordBatch {
+func (groupBy *GroupByPlan) Execute(ctx context.Context) *storage.RecordBatch {
 	if groupBy.data == nil {
-		groupBy.InitializeData()
+		groupBy.InitializeData(ctx)
 	}
 	ret := groupBy.retData.Slice(groupBy.index, batchSize)
 	groupBy.index += batchSize
@@ -98,7 +99,7 @@ func (groupBy *GroupByPlan) Execute() *storage.RecordBatch {
 // GroupBy.
 // |---|---|---|  group by col1, col2.
 // |---|---|---|           |----|----|
-func (groupBy *GroupByPlan) InitializeData() {
+func (groupBy *GroupByPlan) InitializeData(ctx context.Context) {
 	if groupBy.data != nil {
 		return
 	}
@@ -114,7 +115,7 @@ func (groupBy *GroupByPlan) InitializeData() {
 		groupBy.keys.Records[i] = &storage.ColumnVector{Field: f}
 	}
 	for {
-		batch := groupBy.Input.Execute()
+		batch := executePlan(ctx, groupBy.Input)
 		if batch == nil {
 			break
 		}
@@ -197,10 +198,10 @@ func (having *HavingPlan) TypeCheck() error {
 	return having.Expr.AggrTypeCheck(having.Input.GroupByExpr)
 }
 
-func (having *HavingPlan) Execute() (ret *storage.RecordBatch) {
+func (having *HavingPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
 	i := 0
 	for i < batchSize {
-		recordBatch := having.Input.Execute()
+		recordBatch := executePlan(ctx, having.Input)
 		if recordBatch == nil {
 			return
 		}
@@ -219,44 +220,50 @@ func (having *HavingPlan) Reset() {
 	having.Input.Reset()
 }
 
-func MakeAggrePlan(input Plan, ast *parser.SelectStm) (Plan, error) {
-	groupByPlan := makeGroupByPlan(input, ast.Groupby, ast.SelectExpressions)
+func MakeAggrePlan(input Plan, ast *parser.SelectStm, scope *queryScope) (Plan, error) {
+	groupByPlan := makeGroupByPlan(input, ast.Groupby, ast.SelectExpressions, scope)
+	// The schema of groupByPlan is needed to find the outer columns of subqueries in having.
+	err := groupByPlan.TypeCheck()
+	if err != nil {
+		return nil, err
+	}
 	// Having similar to projections for aggregation, the Expr must be either included in the group by Expr.
 	// or must be an aggregation function.
-	havingPlan := makeHavingPlan(groupByPlan, ast.Having)
+	havingPlan := makeHavingPlan(groupByPlan, ast.Having, scope)
 	// Order by similar to projections for aggregation, the Expr must be either included in the group by Expr,
 	// or must be an aggregation function.
-	orderByPlan := makeOrderByPlan(havingPlan, ast.OrderBy, true)
-	limitPlan := makeLimitPlan(orderByPlan, ast.LimitStm)
+	orderByPlan := makeOrderByPlan(havingPlan, ast.OrderBy, true, scope)
+	distinctPlan := makeDistinctPlan(orderByPlan, ast.Tp)
+	limitPlan := makeLimitPlan(distinctPlan, ast.LimitStm)
 	return limitPlan, limitPlan.TypeCheck()
 }
 
-func makeHavingPlan(input *GroupByPlan, having parser.HavingStm) Plan {
+func makeHavingPlan(input *GroupByPlan, having parser.HavingStm, scope *queryScope) Plan {
 	if having == nil {
 		return input
 	}
 	return &HavingPlan{
 		Input: input,
-		Expr:  ExprStmToExpr(having, input),
+		Expr:  ExprStmToExpr(having, input, scope),
 	}
 }
 
-func makeGroupByPlan(input Plan, groupBy *parser.GroupByStm, selectExprStm *parser.SelectExpressionStm) *GroupByPlan {
+func makeGroupByPlan(input Plan, groupBy *parser.GroupByStm, selectExprStm *parser.SelectExpressionStm, scope *queryScope) *GroupByPlan {
 	ret := &GroupByPlan{
 		Input:       input,
-		GroupByExpr: ExprStmsToExprs(*groupBy, input),
+		GroupByExpr: ExprStmsToExprs(*groupBy, input, scope),
 	}
 	switch selectExprStm.Tp {
 	case parser.StarSelectExpressionTp:
 	case parser.ExprSelectExpressionTp:
-		ret.AggrExprs = SelectExprToAsExpr(selectExprStm.Expr.([]*parser.SelectExpr), input)
+		ret.AggrExprs = SelectExprToAsExpr(selectExprStm.Expr.([]*parser.SelectExpr), input, scope)
 	}
 	return ret
 }
 
-func ExprStmsToExprs(expressions []*parser.ExpressionStm, input Plan) (ret []Expr) {
+func ExprStmsToExprs(expressions []*parser.ExpressionStm, input Plan, scope *queryScope) (ret []Expr) {
 	for _, expr := range expressions {
-		ret = append(ret, ExprStmToExpr(expr, input))
+		ret = append(ret, ExprStmToExpr(expr, input, scope))
 	}
 	return ret
 }
@@ -0,0 +1,431 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/storage"
+	"github.com/xiaobogaga/minidb/util"
+	"strings"
+)
+
+// branchType returns the result type of branches. Numerical branches are int if all of them are
+// int, otherwise float. String branches keep their type if all of them have the same type,
+// otherwise text.
+func branchType(tps []storage.FieldTP) (storage.FieldTP, error) {
+	if len(tps) == 0 {
+		return storage.FieldTP{}, errors.New("no branch")
+	}
+	ret := storage.Field{TP: tps[0]}
+	for _, tp := range tps[1:] {
+		field := storage.Field{TP: tp}
+		// Null branches take the type of the others.
+		if field.IsNull() {
+			continue
+		}
+		if ret.IsNull() {
+			ret.TP = tp
+			continue
+		}
+		if ret.CanOp(field, storage.EqualOpType) != nil {
+			return storage.FieldTP{}, errors.New(fmt.Sprintf("branch type %s doesn't match %s", tp.Name, ret.TP.Name))
+		}
+		switch {
+		case ret.TP.Name == tp.Name:
+			ret.TP.Range[0] = util.Max(ret.TP.Range[0], tp.Range[0])
+			ret.TP.Range[1] = util.Max(ret.TP.Range[1], tp.Range[1])
+		case ret.IsInteger():
+			// The other one must be float.
+			ret.TP = tp
+		case ret.IsFloat():
+		default:
+			ret.TP = storage.DefaultFieldTpMap[storage.Text]
+		}
+	}
+	return ret.TP, nil
+}
+
+// typeCheckBranches type checks params and the branches among them.
+func typeCheckBranches(params []Expr, branches []Expr) error {
+	for _, param := range params {
+		err := param.TypeCheck()
+		if err != nil {
+			return err
+		}
+	}
+	_, err := branchType(paramTypes(branches))
+	return err
+}
+
+// convertBranch converts the value of a branch to the result type.
+func convertBranch(value []byte, from storage.FieldTP, to storage.FieldTP) []byte {
+	if from.Name == to.Name {
+		return value
+	}
+	ret, _ := storage.ConvertValue(value, from, storage.Field{TP: to, AllowNull: true})
+	return ret
+}
+
+// isTrue returns true if value is a true bool, null is not true.
+func isTrue(value []byte) bool {
+	return len(value) > 0 && storage.DecodeBool(value)
+}
+
+func funcCallString(funcName string, params []Expr) string {
+	values := make([]string, len(params))
+	for i, param := range params {
+		values[i] = param.String()
+	}
+	return fmt.Sprintf("%s(%s)", funcName, strings.Join(values, ","))
+}
+
+// CaseExpr is like:
+// * case value when expr then expr [when expr then expr...] [else expr] end
+// * case when condition then expr [when condition then expr...] [else expr] end
+// Value is nil for the second one, and Else is nil if not given. The result is null if no
+// branch matches.
+type CaseExpr struct {
+	Value Expr
+	Whens []Expr
+	Thens []Expr
+	Else  Expr
+	Name  string
+}
+
+func (c CaseExpr) params() []Expr {
+	var ret []Expr
+	if c.Value != nil {
+		ret = append(ret, c.Value)
+	}
+	ret = append(ret, c.Whens...)
+	return append(ret, c.branches()...)
+}
+
+func (c CaseExpr) branches() []Expr {
+	ret := append([]Expr{}, c.Thens...)
+	if c.Else != nil {
+		ret = append(ret, c.Else)
+	}
+	return ret
+}
+
+func (c CaseExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	whens := 0
+	if c.Value != nil {
+		whens = 1
+	}
+	thens := whens + len(c.Whens)
+	tp, _ := branchType(tps[thens:])
+	for i := range c.Whens {
+		when := values[whens+i]
+		matched := isTrue(when)
+		if c.Value != nil {
+			matched = len(values[0]) > 0 && len(when) > 0 &&
+				storage.DecodeBool(storage.Equal(values[0], tps[0], when, tps[whens+i]))
+		}
+		if matched {
+			return convertBranch(values[thens+i], tps[thens+i], tp)
+		}
+	}
+	if c.Else == nil {
+		return nil
+	}
+	last := len(values) - 1
+	return convertBranch(values[last], tps[last], tp)
+}
+
+func (c CaseExpr) toField() storage.Field {
+	tp, _ := branchType(paramTypes(c.branches()))
+	return storage.Field{Name: c.String(), TP: tp}
+}
+
+func (c CaseExpr) String() string {
+	bf := strings.Builder{}
+	bf.WriteString("case ")
+	if c.Value != nil {
+		bf.WriteString(c.Value.String() + " ")
+	}
+	for i := range c.Whens {
+		bf.WriteString(fmt.Sprintf("when %s then %s ", c.Whens[i], c.Thens[i]))
+	}
+	if c.Else != nil {
+		bf.WriteString(fmt.Sprintf("else %s ", c.Else))
+	}
+	bf.WriteString("end")
+	return bf.String()
+}
+
+func (c CaseExpr) TypeCheck() error {
+	err := typeCheckBranches(c.params(), c.branches())
+	if err != nil {
+		return err
+	}
+	for _, when := range c.Whens {
+		field := when.toField()
+		if c.Value != nil {
+			err = c.Value.toField().CanOp(field, storage.EqualOpType)
+		} else if !field.IsBool() {
+			err = errors.New(fmt.Sprintf("case condition %s must be bool", when))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c CaseExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(c, input)
+}
+
+func (c CaseExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(c, row, input)
+}
+
+func (c CaseExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(c, groupByExpr)
+}
+
+func (c CaseExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(c, row, input)
+}
+
+func (c CaseExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(c)
+}
+
+func (c CaseExpr) Clone(cloneAccumulator bool) Expr {
+	ret := CaseExpr{
+		Whens: cloneExprs(c.Whens, cloneAccumulator),
+		Thens: cloneExprs(c.Thens, cloneAccumulator),
+		Name:  c.Name,
+	}
+	if c.Value != nil {
+		ret.Value = c.Value.Clone(cloneAccumulator)
+	}
+	if c.Else != nil {
+		ret.Else = c.Else.Clone(cloneAccumulator)
+	}
+	return ret
+}
+
+func (c CaseExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(c)
+}
+
+func (c CaseExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(c)
+}
+
+// IfExpr is like if(condition, expr1, expr2), it's expr1 if condition is true, otherwise expr2.
+type IfExpr struct {
+	FuncName string
+	Params   []Expr
+}
+
+func (i IfExpr) params() []Expr {
+	return i.Params
+}
+
+func (i IfExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	tp, _ := branchType(tps[1:])
+	if isTrue(values[0]) {
+		return convertBranch(values[1], tps[1], tp)
+	}
+	return convertBranch(values[2], tps[2], tp)
+}
+
+func (i IfExpr) toField() storage.Field {
+	if len(i.Params) != 3 {
+		return storage.Field{Name: i.String()}
+	}
+	tp, _ := branchType(paramTypes(i.Params[1:]))
+	return storage.Field{Name: i.String(), TP: tp}
+}
+
+func (i IfExpr) String() string {
+	return funcCallString(i.FuncName, i.Params)
+}
+
+func (i IfExpr) TypeCheck() error {
+	if len(i.Params) != 3 {
+		return errors.New(fmt.Sprintf("%s: param size doesn't match", i))
+	}
+	err := typeCheckBranches(i.Params, i.Params[1:])
+	if err != nil {
+		return err
+	}
+	if !i.Params[0].toField().IsBool() {
+		return errors.New(fmt.Sprintf("%s: condition must be bool", i))
+	}
+	return nil
+}
+
+func (i IfExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(i, input)
+}
+
+func (i IfExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(i, row, input)
+}
+
+func (i IfExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(i, groupByExpr)
+}
+
+func (i IfExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(i, row, input)
+}
+
+func (i IfExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(i)
+}
+
+func (i IfExpr) Clone(cloneAccumulator bool) Expr {
+	return IfExpr{FuncName: i.FuncName, Params: cloneExprs(i.Params, cloneAccumulator)}
+}
+
+func (i IfExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(i)
+}
+
+func (i IfExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(i)
+}
+
+// CoalesceExpr is like coalesce(expr, ...), it's the first param which isn't null. And
+// ifnull(expr1, expr2) is a coalesce having two params.
+type CoalesceExpr struct {
+	FuncName string
+	Params   []Expr
+}
+
+func (coalesce CoalesceExpr) params() []Expr {
+	return coalesce.Params
+}
+
+func (coalesce CoalesceExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	tp, _ := branchType(tps)
+	for i, value := range values {
+		if len(value) > 0 {
+			return convertBranch(value, tps[i], tp)
+		}
+	}
+	return nil
+}
+
+func (coalesce CoalesceExpr) toField() storage.Field {
+	tp, _ := branchType(paramTypes(coalesce.Params))
+	return storage.Field{Name: coalesce.String(), TP: tp}
+}
+
+func (coalesce CoalesceExpr) String() string {
+	return funcCallString(coalesce.FuncName, coalesce.Params)
+}
+
+func (coalesce CoalesceExpr) TypeCheck() error {
+	if len(coalesce.Params) == 0 ||
+		(strings.ToUpper(coalesce.FuncName) == "IFNULL" && len(coalesce.Params) != 2) {
+		return errors.New(fmt.Sprintf("%s: param size doesn't match", coalesce))
+	}
+	return typeCheckBranches(coalesce.Params, coalesce.Params)
+}
+
+func (coalesce CoalesceExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(coalesce, input)
+}
+
+func (coalesce CoalesceExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(coalesce, row, input)
+}
+
+func (coalesce CoalesceExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(coalesce, groupByExpr)
+}
+
+func (coalesce CoalesceExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(coalesce, row, input)
+}
+
+func (coalesce CoalesceExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(coalesce)
+}
+
+func (coalesce CoalesceExpr) Clone(cloneAccumulator bool) Expr {
+	return CoalesceExpr{FuncName: coalesce.FuncName, Params: cloneExprs(coalesce.Params, cloneAccumulator)}
+}
+
+func (coalesce CoalesceExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(coalesce)
+}
+
+func (coalesce CoalesceExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(coalesce)
+}
+
+// NullIfExpr is like nullif(expr1, expr2), it's null if expr1 = expr2, otherwise expr1.
+type NullIfExpr struct {
+	FuncName string
+	Params   []Expr
+}
+
+func (nullIf NullIfExpr) params() []Expr {
+	return nullIf.Params
+}
+
+func (nullIf NullIfExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	if len(values[0]) > 0 && len(values[1]) > 0 &&
+		storage.DecodeBool(storage.Equal(values[0], tps[0], values[1], tps[1])) {
+		return nil
+	}
+	return values[0]
+}
+
+func (nullIf NullIfExpr) toField() storage.Field {
+	if len(nullIf.Params) != 2 {
+		return storage.Field{Name: nullIf.String()}
+	}
+	return storage.Field{Name: nullIf.String(), TP: nullIf.Params[0].toField().TP}
+}
+
+func (nullIf NullIfExpr) String() string {
+	return funcCallString(nullIf.FuncName, nullIf.Params)
+}
+
+func (nullIf NullIfExpr) TypeCheck() error {
+	if len(nullIf.Params) != 2 {
+		return errors.New(fmt.Sprintf("%s: param size doesn't match", nullIf))
+	}
+	return typeCheckOp(nullIf.Params[0], nullIf.Params[1:], storage.EqualOpType)
+}
+
+func (nullIf NullIfExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(nullIf, input)
+}
+
+func (nullIf NullIfExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(nullIf, row, input)
+}
+
+func (nullIf NullIfExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(nullIf, groupByExpr)
+}
+
+func (nullIf NullIfExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(nullIf, row, input)
+}
+
+func (nullIf NullIfExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(nullIf)
+}
+
+func (nullIf NullIfExpr) Clone(cloneAccumulator bool) Expr {
+	return NullIfExpr{FuncName: nullIf.FuncName, Params: cloneExprs(nullIf.Params, cloneAccumulator)}
+}
+
+func (nullIf NullIfExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(nullIf)
+}
+
+func (nullIf NullIfExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(nullIf)
+}
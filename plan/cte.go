@@ -0,0 +1,261 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+)
+
+// cteMaxRecursionDepth limits the iterations of a recursive common table expression.
+var cteMaxRecursionDepth = 1000
+
+// cteDef is a common table expression defined by with. The ctes visible to a query block are
+// linked by prev, a cte can refer to the ctes defined before it, and itself if it's recursive.
+type cteDef struct {
+	parser.CommonTableExprStm
+	Recursive bool
+	prev      *cteDef
+	// Not nil when the recursive select of the cte is being planned, the references of the cte
+	// in it read the rows of the last iteration.
+	anchor     Plan
+	workTables []*WorkTablePlan
+}
+
+// find returns the cte named name, it returns nil if there is no such cte.
+func (cte *cteDef) find(name string) *cteDef {
+	for ; cte != nil; cte = cte.prev {
+		if cte.Name == name {
+			return cte
+		}
+	}
+	return nil
+}
+
+// makeWithPlan makes the plan of with ... select ..., the ctes are visible to the select and its
+// subqueries.
+func makeWithPlan(ast *parser.SelectStm, scope *queryScope) (Plan, error) {
+	ctes := scope.CTEs
+	defer func() { scope.CTEs = ctes }()
+	for _, stm := range ast.With.CTEs {
+		for cte := scope.CTEs; cte != ctes; cte = cte.prev {
+			if cte.Name == stm.Name {
+				return nil, errors.New(fmt.Sprintf("duplicate cte name '%s'", stm.Name))
+			}
+		}
+		scope.CTEs = &cteDef{CommonTableExprStm: stm, Recursive: ast.With.Recursive, prev: scope.CTEs}
+	}
+	body := *ast
+	body.With = nil
+	return makeQueryPlan(&body, scope)
+}
+
+// makeCTEPlan makes the plan of a reference to cte. A cte is planned for every reference like
+// a derived table.
+func makeCTEPlan(cte *cteDef, outer *queryScope) (Plan, error) {
+	if cte.anchor != nil {
+		// A reference in its recursive select.
+		workTable := &WorkTablePlan{Name: cte.Name, anchor: cte.anchor}
+		cte.workTables = append(cte.workTables, workTable)
+		return workTable, nil
+	}
+	scope := &queryScope{CurrentDB: outer.CurrentDB, CTEs: cte.prev, Params: outer.Params, prepared: outer.prepared}
+	if !cte.Recursive || len(cte.Select.SetOperations) == 0 {
+		return makeQueryPlan(cte.Select, scope)
+	}
+	ops := cte.Select.SetOperations
+	if len(ops) != 1 || ops[0].Tp != parser.UnionTp || cte.Select.OrderBy != nil || cte.Select.LimitStm != nil {
+		return nil, errors.New(fmt.Sprintf("recursive cte '%s' must be like: select ... union [all] select ...", cte.Name))
+	}
+	anchorStm := *cte.Select
+	anchorStm.SetOperations = nil
+	anchor, err := makeQueryPlan(&anchorStm, scope)
+	if err != nil {
+		return nil, err
+	}
+	cte.anchor, scope.CTEs = anchor, cte
+	recursive, err := makeQueryPlan(ops[0].Select, scope)
+	workTables := cte.workTables
+	cte.anchor, cte.workTables = nil, nil
+	if err != nil {
+		return nil, err
+	}
+	if len(workTables) == 0 {
+		// The cte doesn't refer to itself.
+		return &SetOperationPlan{LeftPlan: anchor, Tp: parser.UnionTp, All: ops[0].All, RightPlan: recursive}, nil
+	}
+	return &RecursiveCTEPlan{Name: cte.Name, Anchor: anchor, All: ops[0].All, Recursive: recursive, workTables: workTables}, nil
+}
+
+// cteSchema returns the schema of a recursive cte, which is a row index column and the data
+// columns of anchor. The rows of the recursive select are converted to them.
+func cteSchema(anchor Plan) *storage.TableSchema {
+	ret := &storage.TableSchema{
+		Columns: []storage.Field{storage.RowIndexField("", "")},
+	}
+	for _, field := range dataFields(anchor.Schema()) {
+		if field.Alias != "" {
+			field.Name = field.Alias
+		}
+		field.SchemaName, field.TableName, field.Alias, field.AllowNull = "", "", "", true
+		ret.AppendColumn(field)
+	}
+	return ret
+}
+
+// RecursiveCTEPlan is a recursive common table expression like:
+// with recursive name as (select ... union [all] select ... from name ...) ....
+// The anchor select is executed first, then the recursive select is executed repeatedly, and its
+// references of name read the rows returned by the last iteration, until no rows are returned.
+// Without all, duplicate rows are removed, and the rows returned before are not iterated again.
+type RecursiveCTEPlan struct {
+	Name       string `json:"name"`
+	Anchor     Plan   `json:"anchor"`
+	All        bool   `json:"all"`
+	Recursive  Plan   `json:"recursive"`
+	workTables []*WorkTablePlan
+	keys       map[string]bool
+	size       int // The memory size of keys.
+	data       *storage.RecordBatch
+	done       bool
+	index      int
+}
+
+func (cte *RecursiveCTEPlan) Schema() *storage.TableSchema {
+	return cteSchema(cte.Anchor)
+}
+
+func (cte *RecursiveCTEPlan) String() string {
+	return fmt.Sprintf("RecursiveCTEPlan: %s", cte.Name)
+}
+
+func (cte *RecursiveCTEPlan) Child() []Plan {
+	return []Plan{cte.Anchor, cte.Recursive}
+}
+
+func (cte *RecursiveCTEPlan) TypeCheck() error {
+	err := cte.Anchor.TypeCheck()
+	if err != nil {
+		return err
+	}
+	err = cte.Recursive.TypeCheck()
+	if err != nil {
+		return err
+	}
+	anchorFields, recursiveFields := dataFields(cte.Anchor.Schema()), dataFields(cte.Recursive.Schema())
+	if len(anchorFields) != len(recursiveFields) {
+		return errors.New(fmt.Sprintf("the selects of recursive cte '%s' have a different number of columns", cte.Name))
+	}
+	for i, field := range anchorFields {
+		_, err = branchType([]storage.FieldTP{field.TP, recursiveFields[i].TP})
+		if err != nil {
+			return errors.New(fmt.Sprintf("column %s of recursive cte '%s': %s", field.Name, cte.Name, err))
+		}
+	}
+	return nil
+}
+
+func (cte *RecursiveCTEPlan) Execute(ctx context.Context) *storage.RecordBatch {
+	if !cte.done {
+		cte.done = true
+		cte.initialize(ctx)
+	}
+	if cte.data == nil {
+		return nil
+	}
+	ret := cte.data.Slice(cte.index, batchSize)
+	cte.index += batchSize
+	return ret
+}
+
+func (cte *RecursiveCTEPlan) initialize(ctx context.Context) {
+	schema := cte.Schema()
+	cte.data = MakeEmptyRecordBatchFromSchema(schema)
+	cte.keys = map[string]bool{}
+	rows := cte.collect(ctx, cte.Anchor, schema)
+	for depth := 0; rows.RowCount() > 0 && ctx.Err() == nil; depth++ {
+		if depth >= cteMaxRecursionDepth {
+			setQueryErr(ctx, errors.New(fmt.Sprintf("recursive cte '%s' exceeds the max recursion depth %d", cte.Name, cteMaxRecursionDepth)))
+			cte.data = nil
+			return
+		}
+		cte.Recursive.Reset()
+		for _, workTable := range cte.workTables {
+			workTable.data = rows
+		}
+		rows = cte.collect(ctx, cte.Recursive, schema)
+	}
+}
+
+// collect appends the new rows returned by p to the data, and returns them.
+func (cte *RecursiveCTEPlan) collect(ctx context.Context, p Plan, schema *storage.TableSchema) *storage.RecordBatch {
+	ret := MakeEmptyRecordBatchFromSchema(schema)
+	for batch := executePlan(ctx, p); batch != nil; batch = executePlan(ctx, p) {
+		batch = convertBatch(batch, schema)
+		for row := 0; row < batch.RowCount(); row++ {
+			if !cte.All {
+				key := string(dataRowKey(batch, row))
+				if cte.keys[key] {
+					continue
+				}
+				cte.keys[key] = true
+				cte.size += len(key)
+			}
+			ret.Records[0].Append(storage.EncodeInt(int64(cte.data.RowCount() + ret.RowCount())))
+			for col := 1; col < batch.ColumnCount(); col++ {
+				ret.Records[col].Append(batch.Records[col].Values[row])
+			}
+		}
+	}
+	cte.data.Append(ret)
+	return ret
+}
+
+func (cte *RecursiveCTEPlan) Reset() {
+	cte.Anchor.Reset()
+	cte.Recursive.Reset()
+	cte.keys = nil
+	cte.size = 0
+	cte.data = nil
+	cte.done = false
+	cte.index = 0
+}
+
+// WorkTablePlan is a reference of a recursive cte in its recursive select, it returns the rows
+// of the last iteration.
+type WorkTablePlan struct {
+	Name   string `json:"name"`
+	anchor Plan   // For the schema.
+	data   *storage.RecordBatch
+	index  int
+}
+
+func (workTable *WorkTablePlan) Schema() *storage.TableSchema {
+	return cteSchema(workTable.anchor)
+}
+
+func (workTable *WorkTablePlan) String() string {
+	return fmt.Sprintf("WorkTablePlan: %s", workTable.Name)
+}
+
+func (workTable *WorkTablePlan) Child() []Plan {
+	return nil
+}
+
+func (workTable *WorkTablePlan) TypeCheck() error {
+	return nil
+}
+
+func (workTable *WorkTablePlan) Execute(_ context.Context) *storage.RecordBatch {
+	if workTable.data == nil {
+		return nil
+	}
+	ret := workTable.data.Slice(workTable.index, batchSize)
+	workTable.index += batchSize
+	return ret
+}
+
+func (workTable *WorkTablePlan) Reset() {
+	workTable.index = 0
+}
@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"context"
+	"github.com/xiaobogaga/minidb/storage"
+	"time"
+)
+
+// ExecStats is the runtime statistics of a plan collected by explain analyze.
+type ExecStats struct {
+	Rows       int           `json:"act_rows"`
+	Batches    int           `json:"batches"`
+	Time       time.Duration `json:"time"`
+	PeakMemory int           `json:"peak_memory"`
+}
+
+type execStatsKey struct{}
+
+// withExecStats returns a context that makes executePlan collect runtime statistics to stats.
+func withExecStats(ctx context.Context, stats map[Plan]*ExecStats) context.Context {
+	return context.WithValue(ctx, execStatsKey{}, stats)
+}
+
+// Plans holding their input data, like orderBy and groupBy, report the memory they hold.
+type memoryHolder interface {
+	memoryUsage() int
+}
+
+// executePlan executes p for a batch, plans should call their children by it so that
+// runtime statistics can be collected. The time of a plan includes its children.
+func executePlan(ctx context.Context, p Plan) *storage.RecordBatch {
+	stats, ok := ctx.Value(execStatsKey{}).(map[Plan]*ExecStats)
+	if !ok {
+		return p.Execute(ctx)
+	}
+	start := time.Now()
+	ret := p.Execute(ctx)
+	stat, ok := stats[p]
+	if !ok {
+		stat = &ExecStats{}
+		stats[p] = stat
+	}
+	stat.Time += time.Since(start)
+	memory := ret.MemorySize()
+	if holder, ok := p.(memoryHolder); ok {
+		memory += holder.memoryUsage()
+	}
+	if memory > stat.PeakMemory {
+		stat.PeakMemory = memory
+	}
+	if ret != nil {
+		stat.Rows += ret.RowCount()
+		stat.Batches++
+	}
+	return ret
+}
+
+func (orderBy *OrderByPlan) memoryUsage() int {
+	return orderBy.data.MemorySize()
+}
+
+func (semi *SemiJoinPlan) memoryUsage() (size int) {
+	for _, batch := range semi.rightBatches {
+		size += batch.MemorySize()
+	}
+	return
+}
+
+func (join *JoinPlan) memoryUsage() (size int) {
+	for _, batch := range join.rightBatches {
+		size += batch.MemorySize()
+	}
+	return
+}
+
+func (groupBy *GroupByPlan) memoryUsage() int {
+	return groupBy.data.MemorySize() + groupBy.keys.MemorySize() + groupBy.retData.MemorySize()
+}
+
+func (distinct *DistinctPlan) memoryUsage() int {
+	return distinct.size
+}
+
+func (set *SetOperationPlan) memoryUsage() int {
+	return set.size
+}
+
+func (window *WindowPlan) memoryUsage() int {
+	return window.data.MemorySize()
+}
+
+func (cte *RecursiveCTEPlan) memoryUsage() int {
+	return cte.data.MemorySize() + cte.size
+}
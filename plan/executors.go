@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/xiaobogaga/minidb/parser"
@@ -9,14 +10,78 @@ import (
 	"strings"
 )
 
+// ErrQueryInterrupted is returned when a running query is killed.
+var ErrQueryInterrupted = errors.New("query execution was interrupted")
+
+// ErrQueryTimeout is returned when a running query exceeds its execution time limit.
+var ErrQueryTimeout = errors.New("query execution was interrupted, maximum statement execution time exceeded")
+
+type queryErrKey struct{}
+
+// withQueryErr returns a context in which plans can report the errors happened in execution by
+// setQueryErr, since Plan.Execute doesn't return errors.
+func withQueryErr(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryErrKey{}, new(error))
+}
+
+// setQueryErr reports err of the query executed by ctx, the plan should stop returning data after
+// that. Only the first error is kept.
+func setQueryErr(ctx context.Context, err error) {
+	holder, ok := ctx.Value(queryErrKey{}).(*error)
+	if ok && *holder == nil {
+		*holder = err
+	}
+}
+
+// queryError is panicked by the expressions failing in execution, like a scalar subquery returning
+// more than one row, since Expr.Evaluate doesn't return errors. Exec recovers it.
+type queryError struct {
+	err error
+}
+
+// recoverQueryErr recovers a queryError panicked in execution and sets *err to its error, other
+// panics are not recovered.
+func recoverQueryErr(data **storage.RecordBatch, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	queryErr, ok := r.(queryError)
+	if !ok {
+		panic(r)
+	}
+	*data, *err = nil, queryErr.err
+}
+
+// queryContextErr translates the ctx state to the error reported to client.
+func queryContextErr(ctx context.Context) error {
+	if holder, ok := ctx.Value(queryErrKey{}).(*error); ok && *holder != nil {
+		return *holder
+	}
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return ErrQueryTimeout
+	default:
+		return ErrQueryInterrupted
+	}
+}
+
 type Executor struct {
 	Plan      interface{}
 	Stm       parser.Stm
 	CurrentDB *string
+	Ctx       context.Context
 }
 
 func MakeExecutor(stm parser.Stm, currentDB *string) (*Executor, error) {
-	exec := &Executor{Stm: stm, CurrentDB: currentDB}
+	return MakeExecutorWithContext(context.Background(), stm, currentDB)
+}
+
+// MakeExecutorWithContext makes an executor whose execution can be stopped by canceling ctx.
+func MakeExecutorWithContext(ctx context.Context, stm parser.Stm, currentDB *string) (*Executor, error) {
+	exec := &Executor{Stm: stm, CurrentDB: currentDB, Ctx: withQueryErr(ctx)}
 	switch stm.(type) {
 	case *parser.SelectStm:
 		ret, err := MakeSelectPlan(stm.(*parser.SelectStm), *currentDB)
@@ -26,43 +91,82 @@ func MakeExecutor(stm parser.Stm, currentDB *string) (*Executor, error) {
 		exec.Plan = ret
 	case *parser.ShowStm:
 		exec.Plan = &Show{}
+	case *parser.ExplainStm:
+		ret, err := MakeExplainPlan(stm.(*parser.ExplainStm), *currentDB)
+		if err != nil {
+			return nil, err
+		}
+		exec.Plan = ret
 	default:
 	}
 	return exec, nil
 }
 
+// MakePreparedExecutor makes an executor running the plan of prepared, whose parameters must be
+// bound before. It fails if the tables used by prepared are changed after it's prepared.
+func MakePreparedExecutor(ctx context.Context, prepared *PreparedStm, currentDB *string) (*Executor, error) {
+	err := prepared.check()
+	if err != nil {
+		return nil, err
+	}
+	prepared.reset()
+	return &Executor{Plan: prepared.plan, Stm: prepared.Stm, CurrentDB: currentDB, Ctx: withQueryErr(ctx)}, nil
+}
+
 func (exec *Executor) Exec() (data *storage.RecordBatch, err error) {
+	defer recoverQueryErr(&data, &err)
 	currentDB := *exec.CurrentDB
 	stm := exec.Stm
+	err = queryContextErr(exec.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The insert, update and delete statements are planned only if they are prepared.
+	if mul, ok := exec.Plan.(mulPlan); ok {
+		return nil, mul.Execute(exec.Ctx)
+	}
 	switch stm.(type) {
 	case *parser.CreateDatabaseStm:
 		return nil, ExecuteCreateDatabaseStm(stm.(*parser.CreateDatabaseStm))
 	case *parser.DropDatabaseStm:
 		return nil, ExecuteDropDatabaseStm(stm.(*parser.DropDatabaseStm))
+	case *parser.AlterDatabaseStm:
+		return nil, ExecuteAlterDatabaseStm(stm.(*parser.AlterDatabaseStm))
 	case *parser.CreateTableStm:
 		return nil, ExecuteCreateTableStm(stm.(*parser.CreateTableStm), currentDB)
+	case *parser.CreateTableLikeStm:
+		return nil, ExecuteCreateTableLikeStm(stm.(*parser.CreateTableLikeStm), currentDB)
+	case *parser.CreateTableAsSelectStm:
+		return nil, ExecuteCreateTableAsSelectStm(exec.Ctx, stm.(*parser.CreateTableAsSelectStm), currentDB)
 	case *parser.DropTableStm:
 		return nil, ExecuteDropTableStm(stm.(*parser.DropTableStm), currentDB)
 	case *parser.InsertIntoStm:
-		return nil, ExecuteInsertStm(stm.(*parser.InsertIntoStm), currentDB)
+		return nil, ExecuteInsertStm(exec.Ctx, stm.(*parser.InsertIntoStm), currentDB)
 	case *parser.UpdateStm:
-		return nil, ExecuteUpdateStm(stm.(*parser.UpdateStm), currentDB)
+		return nil, ExecuteUpdateStm(exec.Ctx, stm.(*parser.UpdateStm), currentDB)
 	case *parser.MultiUpdateStm:
-		return nil, ExecuteMultiUpdateStm(stm.(*parser.MultiUpdateStm), currentDB)
+		return nil, ExecuteMultiUpdateStm(exec.Ctx, stm.(*parser.MultiUpdateStm), currentDB)
 	case *parser.SingleDeleteStm:
-		return nil, ExecuteDeleteStm(stm.(*parser.SingleDeleteStm), currentDB)
+		return nil, ExecuteDeleteStm(exec.Ctx, stm.(*parser.SingleDeleteStm), currentDB)
 	case *parser.MultiDeleteStm:
-		return nil, ExecuteMultiDeleteStm(stm.(*parser.MultiDeleteStm), currentDB)
+		return nil, ExecuteMultiDeleteStm(exec.Ctx, stm.(*parser.MultiDeleteStm), currentDB)
 	case *parser.RenameStm:
 		return nil, ExecuteRenameStm(stm.(*parser.RenameStm), currentDB)
 	case *parser.TruncateStm:
 		return nil, ExecuteTruncateStm(stm.(*parser.TruncateStm), currentDB)
+	case *parser.AnalyzeTableStm:
+		return nil, ExecuteAnalyzeTableStm(stm.(*parser.AnalyzeTableStm), currentDB)
+	case *parser.AlterTableAlterColumnStm, *parser.AlterTableAddIndexOrConstraintStm, *parser.AlterTableDropIndexOrConstraintStm,
+		*parser.AlterTableAlterEngineStm, *parser.AlterTableCharsetCollateStm:
+		return nil, ExecuteAlterStm(stm, currentDB)
 	case *parser.SelectStm:
-		data = exec.Plan.(Plan).Execute()
-		return data, nil
+		data = executePlan(exec.Ctx, exec.Plan.(Plan))
+		return data, queryContextErr(exec.Ctx)
 	case *parser.ShowStm:
 		data, err = exec.Plan.(*Show).Execute(currentDB, stm.(*parser.ShowStm))
 		return data, err
+	case *parser.ExplainStm:
+		return exec.Plan.(*Explain).Execute(exec.Ctx)
 	case *parser.UseDatabaseStm:
 		err = ExecuteUseStm(stm.(*parser.UseDatabaseStm))
 		if err == nil {
@@ -82,7 +186,7 @@ func MakeSelectPlan(stm *parser.SelectStm, currentDB string) (Plan, error) {
 	if err != nil {
 		return nil, err
 	}
-	return plan, nil
+	return OptimizePlan(plan), nil
 }
 
 func ExecuteCreateDatabaseStm(stm *parser.CreateDatabaseStm) error {
@@ -94,6 +198,22 @@ func ExecuteCreateDatabaseStm(stm *parser.CreateDatabaseStm) error {
 	return nil
 }
 
+// ExecuteAlterDatabaseStm changes the default charset and collate of a database, only the
+// specified one is changed. Existing tables are not changed.
+func ExecuteAlterDatabaseStm(stm *parser.AlterDatabaseStm) error {
+	dbInfo := storage.GetStorage().GetDbInfo(stm.DatabaseName)
+	if dbInfo == nil {
+		return errors.New("database doesn't exist")
+	}
+	if stm.Charset != parser.DEFAULTCHARACTERSETTP {
+		dbInfo.Charset = string(stm.Charset)
+	}
+	if stm.Collate != parser.DEFAULTCOLLATETP {
+		dbInfo.Collate = string(stm.Collate)
+	}
+	return nil
+}
+
 func ExecuteDropDatabaseStm(stm *parser.DropDatabaseStm) error {
 	if !storage.GetStorage().HasSchema(stm.DatabaseName) {
 		return errors.New("database doesn't exist")
@@ -184,6 +304,16 @@ func columnDefToStorageColumn(col *parser.ColumnDefStm, tableName, schemaName st
 	return ret
 }
 
+// columnDefaultValue returns the default value of col converted to the type of field, or nil
+// if col has no default value.
+func columnDefaultValue(col *parser.ColumnDefStm, field storage.Field) ([]byte, error) {
+	if len(col.ColDefaultValue) == 0 {
+		return nil, nil
+	}
+	value := []byte(col.ColDefaultValue)
+	return storage.ConvertValue(storage.Encode(value), storage.InferenceType(value), field)
+}
+
 func getSchema(stm *parser.CreateTableStm, dbInfo *storage.DbInfo) (*storage.TableSchema, error) {
 	schemaName, tableName, _ := getSchemaTableName(stm.TableName, dbInfo.Name)
 	ret := &storage.TableSchema{
@@ -194,6 +324,11 @@ func getSchema(stm *parser.CreateTableStm, dbInfo *storage.DbInfo) (*storage.Tab
 	hasPrimaryColumn := false
 	for i, colDef := range stm.Cols {
 		col := columnDefToStorageColumn(colDef, tableName, schemaName)
+		var err error
+		col.DefaultValue, err = columnDefaultValue(colDef, col)
+		if err != nil {
+			return ret, err
+		}
 		if hasPrimaryColumn && col.PrimaryKey {
 			return ret, errors.New("multi primary key defined")
 		}
@@ -209,31 +344,175 @@ func getSchema(stm *parser.CreateTableStm, dbInfo *storage.DbInfo) (*storage.Tab
 }
 
 func ExecuteCreateTableStm(stm *parser.CreateTableStm, currentDB string) error {
-	schemaName, tableName, err := getSchemaTableName(stm.TableName, currentDB)
+	dbInfo, _, err := getCreateTableDB(stm.TableName, stm.IfNotExist, currentDB)
+	if dbInfo == nil || err != nil {
+		return err
+	}
+	tableSchema, err := getSchema(stm, dbInfo)
 	if err != nil {
 		return err
 	}
-	if stm.IfNotExist && storage.GetStorage().HasTable(schemaName, tableName) {
-		return nil
+	// Use the database defaults if not specified.
+	charset, collate := string(stm.Charset), string(stm.Collate)
+	if stm.Charset == parser.DEFAULTCHARACTERSETTP {
+		charset = dbInfo.Charset
+	}
+	if stm.Collate == parser.DEFAULTCOLLATETP {
+		collate = dbInfo.Collate
+	}
+	table := makeTable(tableSchema, charset, collate, stm.Engine)
+	for _, col := range stm.Cols {
+		if !col.UniqueKey {
+			continue
+		}
+		err = table.AddIndex(&storage.Index{Name: col.ColName, Columns: []string{col.ColName}, Unique: true})
+		if err != nil {
+			return err
+		}
+	}
+	for _, index := range stm.Indexes {
+		err = table.AddIndex(&storage.Index{Name: indexName(index.IndexName, index.ColNames), Columns: index.ColNames})
+		if err != nil {
+			return err
+		}
+	}
+	for _, constraint := range stm.Constraints {
+		err = addConstraint(table, constraint)
+		if err != nil {
+			return err
+		}
+	}
+	dbInfo.AddTable(table)
+	return nil
+}
+
+// getCreateTableDB returns the database where table name will be created. The database is nil
+// if the table already exists and ifNotExist is true, which means nothing to do.
+func getCreateTableDB(name string, ifNotExist bool, currentDB string) (*storage.DbInfo, string, error) {
+	schemaName, tableName, err := getSchemaTableName(name, currentDB)
+	if err != nil {
+		return nil, "", err
 	}
 	dbInfo := storage.GetStorage().GetDbInfo(schemaName)
 	if dbInfo == nil {
-		return errors.New(fmt.Sprintf("cannot find db: '%s'", schemaName))
+		return nil, "", errors.New(fmt.Sprintf("cannot find db: '%s'", schemaName))
 	}
-	tableSchema, err := getSchema(stm, dbInfo)
-	if err != nil {
-		return err
+	if dbInfo.HasTable(tableName) {
+		if ifNotExist {
+			return nil, "", nil
+		}
+		return nil, "", errors.New(fmt.Sprintf("table '%s.%s' already exist", schemaName, tableName))
 	}
+	return dbInfo, tableName, nil
+}
+
+func makeTable(schema *storage.TableSchema, charset, collate, engine string) *storage.TableInfo {
 	table := &storage.TableInfo{
-		TableSchema: tableSchema,
-		Charset:     string(stm.Charset),
-		Collate:     string(stm.Collate),
-		Engine:      stm.Engine,
-		Datas:       make([]*storage.ColumnVector, len(tableSchema.Columns)),
+		TableSchema: schema,
+		Charset:     charset,
+		Collate:     collate,
+		Engine:      engine,
+		Datas:       make([]*storage.ColumnVector, len(schema.Columns)),
 	}
 	for i, col := range table.TableSchema.Columns {
 		table.Datas[i] = &storage.ColumnVector{Field: col}
 	}
+	return table
+}
+
+// ExecuteCreateTableLikeStm creates a table having the same columns, keys and indexes with the
+// liked table, but no data.
+func ExecuteCreateTableLikeStm(stm *parser.CreateTableLikeStm, currentDB string) error {
+	likedSchemaName, likedTableName, err := getSchemaTableName(stm.LikedTableName, currentDB)
+	if err != nil {
+		return err
+	}
+	if !storage.GetStorage().HasTable(likedSchemaName, likedTableName) {
+		return errors.New(fmt.Sprintf("table '%s.%s' doesn't find", likedSchemaName, likedTableName))
+	}
+	liked := storage.GetStorage().GetDbInfo(likedSchemaName).GetTable(likedTableName)
+	dbInfo, tableName, err := getCreateTableDB(stm.TableName, stm.IfNotExist, currentDB)
+	if dbInfo == nil || err != nil {
+		return err
+	}
+	schema := &storage.TableSchema{Columns: make([]storage.Field, len(liked.TableSchema.Columns))}
+	copy(schema.Columns, liked.TableSchema.Columns)
+	schema.SetSchemaTableName(dbInfo.Name, tableName)
+	table := makeTable(schema, liked.Charset, liked.Collate, liked.Engine)
+	for _, index := range liked.Indexes {
+		table.Indexes = append(table.Indexes, &storage.Index{
+			Name:    index.Name,
+			Columns: append([]string{}, index.Columns...),
+			Unique:  index.Unique,
+		})
+	}
+	dbInfo.AddTable(table)
+	return nil
+}
+
+// ExecuteCreateTableAsSelectStm creates a table from the schema of the select statement and
+// loads the selected rows to it. Columns are named by their alias, and all columns allow null.
+func ExecuteCreateTableAsSelectStm(ctx context.Context, stm *parser.CreateTableAsSelectStm, currentDB string) error {
+	dbInfo, tableName, err := getCreateTableDB(stm.TableName, stm.IfNotExist, currentDB)
+	if dbInfo == nil || err != nil {
+		return err
+	}
+	selectPlan, err := MakeSelectPlan(stm.Select, currentDB)
+	if err != nil {
+		return err
+	}
+	schema := &storage.TableSchema{Columns: []storage.Field{storage.RowIndexField(dbInfo.Name, tableName)}}
+	// The positions of the selected columns, row index columns are skipped.
+	var cols []int
+	var colNames []string
+	for i, field := range selectPlan.Schema().Columns {
+		if field.Name == storage.DefaultRowKeyName {
+			continue
+		}
+		name := field.Name
+		if field.Alias != "" {
+			name = field.Alias
+		}
+		if schema.HasColumn("", "", name) {
+			return errors.New(fmt.Sprintf("duplicate column name '%s'", name))
+		}
+		schema.AppendColumn(storage.Field{
+			TP:           field.TP,
+			Name:         name,
+			TableName:    tableName,
+			SchemaName:   dbInfo.Name,
+			DefaultValue: field.DefaultValue,
+			AllowNull:    true,
+		})
+		cols = append(cols, i)
+		colNames = append(colNames, name)
+	}
+	if len(cols) == 0 {
+		return errors.New("table must have at least one column")
+	}
+	// Load all rows first, so no table is created if the select fails.
+	var batches []*storage.RecordBatch
+	for {
+		batch := executePlan(ctx, selectPlan)
+		err = queryContextErr(ctx)
+		if err != nil {
+			return err
+		}
+		if batch == nil {
+			break
+		}
+		batches = append(batches, batch)
+	}
+	table := makeTable(schema, dbInfo.Charset, dbInfo.Collate, "")
+	for _, batch := range batches {
+		for row := 0; row < batch.RowCount(); row++ {
+			values := make([][]byte, len(cols))
+			for i, col := range cols {
+				values[i] = batch.Records[col].RawValue(row)
+			}
+			table.InsertData(colNames, values)
+		}
+	}
 	dbInfo.AddTable(table)
 	return nil
 }
@@ -253,51 +532,58 @@ func ExecuteDropTableStm(stm *parser.DropTableStm, currentDB string) error {
 	return nil
 }
 
-func ExecuteInsertStm(stm *parser.InsertIntoStm, currentDB string) error {
-	plan := MakeInsertPlan(stm, currentDB)
-	err := plan.TypeCheck()
+func ExecuteInsertStm(ctx context.Context, stm *parser.InsertIntoStm, currentDB string) error {
+	plan, err := MakeInsertPlan(stm, currentDB)
+	if err != nil {
+		return err
+	}
+	err = plan.TypeCheck()
 	if err != nil {
 		return err
 	}
-	return plan.Execute()
+	return plan.Execute(ctx)
 }
 
-func ExecuteUpdateStm(stm *parser.UpdateStm, currentDB string) error {
+func ExecuteUpdateStm(ctx context.Context, stm *parser.UpdateStm, currentDB string) error {
 	plan := MakeUpdatePlan(stm, currentDB)
 	err := plan.TypeCheck()
 	if err != nil {
 		return err
 	}
-	return plan.Execute()
+	plan.Input = OptimizePlan(plan.Input)
+	return plan.Execute(ctx)
 }
 
 // For multi update statement, doesn't have orderBy, limit.
-func ExecuteMultiUpdateStm(stm *parser.MultiUpdateStm, currentDB string) error {
+func ExecuteMultiUpdateStm(ctx context.Context, stm *parser.MultiUpdateStm, currentDB string) error {
 	update := MakeMultiUpdatePlan(stm, currentDB)
 	err := update.TypeCheck()
 	if err != nil {
 		return err
 	}
-	return update.Execute()
+	update.Input = OptimizePlan(update.Input)
+	return update.Execute(ctx)
 }
 
-func ExecuteDeleteStm(stm *parser.SingleDeleteStm, currentDB string) error {
+func ExecuteDeleteStm(ctx context.Context, stm *parser.SingleDeleteStm, currentDB string) error {
 	plan := MakeDeletePlan(stm, currentDB)
 	err := plan.TypeCheck()
 	if err != nil {
 		return err
 	}
-	return plan.Execute()
+	plan.Input = OptimizePlan(plan.Input)
+	return plan.Execute(ctx)
 }
 
 // For multi delete, there is no orderBy, no limit.
-func ExecuteMultiDeleteStm(stm *parser.MultiDeleteStm, currentDB string) error {
+func ExecuteMultiDeleteStm(ctx context.Context, stm *parser.MultiDeleteStm, currentDB string) error {
 	delete := MakeMultiDeletePlan(stm, currentDB)
 	err := delete.TypeCheck()
 	if err != nil {
 		return err
 	}
-	return delete.Execute()
+	delete.Input = OptimizePlan(delete.Input)
+	return delete.Execute(ctx)
 }
 
 func ExecuteTruncateStm(stm *parser.TruncateStm, currentDB string) error {
@@ -316,8 +602,200 @@ func ExecuteTruncateStm(stm *parser.TruncateStm, currentDB string) error {
 	return nil
 }
 
-func ExecuteAlterStm(stm interface{}) error {
-	return errors.New("unsupported statement")
+func ExecuteAnalyzeTableStm(stm *parser.AnalyzeTableStm, currentDB string) error {
+	for _, table := range stm.TableNames {
+		schemaName, tableName, err := getSchemaTableName(table, currentDB)
+		if err != nil {
+			return err
+		}
+		if !storage.GetStorage().HasTable(schemaName, tableName) {
+			return errors.New(fmt.Sprintf("table '%s.%s' doesn't find", schemaName, tableName))
+		}
+		storage.GetStorage().GetDbInfo(schemaName).GetTable(tableName).Analyze()
+	}
+	return nil
+}
+
+func ExecuteAlterStm(stm parser.Stm, currentDB string) error {
+	switch stm.(type) {
+	case *parser.AlterTableAlterColumnStm:
+		return executeAlterColumnStm(stm.(*parser.AlterTableAlterColumnStm), currentDB)
+	case *parser.AlterTableAddIndexOrConstraintStm:
+		return executeAlterAddIndexStm(stm.(*parser.AlterTableAddIndexOrConstraintStm), currentDB)
+	case *parser.AlterTableDropIndexOrConstraintStm:
+		return executeAlterDropIndexStm(stm.(*parser.AlterTableDropIndexOrConstraintStm), currentDB)
+	case *parser.AlterTableAlterEngineStm:
+		alterStm := stm.(*parser.AlterTableAlterEngineStm)
+		table, err := getAlterTable(alterStm.TableName, currentDB)
+		if err != nil {
+			return err
+		}
+		table.Engine = alterStm.Engine
+		return nil
+	case *parser.AlterTableCharsetCollateStm:
+		alterStm := stm.(*parser.AlterTableCharsetCollateStm)
+		table, err := getAlterTable(alterStm.TableName, currentDB)
+		if err != nil {
+			return err
+		}
+		// Only the specified one is changed.
+		if alterStm.Charset != parser.DEFAULTCHARACTERSETTP {
+			table.Charset = string(alterStm.Charset)
+		}
+		if alterStm.Collate != parser.DEFAULTCOLLATETP {
+			table.Collate = string(alterStm.Collate)
+		}
+		return nil
+	default:
+		return errors.New("unsupported statement")
+	}
+}
+
+func getAlterTable(name string, currentDB string) (*storage.TableInfo, error) {
+	schemaName, tableName, err := getSchemaTableName(name, currentDB)
+	if err != nil {
+		return nil, err
+	}
+	if !storage.GetStorage().HasTable(schemaName, tableName) {
+		return nil, errors.New(fmt.Sprintf("table '%s.%s' doesn't find", schemaName, tableName))
+	}
+	return storage.GetStorage().GetDbInfo(schemaName).GetTable(tableName), nil
+}
+
+// For add column, existing rows are filled with the default value. For modify and change
+// column, existing values are converted to the new column type.
+func executeAlterColumnStm(stm *parser.AlterTableAlterColumnStm, currentDB string) error {
+	table, err := getAlterTable(stm.TableName, currentDB)
+	if err != nil {
+		return err
+	}
+	name := stm.ColName
+	if stm.Tp == parser.AddColumnTp || stm.Tp == parser.ModifyColumnTp {
+		name = stm.ColDef.ColName
+	}
+	index, col := table.GetColumnInfo(name)
+	if stm.Tp == parser.AddColumnTp {
+		if index > 0 {
+			return errors.New(fmt.Sprintf("column '%s' already exists", name))
+		}
+	} else if index <= 0 {
+		return errors.New(fmt.Sprintf("cannot find column '%s'", name))
+	}
+	if stm.Tp == parser.DropColumnTp {
+		if len(table.Datas) <= 2 {
+			return errors.New("cannot drop all columns of a table")
+		}
+		table.DropColumn(name)
+		return nil
+	}
+	if stm.Tp == parser.ChangeColumnTp && stm.ColDef.ColName != name && table.HasColumn(stm.ColDef.ColName) {
+		return errors.New(fmt.Sprintf("column '%s' already exists", stm.ColDef.ColName))
+	}
+	field, err := makeAlterColumn(stm.ColDef, table, name)
+	if err != nil {
+		return err
+	}
+	if col != nil && col.PrimaryKey {
+		// The primary key is kept like mysql.
+		field.PrimaryKey = true
+	}
+	values := make([][]byte, table.RowCount())
+	for i := range values {
+		if stm.Tp == parser.AddColumnTp && len(field.DefaultValue) == 0 {
+			// The column has no default value, existing rows get nulls.
+			values[i], err = storage.ConvertValue(nil, storage.DefaultFieldTpMap[storage.Null], field)
+		} else if stm.Tp == parser.AddColumnTp {
+			values[i], err = storage.ConvertValue(field.DefaultValue, field.TP, field)
+		} else {
+			values[i], err = storage.ConvertValue(table.Datas[index].Values[i], col.TP, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if field.PrimaryKey {
+		err = storage.CheckPrimaryKey(field.Name, values)
+		if err != nil {
+			return err
+		}
+	}
+	if stm.Tp == parser.AddColumnTp {
+		table.AddColumn(field, field.DefaultValue)
+	} else {
+		table.ReplaceColumn(name, field, values)
+	}
+	return nil
+}
+
+// makeAlterColumn makes the column of col for table, the column replaced is ignored when
+// checking the primary key.
+func makeAlterColumn(col *parser.ColumnDefStm, table *storage.TableInfo, replaced string) (storage.Field, error) {
+	field := columnDefToStorageColumn(col, table.TableSchema.TableName(), table.TableSchema.SchemaName())
+	var err error
+	field.DefaultValue, err = columnDefaultValue(col, field)
+	if err != nil {
+		return field, err
+	}
+	primaryKey := table.PrimaryKey()
+	if field.PrimaryKey && primaryKey != nil && primaryKey.Name != replaced {
+		return field, errors.New("multi primary key defined")
+	}
+	return field, nil
+}
+
+func executeAlterAddIndexStm(stm *parser.AlterTableAddIndexOrConstraintStm, currentDB string) error {
+	table, err := getAlterTable(stm.TableName, currentDB)
+	if err != nil {
+		return err
+	}
+	if stm.Tp == parser.IsIndexTp {
+		indexDef := stm.IndexOrConstraint.(*parser.IndexDefStm)
+		return table.AddIndex(&storage.Index{Name: indexName(indexDef.IndexName, indexDef.ColNames), Columns: indexDef.ColNames})
+	}
+	return addConstraint(table, stm.IndexOrConstraint.(*parser.ConstraintDefStm))
+}
+
+func addConstraint(table *storage.TableInfo, constraintDef *parser.ConstraintDefStm) error {
+	switch constraint := constraintDef.Constraint.(type) {
+	case parser.PrimaryKeyDefStm:
+		if len(constraint.ColNames) != 1 {
+			return errors.New("multi columns primary key is not supported")
+		}
+		return table.AddPrimaryKey(constraint.ColNames[0])
+	case parser.UniqueKeyDefStm:
+		return table.AddIndex(&storage.Index{Name: indexName(constraint.IndexName, constraint.ColNames),
+			Columns: constraint.ColNames, Unique: true})
+	default:
+		return errors.New("foreign key is not supported")
+	}
+}
+
+// indexName returns name, or the first column name like mysql if name is empty.
+func indexName(name string, cols []string) string {
+	if name != "" {
+		return name
+	}
+	return cols[0]
+}
+
+func executeAlterDropIndexStm(stm *parser.AlterTableDropIndexOrConstraintStm, currentDB string) error {
+	table, err := getAlterTable(stm.TableName, currentDB)
+	if err != nil {
+		return err
+	}
+	switch stm.Tp {
+	case parser.IndexTp:
+		if !table.DropIndex(stm.IndexOrKeyName) {
+			return errors.New(fmt.Sprintf("cannot find index '%s'", stm.IndexOrKeyName))
+		}
+	case parser.PrimaryKeyTp:
+		if !table.DropPrimaryKey() {
+			return errors.New("table doesn't have primary key")
+		}
+	default:
+		return errors.New("foreign key is not supported")
+	}
+	return nil
 }
 
 func ExecuteUseStm(stm *parser.UseDatabaseStm) error {
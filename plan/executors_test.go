@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/xiaobogaga/minidb/parser"
@@ -57,6 +58,38 @@ func TestExecuteRenameStm(t *testing.T) {
 	storage.PrintStorage(t)
 }
 
+func TestExecuteAlterDatabaseStm(t *testing.T) {
+	initTestStorage(t)
+	err := ExecuteAlterDatabaseStm(toTestStm(t, "alter database db1 character set = utf16;").(*parser.AlterDatabaseStm))
+	assert.Nil(t, err)
+	err = ExecuteAlterDatabaseStm(toTestStm(t, "alter database db1 collate = utf16_general_ci;").(*parser.AlterDatabaseStm))
+	assert.Nil(t, err)
+	err = ExecuteAlterDatabaseStm(toTestStm(t, "alter database db3 collate = utf16_general_ci;").(*parser.AlterDatabaseStm))
+	assert.NotNil(t, err)
+	dbInfo := storage.GetStorage().GetDbInfo("db1")
+	assert.Equal(t, "utf16", dbInfo.Charset)
+	assert.Equal(t, "UTF16GENERALCI", dbInfo.Collate)
+	// Existing tables are not changed, new tables inherit the database defaults.
+	assert.Equal(t, "default", dbInfo.GetTable("test1").Charset)
+	err = ExecuteCreateTableStm(toTestStm(t, "create table test3 (id int);").(*parser.CreateTableStm), "db1")
+	assert.Nil(t, err)
+	assert.Equal(t, "utf16", dbInfo.GetTable("test3").Charset)
+	assert.Equal(t, "UTF16GENERALCI", dbInfo.GetTable("test3").Collate)
+	err = ExecuteCreateTableStm(toTestStm(t, "create table test4 (id int) character set = utf8;").(*parser.CreateTableStm), "db1")
+	assert.Nil(t, err)
+	assert.Equal(t, "utf8", dbInfo.GetTable("test4").Charset)
+
+	showPlan := &Show{}
+	ret, err := showPlan.Execute("db1", toTestStm(t, "show create database db1;").(*parser.ShowStm))
+	assert.Nil(t, err)
+	assertRecordBatch(t, 3, 2, ret)
+	assert.Equal(t, "utf16", ret.Records[1].String(1))
+	assert.Equal(t, "UTF16GENERALCI", ret.Records[1].String(2))
+	showPlan = &Show{}
+	_, err = showPlan.Execute("db1", toTestStm(t, "show create database db3;").(*parser.ShowStm))
+	assert.NotNil(t, err)
+}
+
 func TestExecuteDropDatabaseStm(t *testing.T) {
 	initTestStorage(t)
 	dropStm := &parser.DropDatabaseStm{
@@ -198,6 +231,23 @@ func TestExecuteSelectStmWithJoin(t *testing.T) {
 	testSelect2(t, sql)
 }
 
+func TestExecuteSelectWithJoinTypes(t *testing.T) {
+	initTestStorage(t)
+	testSelect(t, "select * from test1 cross join test2;", testDataSize*testDataSize, false)
+	testSelect(t, "select * from test1 cross join test2 cross join db2.test1 where db1.test1.id = test2.id;", testDataSize*testDataSize, false)
+	// Natural joins match all the columns having the same names.
+	testSelect(t, "select * from test1 natural join (select id, location from test2) b;", testDataSize, false)
+	testSelect(t, "select * from (select id, location from test1) a natural join (select id, name from test2 where id > 1) b;", 2, false)
+	testSelect(t, "select * from (select id from test1) a natural left join (select location from test2) b;", testDataSize*testDataSize, false)
+	testSelect(t, "select * from (select id from test1) a natural join (select id from test2) b natural join (select id from db2.test1 where id = 3) c;", 1, false)
+	// Full outer joins return the unmatched rows of both sides with nulls.
+	testSelect(t, "select a.id, b.id from (select id from test1 where id < 3) a full join (select id from test2 where id > 0) b on a.id = b.id;", 4, false)
+	testSelect(t, "select * from (select id from test1 where id < 3) a full outer join (select id from test2 where id > 0) b on a.id = b.id where ifnull(a.id, -1) = -1;", 1, false)
+	testSelect(t, "select * from (select id from test1 where id < 3) a full join (select id from test2 where id > 0) b using (id) where ifnull(b.id, -1) = -1;", 1, false)
+	testSelect(t, "select * from test1 full join (select id from test2 where id > 10) b on test1.id = b.id;", testDataSize, false)
+	testSelect(t, "select * from test1 full join test2 on test1.id;", 0, true)
+}
+
 func TestExecuteSelectWithOrderBy(t *testing.T) {
 	initTestStorage(t)
 	var sql string
@@ -308,6 +358,270 @@ func TestExecuteFuncCall(t *testing.T) {
 	testSelect(t, sql, 1, false)
 }
 
+func TestExecuteSelectWithPredicates(t *testing.T) {
+	initTestStorage(t)
+	evenRows := (testDataSize + 1) / 2
+	testSelect(t, "select * from test1 where id in (0, 1, 1.0, 100);", 2, false)
+	testSelect(t, "select * from test1 where id not in (0, 1);", testDataSize-2, false)
+	testSelect(t, "select * from test1 where id between 1 and 2;", 2, false)
+	testSelect(t, "select * from test1 where id between 0.5 and 1 + 1 and id != 2;", 1, false)
+	testSelect(t, "select * from test1 where id not between 1 and 2;", testDataSize-2, false)
+	testSelect(t, "select * from test1 where location like 'location.%';", testDataSize, false)
+	testSelect(t, "select * from test1 where location like 'location._';", testDataSize, false)
+	testSelect(t, "select * from test1 where location not like '%1';", evenRows, false)
+	testSelect(t, "select * from test1 where location like 'location!.0' escape '!';", evenRows, false)
+	testSelect(t, "select * from test1 where location like 'location.0' escape '.';", 0, false)
+	testSelect(t, "select * from test1 where not id = 0;", testDataSize-1, false)
+	testSelect(t, "select * from test1 where not id = 0 and id < 3;", 2, false)
+	testSelect(t, "select * from test1 where not (id = 0 or id = 1);", testDataSize-2, false)
+	testSelect(t, "select * from test1 where (id = 1) is not true;", testDataSize-1, false)
+	testSelect(t, "select id in (1, 2), location like '%0' from test1 where id < 3;", 3, false)
+	testSelect(t, "select location, count(id) from test1 group by location having location like '%0';", 1, false)
+	// Type check fails.
+	testSelect(t, "select * from test1 where name in (1, 2);", 0, true)
+	testSelect(t, "select * from test1 where id between 'a' and 'b';", 0, true)
+	testSelect(t, "select * from test1 where id like 'a%';", 0, true)
+	testSelect(t, "select * from test1 where location like 'a%' escape 'ab';", 0, true)
+	testSelect(t, "select * from test1 where not id;", 0, true)
+	testSelect(t, "select * from test1 where id is not 'a';", 0, true)
+}
+
+func TestExecuteSelectWithNullComparison(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "create table test3 (id int, v int null, f float null);"))
+	assert.Nil(t, testExecute(t, "insert into test3(id) values (1);"))
+	assert.Nil(t, testExecute(t, "insert into test3 values (2, 3, 3.5), (3, 6, 6.5);"))
+	testSelect(t, "select * from test3 where v < 5;", 1, false)
+	testSelect(t, "select * from test3 where v != 3;", 1, false)
+	testSelect(t, "select * from test3 where f <= 6.5 and v >= 0;", 2, false)
+	testSelect(t, "select * from test3 where v = v;", 2, false)
+	// Null is ordered before any value.
+	testSelect(t, "select * from test3 order by v;", 3, false)
+	testSelect(t, "select * from test3 where v = null;", 0, false)
+	testSelect(t, "select * from test3 where v != null or null < f;", 0, false)
+	// Not, in and between carry nulls, which aren't selected.
+	testSelect(t, "select * from test3 where not v > 5;", 1, false)
+	testSelect(t, "select * from test3 where v not in (3, null);", 0, false)
+	testSelect(t, "select * from test3 where v in (3, null);", 1, false)
+	testSelect(t, "select * from test3 where v not in (3);", 1, false)
+	testSelect(t, "select * from test3 where v not between 1 and 4;", 1, false)
+	testSelect(t, "select * from test3 where not (v > 5 and f > 0);", 1, false)
+	testSelect(t, "select * from test3 where not (v > 5 or id = 1);", 1, false)
+	testSelect(t, "select * from test3 where (v > 5) is null and (v in (1)) is null;", 1, false)
+	testSelect(t, "select v > 5, not v > 5, v in (1, null) from test3;", 3, false)
+	// Not in subqueries are null if the subquery returns a null, decorrelated or not.
+	for _, limit := range []string{"", " limit 10"} {
+		testSelect(t, "select * from test3 where v not in (select v from test3 where id < 3"+limit+");", 0, false)
+		testSelect(t, "select * from test3 where id not in (select v from test3"+limit+");", 0, false)
+		testSelect(t, "select * from test3 where v not in (select v from test3 where id > 1"+limit+");", 0, false)
+		testSelect(t, "select * from test3 where v not in (select v from test3 where id > 5"+limit+");", 3, false)
+		testSelect(t, "select * from test3 where id not in (select v from test3 where id > 1"+limit+");", 2, false)
+		testSelect(t, "select * from test3 where id in (select v from test3"+limit+");", 1, false)
+		testSelect(t, "select * from test3 where v not in (select test1.id from test1 where test1.id < test3.id"+limit+");", 2, false)
+		testSelect(t, "select * from test1 where id not in (select v from test3"+limit+");", 0, false)
+	}
+	// Arithmetics having a null operand are null.
+	testSelect(t, "select id, v * 10, f / 2, v + f, -v from test3;", 3, false)
+	testSelect(t, "select * from test3 where v * 10 > 5;", 2, false)
+	testSelect(t, "select * from test3 where v - 1 is null and -f is null;", 1, false)
+	testSelect(t, "select * from test3 where (select sum(v) from test3) = 9;", 3, false)
+	testSelect(t, "select * from test3 where id in (select id from test1 where test1.id > test3.v * 0);", 2, false)
+}
+
+func TestExecuteSelectWithIsNull(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "create table test3 (id int, v int null, s varchar(10) null);"))
+	assert.Nil(t, testExecute(t, "insert into test3(id) values (1);"))
+	assert.Nil(t, testExecute(t, "insert into test3 values (2, null, 'a'), (3, 6, null);"))
+	testSelect(t, "select * from test3 where v is null;", 2, false)
+	testSelect(t, "select * from test3 where v is not null;", 1, false)
+	testSelect(t, "select * from test3 where s is null and v is not null;", 1, false)
+	testSelect(t, "select * from test3 where null is null and not null is not null;", 3, false)
+	testSelect(t, "select * from test3 where ifnull(v, 0) = 0;", 2, false)
+	testSelect(t, "select * from test3 where coalesce(null, v, id) = 1;", 1, false)
+	testSelect(t, "select * from test3 where nullif(v, null) is null;", 2, false)
+	testSelect(t, "select * from test3 where if(v > 0, null, 1) is null;", 1, false)
+	testSelect(t, "select id, null from test3;", 3, false)
+}
+
+func TestExecuteSelectWithConditions(t *testing.T) {
+	initTestStorage(t)
+	evenRows := (testDataSize + 1) / 2
+	testSelect(t, "select * from test1 where case when id < 1 then 'low' when id < 3 then 'mid' else 'high' end = 'mid';", 2, false)
+	testSelect(t, "select * from test1 where case id % 2 when 0 then 'even' else 'odd' end = 'even';", evenRows, false)
+	// No branch matches is null.
+	testSelect(t, "select * from test1 where case id when 0 then true end;", 1, false)
+	testSelect(t, "select * from test1 where if(id < 2, id, 0.5) = 0.5;", testDataSize-2, false)
+	testSelect(t, "select * from test1 where ifnull(nullif(id, 0), 100) = 100;", 1, false)
+	testSelect(t, "select * from test1 where coalesce(nullif(location, 'location.0'), 'x') = 'x';", evenRows, false)
+	testSelect(t, "select id, nullif(id, 0), if(id < 1, 'a', name), case when id < 1 then 1 else 1.5 end from test1;",
+		testDataSize, false)
+	testSelect(t, "select case when id < 2 then 'low' else 'high' end, count(id) from test1 group by case when id < 2 then 'low' else 'high' end;",
+		2, false)
+	// Type check fails.
+	testSelect(t, "select case when id < 2 then 1 else 'a' end from test1;", 0, true)
+	testSelect(t, "select case id when 'a' then 1 end from test1;", 0, true)
+	testSelect(t, "select case when id then 1 end from test1;", 0, true)
+	testSelect(t, "select if(id, 1, 2) from test1;", 0, true)
+	testSelect(t, "select if(id < 1, 1) from test1;", 0, true)
+	testSelect(t, "select ifnull(id) from test1;", 0, true)
+	testSelect(t, "select coalesce(id, name) from test1;", 0, true)
+	testSelect(t, "select nullif(id, 'a') from test1;", 0, true)
+}
+
+func TestExecuteSelectFromDerivedTable(t *testing.T) {
+	initTestStorage(t)
+	evenRows := (testDataSize + 1) / 2
+	testSelect(t, "select t.id, t.n from (select id, name as n from test1 where id > 0) as t where t.id < 3;", 2, false)
+	testSelect(t, "select * from (select id, location from test1) t where id % 2 = 0;", evenRows, false)
+	testSelect(t, "select test2.id from (select id from test1 where id < 2) as t join test2 on t.id = test2.id;", 2, false)
+	testSelect(t, "select * from test2, (select id from test1 where id < 2) as t where t.id = test2.id;", 2, false)
+	testSelect(t, "select * from (select * from (select id from test1) as a) as b where b.id = 1;", 1, false)
+	testSelect(t, "select loc, count(id) from (select id, location as loc from test1) t group by loc;", 2, false)
+	testSelect(t, "select c + 1 from (select count(id) as c from test1) t;", 1, false)
+	testSelect(t, "select * from (test1 join test2 on test1.id = test2.id) where test1.id < 2;", 2, false)
+	testSelect(t, "select * from test1 join (test2) on test1.id = test2.id;", testDataSize, false)
+	// Duplicate or unknown columns.
+	testSelect(t, "select * from (select test1.id, test2.id from test1, test2) t;", 0, true)
+	testSelect(t, "select t.name from (select id from test1) t;", 0, true)
+	testSelect(t, "select test1.id from (select id from test1) t;", 0, true)
+	testSelect(t, "select * from (select id from test5) t;", 0, true)
+}
+
+func TestExecuteSelectWithSubQuery(t *testing.T) {
+	initTestStorage(t)
+	evenRows := (testDataSize + 1) / 2
+	// Scalar subqueries.
+	testSelect(t, "select id, (select max(id) from test2) from test1;", testDataSize, false)
+	testSelect(t, "select id from test1 where id = (select max(id) from test2);", 1, false)
+	testSelect(t, "select id from test1 where id = (select id from test2 where id > 100);", 0, false)
+	// A subquery having no rows is null, comparisons having null are false.
+	testSelect(t, "select id from test1 where id > (select id from test2 where id > 100);", 0, false)
+	testSelect(t, "select id from test1 where id != (select id from test2 where id > 100);", 0, false)
+	testSelect(t, "select id, (select name from test2 where test2.id = test1.id) from test1;", testDataSize, false)
+	testSelect(t, "select id from test1 where id > (select min(test2.id) from test2 where test2.location = test1.location);", testDataSize-2, false)
+	testSelect(t, "select id from test1 where (select count(id) from test2 where test2.id < test1.id) >= 2;", testDataSize-2, false)
+	testSelect(t, "select location, count(id) from test1 group by location having location = (select max(location) from test2);", 1, false)
+	// In and exists subqueries.
+	testSelect(t, "select id from test1 where id in (select id from test2 where id < 2);", 2, false)
+	testSelect(t, "select id from test1 where id not in (select id from test2 where id < 2);", testDataSize-2, false)
+	testSelect(t, "select id from test1 where id in (select id + 1 from test2 where test2.location = test1.location);", 0, false)
+	testSelect(t, "select id from test1 where exists (select * from test2 where test2.id = test1.id and test2.id > 1);", testDataSize-2, false)
+	testSelect(t, "select id from test1 where not exists (select * from test2 where test2.id = test1.id + 1);", 1, false)
+	testSelect(t, "select id from test1 where exists (select * from test2 where test2.id = test1.id + 1) or id = 0;", testDataSize-1, false)
+	testSelect(t, "select exists (select * from test2 where test2.id = test1.id + 1) from test1;", testDataSize, false)
+	testSelect(t, "select id from test1 where id % 2 = 0 and exists (select * from test2 where test2.id > 0);", evenRows, false)
+	// Nested subqueries.
+	testSelect(t, "select id from test1 where id in (select id from test2 where exists (select * from test1 where test1.id = test2.id + 1));", testDataSize-1, false)
+	// Scalar subqueries returning more than one row.
+	testSelect(t, "select * from test1 where (select id from test2) > 1;", 0, true)
+	testSelect(t, "select id, (select id from test2 where test2.id >= test1.id) from test1;", 0, true)
+	testSelect(t, "select id from test1 where id = (select id from test2 where test2.id = test1.id);", testDataSize, false)
+	assert.Equal(t, errSubQueryRows, testExecute(t, "update test2 set age = (select age from test1) where id = 0;"))
+	// Wrong columns.
+	testSelect(t, "select id from test1 where id = (select id, name from test2);", 0, true)
+	testSelect(t, "select id from test1 where id in (select * from test2);", 0, true)
+	testSelect(t, "select id from test1 where exists (select * from test2 where ttt = 1);", 0, true)
+	testSelect(t, "select count(id) from test1 group by location having count(id) > (select count(id) from test2 where test2.id = test1.id);", 0, true)
+}
+
+func TestExecuteSelectDistinct(t *testing.T) {
+	initTestStorage(t)
+	testSelect(t, "select distinct location from test1;", 2, false)
+	testSelect(t, "select distinctrow location, id % 2 from test1 order by location;", 2, false)
+	testSelect(t, "select distinct * from test1;", testDataSize, false)
+	testSelect(t, "select distinct test1.location, test2.location from test1, test2;", 4, false)
+	testSelect(t, "select distinct location from test1 limit 1;", 1, false)
+	testSelect(t, "select distinct count(id) from test1 group by location;", 1, false)
+	testSelect(t, "select count(location) from (select distinct location from test1) t;", 1, false)
+	// Nulls are equal to each other.
+	testSelect(t, "select distinct (select id from test2 where test2.id > 100) from test1;", 1, false)
+
+	testSelect(t, "select count(distinct location), sum(distinct id % 2) from test1;", 1, false)
+	testSelect(t, "select location, count(distinct id % 2) from test1 group by location;", 2, false)
+	// Nulls are not counted.
+	testSelect(t, "select count(distinct (select id from test2 where test2.id > 100)) from test1;", 1, false)
+	testSelect(t, "select max(distinct id) from test1;", 0, true)
+	testSelect(t, "select sum(distinct location) from test1;", 0, true)
+}
+
+func TestExecuteSelectSetOperation(t *testing.T) {
+	initTestStorage(t)
+	testSelect(t, "select id from test1 union select id from test2;", testDataSize, false)
+	testSelect(t, "select id from test1 union all select id from test2;", testDataSize*2, false)
+	testSelect(t, "select location from test1 union select location from test2;", 2, false)
+	testSelect(t, "select id from test1 union all select id from test2 order by id desc limit 3;", 3, false)
+	testSelect(t, "select id from test1 where id < 3 intersect select id from test2 where id > 0;", 2, false)
+	testSelect(t, "select location from test1 intersect all select location from test2 where id > 0;", 3, false)
+	testSelect(t, "select id from test1 except select id from test2 where id > 1;", 2, false)
+	testSelect(t, "select location from test1 except all select location from test2 where id > 1;", 2, false)
+	// Intersect binds tighter than union.
+	testSelect(t, "select id from test1 where id = 0 union select id from test1 where id = 1 intersect select id from test2 where id = 2;", 1, false)
+	// Int and float columns are combined to float, columns are named by the left select.
+	testSelect(t, "select id, name as n from test1 where id = 0 union select age, location from test2 where id = 1 order by n;", 2, false)
+	testSelect(t, "select count(id) from test1 union all select count(id) from test2;", 2, false)
+	testSelect(t, "select * from (select id from test1 union all select id from test2) t where t.id > 2;", 2, false)
+	testSelect(t, "select id from test1 where id in (select id from test2 where id = 1 union select id from test2 where id = 2);", 2, false)
+	// Wrong columns.
+	testSelect(t, "select id from test1 union select id, name from test2;", 0, true)
+	testSelect(t, "select id from test1 union select name from test2;", 0, true)
+	testSelect(t, "select id from test1 union select id from test2 order by test1.id;", 0, true)
+}
+
+func TestExecuteSelectWithWindow(t *testing.T) {
+	initTestStorage(t)
+	testSelect(t, "select id, row_number() over (order by id desc) from test1;", testDataSize, false)
+	testSelect(t, "select * from (select id, row_number() over (partition by location order by id) as rn from test1) t where t.rn = 2;", 2, false)
+	testSelect(t, "select * from (select id, rank() over (order by location) as r, dense_rank() over (order by location) as d from test1) t where t.r = 3 and t.d = 2;", 2, false)
+	testSelect(t, "select * from (select id, lag(id) over (order by id) as p, lead(id, 2, -1) over (order by id) as n from test1) t where t.p = t.id - 1 and t.n = -1;", 2, false)
+	testSelect(t, "select * from (select id, first_value(id) over (partition by location order by id desc) as f from test1) t where t.f = t.id;", 2, false)
+	// Running sums are 0, 1, 3, 6.
+	testSelect(t, "select * from (select id, sum(id) over (order by id) as s from test1) t where t.s = 6;", 1, false)
+	testSelect(t, "select * from (select id, sum(id) over (partition by location) as s from test1) t where t.s = 4;", 2, false)
+	testSelect(t, "select * from (select id, count(*) over () as c from test1) t where t.c = 4;", testDataSize, false)
+	// Frames: sums are 1, 3, 6, 5 and maxes are null, 0, 1, 2.
+	testSelect(t, "select * from (select id, sum(id) over (order by id rows between 1 preceding and 1 following) as s from test1) t where t.s > 4;", 2, false)
+	testSelect(t, "select * from (select id, max(id) over (order by id rows between 2 preceding and 1 preceding) as m from test1) t where t.m = t.id - 1;", 3, false)
+	testSelect(t, "select * from (select id, count(id) over (order by location range between current row and unbounded following) as c from test1) t where t.c = 2;", 2, false)
+	testSelect(t, "select id from test1 order by row_number() over (order by id desc) limit 1;", 1, false)
+	testSelect(t, "select id, row_number() over () from test1 where id > 10;", 0, false)
+	// Wrong usages.
+	testSelect(t, "select id from test1 where row_number() over () > 1;", 0, true)
+	testSelect(t, "select location, count(id) over () from test1 group by location;", 0, true)
+	testSelect(t, "select charlength(name) over () from test1;", 0, true)
+	testSelect(t, "select rank(id) over () from test1;", 0, true)
+	testSelect(t, "select lag(id, id) over () from test1;", 0, true)
+	testSelect(t, "select count(distinct id) over () from test1;", 0, true)
+	testSelect(t, "select sum(id) over (order by id range 1 preceding) from test1;", 0, true)
+}
+
+func TestExecuteSelectWithCTE(t *testing.T) {
+	initTestStorage(t)
+	testSelect(t, "with c as (select id, location from test1 where id > 1) select * from c;", 2, false)
+	testSelect(t, "with c (a, b) as (select id, location from test1 where id > 1) select c.a, x.b from c, c as x where c.a = x.a;", 2, false)
+	// A cte refers to the ctes before it, and inner ctes hide outer ones.
+	testSelect(t, "with c1 as (select id from test1 where id > 0), c2 as (select id from c1 where id > 1) select * from c2;", 2, false)
+	testSelect(t, "with c as (select id from test1) select * from (with c as (select id from test2 where id = 0) select * from c) t;", 1, false)
+	testSelect(t, "with c as (select id from test2 where id > 2) select id from test1 where id in (select id from c);", 1, false)
+	testSelect(t, "with c as (select id from test1) select id from c union select id from c where id > 10;", testDataSize, false)
+	testSelect(t, "with c as (select id from test1), c as (select id from test2) select * from c;", 0, true)
+	testSelect(t, "with c (a, b) as (select id from test1) select * from c;", 0, true)
+	testSelect(t, "select * from c;", 0, true)
+
+	assert.Nil(t, testExecute(t, "create table tree (id int primary key, parent int);"))
+	assert.Nil(t, testExecute(t, "insert into tree values (1, 0), (2, 1), (3, 1), (4, 2), (5, 4), (6, 0);"))
+	testSelect(t, "with recursive sub (id, depth) as (select id, 0 from tree where id = 1 union all "+
+		"select tree.id, sub.depth + 1 from tree join sub on tree.parent = sub.id) select * from sub order by depth;", 5, false)
+	testSelect(t, "with recursive sub (id, depth) as (select id, 0 from tree where id = 1 union all "+
+		"select tree.id, sub.depth + 1 from tree join sub on tree.parent = sub.id) select * from sub where depth = 3;", 1, false)
+	testSelect(t, "with recursive c (n) as (select id from test1 where id = 0 union all select n + 1 from c where n < 5) select * from c;", 6, false)
+	// Without all, the rows returned before are not iterated again.
+	testSelect(t, "with recursive c (n) as (select id from test1 where id = 0 union select (n + 1) % 3 from c) select * from c;", 3, false)
+	testSelect(t, "with recursive c (n) as (select id from test1 where id = 0 union all select n + 1 from c) select * from c;", 0, true)
+	testSelect(t, "with recursive c (n) as (select id from test1 where id = 0 union all select n, n from c) select * from c;", 0, true)
+	testSelect(t, "with recursive c (n) as (select id from test1 except select n from c) select * from c;", 0, true)
+}
+
 func TestExecuteSelectWithLargeData(t *testing.T) {
 	batchSize = 4
 	testDataSize = batchSize * 3
@@ -323,3 +637,425 @@ func TestExecuteSelectWithLargeData(t *testing.T) {
 	sql = "select id, age from test1 order by age limit 2, 8;"
 	testSelect(t, sql, 8, false)
 }
+
+func TestExecuteSelectWithCancel(t *testing.T) {
+	initTestStorage(t)
+	db := "db1"
+	stm := toTestStm(t, "select * from test1, test2;")
+	ctx, cancel := context.WithCancel(context.Background())
+	exec, err := MakeExecutorWithContext(ctx, stm, &db)
+	assert.Nil(t, err)
+	cancel()
+	_, err = exec.Exec()
+	assert.Equal(t, ErrQueryInterrupted, err)
+
+	stm = toTestStm(t, "update test1 set name = 'hello';")
+	exec, err = MakeExecutorWithContext(ctx, stm, &db)
+	assert.Nil(t, err)
+	_, err = exec.Exec()
+	assert.Equal(t, ErrQueryInterrupted, err)
+
+	stm = toTestStm(t, "select * from test1, test2;")
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	exec, err = MakeExecutorWithContext(ctx, stm, &db)
+	assert.Nil(t, err)
+	_, err = exec.Exec()
+	assert.Equal(t, ErrQueryTimeout, err)
+}
+
+func testExplain(t *testing.T, sql string, expectRows int) {
+	stm := toTestStm(t, sql)
+	db := "db1"
+	exec, err := MakeExecutor(stm, &db)
+	assert.Nil(t, err)
+	ret, err := exec.Exec()
+	assert.Nil(t, err)
+	storage.PrintRecordBatch(ret, true)
+	assert.Equal(t, expectRows, ret.RowCount())
+	ret, err = exec.Exec()
+	assert.Nil(t, err)
+	assert.Nil(t, ret)
+}
+
+func TestExecuteExplainStm(t *testing.T) {
+	initTestStorage(t)
+	// projection, selection, scan, tableScan.
+	sql := "explain select id from test1 where id > 1;"
+	testExplain(t, sql, 4)
+	// limit, orderBy, projection, selection, join, scan, tableScan, scan, tableScan.
+	sql = "explain select test1.id from test1 left join test2 on test1.id = test2.id order by test1.id limit 2;"
+	testExplain(t, sql, 9)
+	sql = "explain select location, count(id) from test1 group by location having location != 'a';"
+	testExplain(t, sql, 4)
+	sql = "explain format = json select id from test1 where id > 1;"
+	testExplain(t, sql, 1)
+	// projection, semiJoin, scan, tableScan, scan, tableScan.
+	sql = "explain select id from test1 where exists (select * from test2 where test2.id = test1.id);"
+	testExplain(t, sql, 6)
+	// distinct, projection, scan, tableScan.
+	sql = "explain select distinct location from test1;"
+	testExplain(t, sql, 4)
+	// setOperation, projection, scan, tableScan, projection, scan, tableScan.
+	sql = "explain select id from test1 union select id from test2;"
+	testExplain(t, sql, 7)
+	// projection, window, scan, tableScan.
+	sql = "explain select id, rank() over (partition by location order by id) from test1;"
+	testExplain(t, sql, 4)
+	// projection, derived, recursiveCTE, projection, selection, scan, tableScan, projection, selection, derived, workTable.
+	sql = "explain with recursive c (n) as (select id from test1 where id = 0 union all select n + 1 from c where n < 3) select n from c;"
+	testExplain(t, sql, 11)
+	// projection, join, scan, tableScan, scan, tableScan.
+	sql = "explain select * from test1 full join test2 on test1.id = test2.id;"
+	testExplain(t, sql, 6)
+
+	p, err := MakePlan(toTestStm(t, "select id from test1 where id > 1 limit 1;").(*parser.SelectStm), "db1")
+	assert.Nil(t, err)
+	node := MakeExplainNode(p, nil)
+	assert.Equal(t, "Limit", node.Operator)
+	assert.Equal(t, 1, node.EstRows)
+	assert.Equal(t, "Projection", node.Children[0].Operator)
+	assert.Equal(t, testDataSize, node.Children[0].Children[0].Children[0].EstRows)
+
+	db := "db1"
+	_, err = MakeExecutor(toTestStm(t, "explain select ttt from test1;"), &db)
+	assert.NotNil(t, err)
+}
+
+func TestExecuteExplainAnalyzeStm(t *testing.T) {
+	initTestStorage(t)
+	sql := "explain analyze select id from test1 where id > 1 order by id;"
+	testExplain(t, sql, 5)
+	sql = "explain analyze select test1.id from test1 left join test2 on test1.id = test2.id limit 2;"
+	testExplain(t, sql, 8)
+	sql = "explain analyze format = json select location, count(id) from test1 group by location;"
+	testExplain(t, sql, 1)
+
+	p, err := MakePlan(toTestStm(t, "select id from test1 where id > 1 order by id;").(*parser.SelectStm), "db1")
+	assert.Nil(t, err)
+	explain := &Explain{Analyze: true, Input: p}
+	stats, err := explain.collectExecStats(context.Background())
+	assert.Nil(t, err)
+	node := MakeExplainNode(p, stats)
+	// projection, orderBy, selection, scan, tableScan.
+	assert.Equal(t, testDataSize-2, node.Runtime.Rows)
+	orderBy := node.Children[0]
+	assert.Equal(t, testDataSize-2, orderBy.Runtime.Rows)
+	assert.True(t, orderBy.Runtime.PeakMemory > 0)
+	tableScan := orderBy.Children[0].Children[0].Children[0]
+	assert.Equal(t, "TableScan", tableScan.Operator)
+	assert.Equal(t, testDataSize, tableScan.Runtime.Rows)
+	assert.Equal(t, (testDataSize+batchSize-1)/batchSize, tableScan.Runtime.Batches)
+}
+
+func TestExecuteAnalyzeTableStm(t *testing.T) {
+	initTestStorage(t)
+	showStm := &parser.ShowStm{TP: parser.ShowStatsTP, Table: "test1"}
+	showPlan := &Show{}
+	ret, err := showPlan.Execute("db1", showStm)
+	assert.Nil(t, err)
+	assertRecordBatch(t, 0, 8, ret)
+
+	analyzeStm := &parser.AnalyzeTableStm{TableNames: []string{"test1", "db2.test2"}}
+	err = ExecuteAnalyzeTableStm(analyzeStm, "db1")
+	assert.Nil(t, err)
+	stats := storage.GetStorage().GetDbInfo("db1").GetTable("test1").Stats
+	assert.NotNil(t, stats)
+	assert.Equal(t, testDataSize, stats.RowCount)
+	assert.Equal(t, testDataSize, stats.GetColumnStats("id").DistinctCount)
+	assert.Equal(t, 2, stats.GetColumnStats("location").DistinctCount)
+	assert.NotNil(t, storage.GetStorage().GetDbInfo("db2").GetTable("test2").Stats)
+	assert.Nil(t, storage.GetStorage().GetDbInfo("db1").GetTable("test2").Stats)
+
+	showPlan = &Show{}
+	ret, err = showPlan.Execute("db1", showStm)
+	assert.Nil(t, err)
+	// All columns except the row index column.
+	assertRecordBatch(t, 18, 8, ret)
+	storage.PrintRecordBatch(ret, true)
+
+	analyzeStm = &parser.AnalyzeTableStm{TableNames: []string{"test3"}}
+	err = ExecuteAnalyzeTableStm(analyzeStm, "db1")
+	assert.NotNil(t, err)
+}
+
+func testAlter(t *testing.T, sql string, expectErr bool) {
+	stm := toTestStm(t, sql)
+	err := ExecuteAlterStm(stm, "db1")
+	if expectErr {
+		assert.NotNil(t, err, sql)
+		fmt.Printf("err: %s\n", err)
+		return
+	}
+	assert.Nil(t, err, sql)
+}
+
+func TestExecuteAlterStm(t *testing.T) {
+	initTestStorage(t)
+	table := storage.GetStorage().GetDbInfo("db1").GetTable("test1")
+	table.Analyze()
+	// Existing rows are filled with the default value.
+	testAlter(t, "alter table test1 add column score int default 5;", false)
+	assert.Nil(t, table.Stats)
+	testSelect(t, "select * from test1 where score = 5;", testDataSize, false)
+	testAlter(t, "alter table test1 add column score int null;", true)
+	testAlter(t, "alter table test1 add column note varchar(10);", true)
+	testAlter(t, "alter table test1 add column note varchar(10) null;", false)
+	testAlter(t, "alter table test1 add column x int default 'a';", true)
+	testAlter(t, "alter table test1 add column x int default 1 primary key;", true)
+
+	// Values are converted to the new type.
+	testAlter(t, "alter table test1 modify score float;", false)
+	_, score := table.GetColumnInfo("score")
+	assert.Equal(t, storage.Float, score.TP.Name)
+	testSelect(t, "select * from test1 where score = 5.0;", testDataSize, false)
+	testAlter(t, "alter table test1 change score points varchar(10);", false)
+	testSelect(t, "select * from test1 where points = '5';", testDataSize, false)
+	testAlter(t, "alter table test1 modify points bool;", true)
+	testAlter(t, "alter table test1 change location loc varchar(5);", true)
+	testAlter(t, "alter table test1 change location points varchar(20);", true)
+	testAlter(t, "alter table test1 change location loc varchar(20);", false)
+	testSelect(t, "select loc from test1 where loc = 'location.1';", testDataSize/2, false)
+	testAlter(t, "alter table test1 modify id float;", false)
+	assert.Equal(t, "id", table.PrimaryKey().Name)
+
+	testAlter(t, "alter table test1 drop column points;", false)
+	testAlter(t, "alter table test1 drop column points;", true)
+	testSelect(t, "select points from test1;", 0, true)
+
+	// Indexes and keys.
+	testAlter(t, "alter table test1 add index idx_loc (loc, sex);", false)
+	testAlter(t, "alter table test1 add index idx_loc (loc);", true)
+	testAlter(t, "alter table test1 add index (unknown);", true)
+	testAlter(t, "alter table test1 add unique key (loc);", true)
+	testAlter(t, "alter table test1 add unique key uk_id (id);", false)
+	testAlter(t, "alter table test1 drop column sex;", false)
+	assert.Equal(t, []string{"loc"}, table.GetIndex("idx_loc").Columns)
+	testAlter(t, "alter table test1 drop index idx_loc;", false)
+	testAlter(t, "alter table test1 drop index idx_loc;", true)
+	testAlter(t, "alter table test1 add constraint primary key (loc);", true)
+	testAlter(t, "alter table test1 drop primary key;", false)
+	testAlter(t, "alter table test1 drop primary key;", true)
+	testAlter(t, "alter table test1 add constraint primary key (loc);", true)
+	testAlter(t, "alter table test1 add constraint primary key (id);", false)
+	testAlter(t, "alter table test1 drop foreign key fk;", true)
+
+	testAlter(t, "alter table test1 engine = memory;", false)
+	testAlter(t, "alter table test1 collate = utf8_general_ci;", false)
+	assert.Equal(t, "memory", table.Engine)
+	assert.Equal(t, "UTF8GENERALCI", table.Collate)
+	testAlter(t, "alter table test3 engine = memory;", true)
+}
+
+func testExecute(t *testing.T, sql string) error {
+	db := "db1"
+	exec, err := MakeExecutor(toTestStm(t, sql), &db)
+	assert.Nil(t, err)
+	_, err = exec.Exec()
+	return err
+}
+
+func TestExecuteCreateTableLikeStm(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "alter table test1 add unique key uk_id (id);"))
+	assert.Nil(t, testExecute(t, "alter table test1 add column score int default 5;"))
+	assert.Nil(t, testExecute(t, "create table db2.test3 like test1;"))
+	assert.NotNil(t, testExecute(t, "create table test3 like test4;"))
+	assert.NotNil(t, testExecute(t, "create table test2 like test1;"))
+	assert.Nil(t, testExecute(t, "create table if not exist test2 like test1;"))
+	liked := storage.GetStorage().GetDbInfo("db1").GetTable("test1")
+	table := storage.GetStorage().GetDbInfo("db2").GetTable("test3")
+	assert.Equal(t, 0, table.RowCount())
+	assert.Equal(t, len(liked.TableSchema.Columns), len(table.TableSchema.Columns))
+	assert.Equal(t, "test3", table.TableSchema.TableName())
+	assert.Equal(t, "db2", table.TableSchema.SchemaName())
+	assert.Equal(t, "id", table.PrimaryKey().Name)
+	_, score := table.GetColumnInfo("score")
+	assert.Equal(t, int64(5), storage.DecodeInt(score.DefaultValue))
+	assert.True(t, table.GetIndex("uk_id").Unique)
+	// The liked table is not changed.
+	assert.Nil(t, testExecute(t, "alter table db2.test3 drop index uk_id;"))
+	assert.NotNil(t, liked.GetIndex("uk_id"))
+	assert.Equal(t, "test1", liked.TableSchema.TableName())
+}
+
+func TestExecuteCreateTableAsSelectStm(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "create table test3 as select id, location as loc, age * 2 as age2 from test1 where id > 0;"))
+	table := storage.GetStorage().GetDbInfo("db1").GetTable("test3")
+	assert.Equal(t, testDataSize-1, table.RowCount())
+	assert.Equal(t, 4, len(table.TableSchema.Columns))
+	_, loc := table.GetColumnInfo("loc")
+	assert.Equal(t, storage.VarChar, loc.TP.Name)
+	_, age := table.GetColumnInfo("age2")
+	assert.Equal(t, storage.Float, age.TP.Name)
+	assert.Nil(t, table.PrimaryKey())
+	testSelect(t, "select * from test3 where loc = 'location.1';", testDataSize/2, false)
+
+	assert.Nil(t, testExecute(t, "create table test4 as select * from test1;"))
+	assert.Equal(t, testDataSize, storage.GetStorage().GetDbInfo("db1").GetTable("test4").RowCount())
+	assert.Nil(t, testExecute(t, "create table if not exist test4 as select id from test1;"))
+	assert.NotNil(t, testExecute(t, "create table test4 as select id from test1;"))
+	assert.NotNil(t, testExecute(t, "create table test5 as select * from test1, test2;"))
+	assert.NotNil(t, testExecute(t, "create table test5 as select unknown from test1;"))
+	assert.False(t, storage.GetStorage().HasTable("db1", "test5"))
+}
+
+func testPrepare(t *testing.T, sql string) (*PreparedStm, error) {
+	parser := parser.NewParser()
+	stm, err := parser.Parse([]byte(sql))
+	assert.Nil(t, err)
+	return Prepare(stm, parser.ParamCount, "db1")
+}
+
+// testExecutePrepared binds values of types and returns the count of returned rows.
+func testExecutePrepared(t *testing.T, prepared *PreparedStm, values [][]byte, types ...storage.FieldTPName) (int, error) {
+	tps := make([]storage.FieldTP, len(types))
+	for i, tp := range types {
+		tps[i] = storage.DefaultFieldTpMap[tp]
+	}
+	err := prepared.Bind(values, tps)
+	if err != nil {
+		return 0, err
+	}
+	db := "db1"
+	exec, err := MakePreparedExecutor(context.Background(), prepared, &db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		ret, err := exec.Exec()
+		if err != nil || ret == nil {
+			return count, err
+		}
+		count += ret.RowCount()
+	}
+}
+
+func TestExecutePreparedStm(t *testing.T) {
+	initTestStorage(t)
+	prepared, err := testPrepare(t, "select id, ? from test1 where id > ? and id < ? + 2;")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(prepared.Params))
+	// The types are inferred from the expressions using the parameters, or text.
+	assert.Equal(t, storage.Text, prepared.Params[0].TP.Name)
+	assert.Equal(t, storage.Int, prepared.Params[1].TP.Name)
+	assert.Equal(t, storage.Int, prepared.Params[2].TP.Name)
+	assert.Equal(t, storage.Text, prepared.Schema().Columns[2].TP.Name)
+	// The plan is executed again with other values.
+	rows, err := testExecutePrepared(t, prepared, [][]byte{[]byte("a"), storage.EncodeInt(0), storage.EncodeInt(1)},
+		storage.Text, storage.Int, storage.Int)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rows)
+	rows, err = testExecutePrepared(t, prepared, [][]byte{nil, storage.EncodeInt(1), []byte("1")},
+		storage.Int, storage.Int, storage.Text)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rows)
+	rows, err = testExecutePrepared(t, prepared, [][]byte{nil, storage.EncodeInt(3), storage.EncodeInt(2)}, storage.Int, storage.Int, storage.Int)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rows)
+	_, err = testExecutePrepared(t, prepared, [][]byte{nil, storage.EncodeInt(0), []byte("abc")}, storage.Int, storage.Int, storage.Text)
+	assert.NotNil(t, err)
+	_, err = testExecutePrepared(t, prepared, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.NotNil(t, err)
+
+	// Values of execute ... using must be constants.
+	prepared, err = testPrepare(t, "select id from test1 where id in (?, ?);")
+	assert.Nil(t, err)
+	assert.Nil(t, prepared.BindExprs(toTestStm(t, "execute s using 1 + 1, -1;").(*parser.ExecuteStm).Params))
+	assert.Equal(t, int64(2), storage.DecodeInt(prepared.Params[0].Value))
+	assert.Equal(t, int64(-1), storage.DecodeInt(prepared.Params[1].Value))
+	assert.NotNil(t, prepared.BindExprs(toTestStm(t, "execute s using id, 1;").(*parser.ExecuteStm).Params))
+
+	// Insert, update and delete.
+	assert.Nil(t, testExecute(t, "create table prepared (id int primary key, name varchar(20), score float);"))
+	prepared, err = testPrepare(t, "insert into prepared values (?, ?, ?);")
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = testExecutePrepared(t, prepared, [][]byte{storage.EncodeInt(int64(i)), []byte(fmt.Sprintf("name%d", i)), storage.EncodeInt(int64(i))},
+			storage.Int, storage.Text, storage.Int)
+		assert.Nil(t, err)
+	}
+	_, err = testExecutePrepared(t, prepared, [][]byte{[]byte("x"), nil, nil}, storage.Text, storage.Int, storage.Int)
+	assert.NotNil(t, err)
+	prepared, err = testPrepare(t, "update prepared set score = score + ? where id = ?;")
+	assert.Nil(t, err)
+	_, err = testExecutePrepared(t, prepared, [][]byte{storage.EncodeFloat(0.5), storage.EncodeInt(1)}, storage.Float, storage.Int)
+	assert.Nil(t, err)
+	testSelect(t, "select * from prepared where score = 1.5;", 1, false)
+	prepared, err = testPrepare(t, "delete from prepared where name = ?;")
+	assert.Nil(t, err)
+	_, err = testExecutePrepared(t, prepared, [][]byte{[]byte("name0")}, storage.Text)
+	assert.Nil(t, err)
+	_, err = testExecutePrepared(t, prepared, [][]byte{[]byte("name2")}, storage.Text)
+	assert.Nil(t, err)
+	testSelect(t, "select * from prepared;", 1, false)
+
+	// Uncorrelated subqueries are executed again with other values.
+	for _, sql := range []string{
+		"select id from test1 where id < (select min(id) from test2 where id > ?);",
+		"select id from test1 where id in (select id from test2 where id <= ?);",
+		"select id from test1 where id < (select count(id) from test2 where id <= ?);",
+	} {
+		prepared, err = testPrepare(t, sql)
+		assert.Nil(t, err)
+		for _, value := range []int64{0, 2} {
+			rows, err := testExecutePrepared(t, prepared, [][]byte{storage.EncodeInt(value)}, storage.Int)
+			assert.Nil(t, err, sql)
+			assert.Equal(t, int(value)+1, rows, sql)
+		}
+	}
+
+	// Placeholders can only be used in prepared statements.
+	testSelect(t, "select id from test1 where id = ?;", 0, true)
+	_, err = testPrepare(t, "show tables;")
+	assert.NotNil(t, err)
+	_, err = testPrepare(t, "select unknown from test1 where id = ?;")
+	assert.NotNil(t, err)
+}
+
+func TestExecutePreparedStmAfterSchemaChange(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "create table test3 (id int, v int);"))
+	assert.Nil(t, testExecute(t, "insert into test3 values (1, 1), (2, 2);"))
+	selected, err := testPrepare(t, "select * from test3 where id > ?;")
+	assert.Nil(t, err)
+	inserted, err := testPrepare(t, "insert into test3 values (?, ?);")
+	assert.Nil(t, err)
+	joined, err := testPrepare(t, "select id from test1 where id in (select id from test3 where v = ?);")
+	assert.Nil(t, err)
+	rows, err := testExecutePrepared(t, selected, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rows)
+	// Data changes don't matter.
+	_, err = testExecutePrepared(t, inserted, [][]byte{storage.EncodeInt(3), storage.EncodeInt(3)}, storage.Int, storage.Int)
+	assert.Nil(t, err)
+	rows, err = testExecutePrepared(t, joined, [][]byte{storage.EncodeInt(3)}, storage.Int)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rows)
+
+	// The schema is changed.
+	assert.Nil(t, testExecute(t, "alter table test3 drop column v;"))
+	_, err = testExecutePrepared(t, selected, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.NotNil(t, err)
+	_, err = testExecutePrepared(t, inserted, [][]byte{storage.EncodeInt(4), storage.EncodeInt(4)}, storage.Int, storage.Int)
+	assert.NotNil(t, err)
+	_, err = testExecutePrepared(t, joined, [][]byte{storage.EncodeInt(3)}, storage.Int)
+	assert.NotNil(t, err)
+	testSelect(t, "select * from test3;", 3, false)
+	// Prepared again.
+	selected, err = testPrepare(t, "select * from test3 where id > ?;")
+	assert.Nil(t, err)
+	rows, err = testExecutePrepared(t, selected, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, rows)
+
+	// The table is dropped, or dropped and created again.
+	assert.Nil(t, testExecute(t, "drop table test3;"))
+	_, err = testExecutePrepared(t, selected, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.NotNil(t, err)
+	assert.Nil(t, testExecute(t, "create table test3 (id int);"))
+	_, err = testExecutePrepared(t, selected, [][]byte{storage.EncodeInt(0)}, storage.Int)
+	assert.NotNil(t, err)
+}
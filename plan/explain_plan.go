@@ -0,0 +1,357 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+	"math"
+	"strings"
+)
+
+// For explain [analyze] [format = {traditional | json}] select_statement.
+type Explain struct {
+	Analyze bool
+	Format  parser.ExplainFormatTp
+	Input   Plan
+	Done    bool
+}
+
+// ExplainNode is an operator of the plan tree shown by explain.
+type ExplainNode struct {
+	Operator string         `json:"operator"`
+	Details  string         `json:"details"`
+	EstRows  int            `json:"est_rows"`
+	Runtime  *ExecStats     `json:"runtime,omitempty"`
+	Children []*ExplainNode `json:"children,omitempty"`
+}
+
+func MakeExplainPlan(stm *parser.ExplainStm, currentDB string) (*Explain, error) {
+	input, err := MakeSelectPlan(stm.Select, currentDB)
+	if err != nil {
+		return nil, err
+	}
+	return &Explain{Analyze: stm.Analyze, Format: stm.Format, Input: input}, nil
+}
+
+func (explain *Explain) Execute(ctx context.Context) (*storage.RecordBatch, error) {
+	if explain.Done {
+		return nil, nil
+	}
+	explain.Done = true
+	err := queryContextErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var stats map[Plan]*ExecStats
+	if explain.Analyze {
+		stats, err = explain.collectExecStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	node := MakeExplainNode(explain.Input, stats)
+	if explain.Format == parser.ExplainJSONFormat {
+		return explainJSONData(node)
+	}
+	fields := []storage.Field{
+		storage.RowIndexField("", ""),
+		{TP: storage.DefaultFieldTpMap[storage.Text], Name: "operator"},
+		{TP: storage.DefaultFieldTpMap[storage.Text], Name: "details"},
+		{TP: storage.DefaultFieldTpMap[storage.Int], Name: "est_rows"},
+	}
+	if explain.Analyze {
+		fields = append(fields,
+			storage.Field{TP: storage.DefaultFieldTpMap[storage.Int], Name: "act_rows"},
+			storage.Field{TP: storage.DefaultFieldTpMap[storage.Int], Name: "batches"},
+			storage.Field{TP: storage.DefaultFieldTpMap[storage.Text], Name: "time"},
+			storage.Field{TP: storage.DefaultFieldTpMap[storage.Int], Name: "peak_memory"},
+		)
+	}
+	ret := MakeEmptyRecordBatchFromSchema(&storage.TableSchema{Columns: fields})
+	fillExplainData(ret, node, 0)
+	return ret, nil
+}
+
+// collectExecStats runs the whole query and returns the runtime statistics of each plan.
+func (explain *Explain) collectExecStats(ctx context.Context) (map[Plan]*ExecStats, error) {
+	stats := map[Plan]*ExecStats{}
+	statsCtx := withExecStats(ctx, stats)
+	for executePlan(statsCtx, explain.Input) != nil {
+	}
+	return stats, queryContextErr(ctx)
+}
+
+func explainJSONData(node *ExplainNode) (*storage.RecordBatch, error) {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	ret := MakeEmptyRecordBatchFromSchema(&storage.TableSchema{Columns: []storage.Field{
+		storage.RowIndexField("", ""),
+		{TP: storage.DefaultFieldTpMap[storage.Text], Name: "explain"},
+	}})
+	ret.Records[0].Append(storage.EncodeInt(0))
+	ret.Records[1].Append(bytes.TrimSpace(buf.Bytes()))
+	return ret, nil
+}
+
+// Children are indented under their parent, like:
+//
+//	Projection
+//	  └─Selection
+//	    └─Scan
+func fillExplainData(ret *storage.RecordBatch, node *ExplainNode, depth int) {
+	operator := node.Operator
+	if depth > 0 {
+		operator = strings.Repeat("  ", depth) + "└─" + operator
+	}
+	ret.Records[0].Append(storage.EncodeInt(int64(ret.RowCount())))
+	ret.Records[1].Append([]byte(operator))
+	ret.Records[2].Append([]byte(node.Details))
+	ret.Records[3].Append(storage.EncodeInt(int64(node.EstRows)))
+	if ret.ColumnCount() > 4 {
+		runtime := node.Runtime
+		ret.Records[4].Append(storage.EncodeInt(int64(runtime.Rows)))
+		ret.Records[5].Append(storage.EncodeInt(int64(runtime.Batches)))
+		ret.Records[6].Append([]byte(runtime.Time.String()))
+		ret.Records[7].Append(storage.EncodeInt(int64(runtime.PeakMemory)))
+	}
+	for _, child := range node.Children {
+		fillExplainData(ret, child, depth+1)
+	}
+}
+
+// MakeExplainNode makes the explain tree of p, stats is the runtime statistics collected
+// by explain analyze and can be nil.
+func MakeExplainNode(p Plan, stats map[Plan]*ExecStats) *ExplainNode {
+	node := &ExplainNode{
+		Operator: explainOperator(p),
+		Details:  explainDetails(p),
+		EstRows:  EstimateRows(p),
+	}
+	if stats != nil {
+		node.Runtime = stats[p]
+		if node.Runtime == nil {
+			node.Runtime = &ExecStats{}
+		}
+	}
+	for _, child := range p.Child() {
+		node.Children = append(node.Children, MakeExplainNode(child, stats))
+	}
+	return node
+}
+
+func explainOperator(p Plan) string {
+	switch p.(type) {
+	case *ScanPlan:
+		return "Scan"
+	case *TableScan:
+		return "TableScan"
+	case *JoinPlan:
+		return "Join"
+	case *SelectionPlan:
+		return "Selection"
+	case *OrderByPlan:
+		return "OrderBy"
+	case *ProjectionPlan:
+		return "Projection"
+	case *LimitPlan:
+		return "Limit"
+	case *GroupByPlan:
+		return "GroupBy"
+	case *HavingPlan:
+		return "Having"
+	case *ReorderPlan:
+		return "Reorder"
+	case *DerivedPlan:
+		return "Derived"
+	case *SemiJoinPlan:
+		return "SemiJoin"
+	case *DistinctPlan:
+		return "Distinct"
+	case *SetOperationPlan:
+		return "SetOperation"
+	case *WindowPlan:
+		return "Window"
+	case *RecursiveCTEPlan:
+		return "RecursiveCTE"
+	case *WorkTablePlan:
+		return "WorkTable"
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
+
+func exprsToString(exprs []Expr) string {
+	buf := bytes.Buffer{}
+	for i, expr := range exprs {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(expr.String())
+	}
+	return buf.String()
+}
+
+func asExprsToString(exprs []AsExpr) string {
+	buf := bytes.Buffer{}
+	for i, expr := range exprs {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(expr.Expr.String())
+		if expr.Alias != "" {
+			buf.WriteString(" as " + expr.Alias)
+		}
+	}
+	return buf.String()
+}
+
+func explainDetails(p Plan) string {
+	switch plan := p.(type) {
+	case *ScanPlan:
+		if plan.Alias != "" && plan.Alias != plan.Name {
+			return fmt.Sprintf("table: %s.%s, alias: %s", plan.SchemaName, plan.Name, plan.Alias)
+		}
+		return fmt.Sprintf("table: %s.%s", plan.SchemaName, plan.Name)
+	case *TableScan:
+		if len(plan.Filters) > 0 {
+			return fmt.Sprintf("table: %s.%s, zone map: %s, skipped groups: %d", plan.SchemaName, plan.Name,
+				zoneFiltersToString(plan.Filters), plan.SkippedGroups)
+		}
+		return fmt.Sprintf("table: %s.%s", plan.SchemaName, plan.Name)
+	case *JoinPlan:
+		if plan.Expr != nil {
+			return fmt.Sprintf("%s, on: %s", joinTypeToString(plan.JoinType), plan.Expr)
+		}
+		return joinTypeToString(plan.JoinType)
+	case *SelectionPlan:
+		return fmt.Sprintf("where: %s", plan.Expr)
+	case *OrderByPlan:
+		return plan.OrderBy.String()
+	case *ProjectionPlan:
+		if len(plan.Exprs) == 0 {
+			return "*"
+		}
+		return asExprsToString(plan.Exprs)
+	case *LimitPlan:
+		return fmt.Sprintf("count: %d, offset: %d", plan.Count, plan.Offset)
+	case *GroupByPlan:
+		return fmt.Sprintf("group by: %s, aggr: %s", exprsToString(plan.GroupByExpr), asExprsToString(plan.AggrExprs))
+	case *HavingPlan:
+		return fmt.Sprintf("having: %s", plan.Expr)
+	case *ReorderPlan:
+		return fmt.Sprintf("columns: %d", len(plan.Order))
+	case *DerivedPlan:
+		return fmt.Sprintf("alias: %s", plan.Alias)
+	case *SetOperationPlan:
+		return plan.operation()
+	case *RecursiveCTEPlan:
+		if plan.All {
+			return fmt.Sprintf("name: %s, union all", plan.Name)
+		}
+		return fmt.Sprintf("name: %s, union", plan.Name)
+	case *WorkTablePlan:
+		return fmt.Sprintf("name: %s", plan.Name)
+	case *WindowPlan:
+		windows := make([]Expr, len(plan.Windows))
+		for i, window := range plan.Windows {
+			windows[i] = window
+		}
+		return exprsToString(windows)
+	case *SemiJoinPlan:
+		var on []string
+		if plan.Expr != nil {
+			on = append(on, plan.Expr.String())
+		}
+		if plan.In != nil {
+			on = append(on, plan.In.String())
+		}
+		if len(on) == 0 {
+			return plan.joinType()
+		}
+		return fmt.Sprintf("%s, on: %s", plan.joinType(), strings.Join(on, " and "))
+	default:
+		return ""
+	}
+}
+
+// Without statistics, we assume a filter keeps 1/defaultSelectivity of its input.
+const defaultSelectivity = 3
+
+// EstimateRows returns the estimated row count the plan would produce.
+func EstimateRows(p Plan) int {
+	switch plan := p.(type) {
+	case *ScanPlan:
+		return EstimateRows(plan.Input)
+	case *TableScan:
+		dbInfo := storage.GetStorage().GetDbInfo(plan.SchemaName)
+		if dbInfo == nil || !dbInfo.HasTable(plan.Name) {
+			return 0
+		}
+		return dbInfo.GetTable(plan.Name).RowCount()
+	case *JoinPlan:
+		left, right := EstimateRows(plan.LeftPlan), EstimateRows(plan.RightPlan)
+		if plan.Expr == nil {
+			return left * right
+		}
+		// A full outer join returns every row of both sides at least once.
+		rows := math.Round(float64(left*right) * Selectivity(plan.Expr))
+		return int(math.Max(rows, math.Max(float64(left), float64(right))))
+	case *SelectionPlan:
+		return int(math.Round(float64(EstimateRows(plan.Input)) * Selectivity(plan.Expr)))
+	case *OrderByPlan:
+		return EstimateRows(plan.Input)
+	case *ProjectionPlan:
+		if plan.IsAggr() {
+			return 1
+		}
+		return EstimateRows(plan.Input)
+	case *LimitPlan:
+		rows := EstimateRows(plan.Input) - plan.Offset
+		if rows < 0 {
+			rows = 0
+		}
+		if rows > plan.Count {
+			rows = plan.Count
+		}
+		return rows
+	case *GroupByPlan:
+		return EstimateRows(plan.Input)
+	case *ReorderPlan:
+		return EstimateRows(plan.Input)
+	case *DerivedPlan:
+		return EstimateRows(plan.Input)
+	case *DistinctPlan:
+		return EstimateRows(plan.Input)
+	case *WindowPlan:
+		return EstimateRows(plan.Input)
+	case *RecursiveCTEPlan:
+		return EstimateRows(plan.Anchor)
+	case *WorkTablePlan:
+		return EstimateRows(plan.anchor)
+	case *SetOperationPlan:
+		left, right := EstimateRows(plan.LeftPlan), EstimateRows(plan.RightPlan)
+		switch plan.Tp {
+		case parser.UnionTp:
+			return left + right
+		case parser.IntersectTp:
+			return int(math.Min(float64(left), float64(right)))
+		default:
+			return left
+		}
+	case *SemiJoinPlan:
+		return EstimateRows(plan.LeftPlan) / defaultSelectivity
+	case *HavingPlan:
+		return EstimateRows(plan.Input) / defaultSelectivity
+	default:
+		return 0
+	}
+}
@@ -176,7 +176,7 @@ type LiteralExpr struct {
 }
 
 func (literal LiteralExpr) toField() storage.Field {
-	f := storage.Field{Name: string(literal.Data)}
+	f := storage.Field{Name: literal.String()}
 	f.TP = storage.InferenceType(literal.Data)
 	return f
 }
@@ -186,12 +186,16 @@ func (literal LiteralExpr) TypeCheck() error {
 }
 
 func (literal LiteralExpr) String() string {
+	// The null literal has an empty data.
+	if len(literal.Data) == 0 {
+		return "null"
+	}
 	return string(literal.Data)
 }
 
 func (literal LiteralExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
 	ret := &storage.ColumnVector{
-		Field: storage.Field{Name: string(literal.Data), TP: storage.InferenceType(literal.Data)},
+		Field: storage.Field{Name: literal.String(), TP: storage.InferenceType(literal.Data)},
 	}
 	for i := 0; i < input.RowCount(); i++ {
 		ret.Append(literal.Value())
@@ -1705,6 +1709,7 @@ func (orderBy OrderByExpr) Compute() []byte {
 // here.
 type FuncCallExpr struct {
 	FuncName string
+	Distinct bool
 	Params   []Expr
 	Name     string
 	Fn       FuncInterface `json:"-"`
@@ -1717,6 +1722,9 @@ func (call *FuncCallExpr) toField() storage.Field {
 func (call *FuncCallExpr) String() string {
 	bf := bytes.Buffer{}
 	bf.WriteString(call.FuncName + "(")
+	if call.Distinct {
+		bf.WriteString("distinct ")
+	}
 	for i, param := range call.Params {
 		bf.WriteString(param.String())
 		if i != len(call.Params)-1 {
@@ -1825,13 +1833,14 @@ func (call *FuncCallExpr) EvaluateRow(row int, input *storage.RecordBatch) []byt
 func (call *FuncCallExpr) Clone(cloneAccumulate bool) Expr {
 	ret := &FuncCallExpr{
 		FuncName: call.FuncName,
+		Distinct: call.Distinct,
 		Params:   make([]Expr, len(call.Params)),
 		Name:     call.Name,
 	}
 	for i, expr := range call.Params {
 		ret.Params[i] = expr.Clone(cloneAccumulate)
 	}
-	ret.Fn = getFunc(call.FuncName, call.Params)
+	ret.Fn = makeFunc(call.FuncName, call.Params, call.Distinct)
 	return ret
 }
 
@@ -1851,11 +1860,12 @@ func (call *FuncCallExpr) HasGroupFunc() bool {
 	return false
 }
 
-func MakeFuncCallExpr(name string, params []Expr) *FuncCallExpr {
+func MakeFuncCallExpr(name string, distinct bool, params []Expr) *FuncCallExpr {
 	return &FuncCallExpr{
 		FuncName: name,
+		Distinct: distinct,
 		Name:     name,
-		Fn:       getFunc(name, params),
+		Fn:       makeFunc(name, params, distinct),
 		Params:   params,
 	}
 }
@@ -0,0 +1,352 @@
+package plan
+
+import (
+	"github.com/xiaobogaga/minidb/storage"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SimplifyPlan simplifies the filter expressions of the type checked plan p, and removes the
+// filters which are always true.
+func SimplifyPlan(p Plan) Plan {
+	switch plan := p.(type) {
+	case *SelectionPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+		plan.Expr = SimplifyExpr(plan.Expr)
+		if value, ok := boolConstant(plan.Expr); ok && value {
+			return plan.Input
+		}
+	case *JoinPlan:
+		plan.LeftPlan = SimplifyPlan(plan.LeftPlan)
+		plan.RightPlan = SimplifyPlan(plan.RightPlan)
+		if plan.Expr != nil {
+			plan.Expr = SimplifyExpr(plan.Expr)
+		}
+	case *ReorderPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *OrderByPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *ProjectionPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *LimitPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *GroupByPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *HavingPlan:
+		plan.Input = SimplifyPlan(plan.Input).(*GroupByPlan)
+		plan.Expr = SimplifyExpr(plan.Expr)
+	case *DerivedPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *DistinctPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *WindowPlan:
+		plan.Input = SimplifyPlan(plan.Input)
+	case *RecursiveCTEPlan:
+		plan.Anchor = SimplifyPlan(plan.Anchor)
+		plan.Recursive = SimplifyPlan(plan.Recursive)
+	case *SetOperationPlan:
+		plan.LeftPlan = SimplifyPlan(plan.LeftPlan)
+		plan.RightPlan = SimplifyPlan(plan.RightPlan)
+	case *SemiJoinPlan:
+		plan.LeftPlan = SimplifyPlan(plan.LeftPlan)
+		plan.RightPlan = SimplifyPlan(plan.RightPlan)
+		if plan.Expr != nil {
+			plan.Expr = SimplifyExpr(plan.Expr)
+		}
+		if plan.In != nil {
+			plan.In = SimplifyExpr(plan.In)
+		}
+	}
+	return p
+}
+
+// SimplifyExpr rewrites the type checked expr bottom up:
+// * sub expressions only having literals are folded to a literal, like 1 + 2 to 3.
+// * boolean identities are simplified, like true and x to x, false and x to false.
+// * literals are moved to the right of comparisons, like 1 < x to x > 1.
+func SimplifyExpr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case NegativeExpr:
+		e.Expr = SimplifyExpr(e.Expr)
+		expr = e
+	case AddExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case MinusExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case MulExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case DivideExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case ModExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case IsExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case EqualExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			e.Left, e.Right = e.Right, e.Left
+		}
+		expr = e
+	case NotEqualExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			e.Left, e.Right = e.Right, e.Left
+		}
+		expr = e
+	case GreatExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			return LessExpr{Left: e.Right, Right: e.Left, Name: "<"}
+		}
+		expr = e
+	case GreatEqualExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			return LessEqualExpr{Left: e.Right, Right: e.Left, Name: "<="}
+		}
+		expr = e
+	case LessExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			return GreatExpr{Left: e.Right, Right: e.Left, Name: ">"}
+		}
+		expr = e
+	case LessEqualExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if isConstant(e.Left) && !isConstant(e.Right) {
+			return GreatEqualExpr{Left: e.Right, Right: e.Left, Name: ">="}
+		}
+		expr = e
+	case AndExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if value, ok := boolConstant(e.Left); ok {
+			if value {
+				return e.Right
+			}
+			return e.Left
+		}
+		if value, ok := boolConstant(e.Right); ok {
+			if value {
+				return e.Left
+			}
+			return e.Right
+		}
+		expr = e
+	case OrExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		if value, ok := boolConstant(e.Left); ok {
+			if value {
+				return e.Left
+			}
+			return e.Right
+		}
+		if value, ok := boolConstant(e.Right); ok {
+			if value {
+				return e.Right
+			}
+			return e.Left
+		}
+		expr = e
+	case NotExpr:
+		e.Expr = SimplifyExpr(e.Expr)
+		expr = e
+	case IsNotExpr:
+		e.Left, e.Right = SimplifyExpr(e.Left), SimplifyExpr(e.Right)
+		expr = e
+	case InExpr:
+		e.Expr = SimplifyExpr(e.Expr)
+		e.Values = simplifyExprs(e.Values)
+		expr = e
+	case BetweenExpr:
+		e.Expr, e.Low, e.High = SimplifyExpr(e.Expr), SimplifyExpr(e.Low), SimplifyExpr(e.High)
+		expr = e
+	case LikeExpr:
+		e.Expr, e.Pattern = SimplifyExpr(e.Expr), SimplifyExpr(e.Pattern)
+		if e.Escape != nil {
+			e.Escape = SimplifyExpr(e.Escape)
+		}
+		expr = e
+	case CaseExpr:
+		if e.Value != nil {
+			e.Value = SimplifyExpr(e.Value)
+		}
+		e.Whens, e.Thens = simplifyExprs(e.Whens), simplifyExprs(e.Thens)
+		if e.Else != nil {
+			e.Else = SimplifyExpr(e.Else)
+		}
+		expr = e
+	case IfExpr:
+		e.Params = simplifyExprs(e.Params)
+		expr = e
+	case CoalesceExpr:
+		e.Params = simplifyExprs(e.Params)
+		expr = e
+	case NullIfExpr:
+		e.Params = simplifyExprs(e.Params)
+		expr = e
+	case *InSubQueryExpr:
+		// The subquery is simplified when it's made.
+		e.Expr = SimplifyExpr(e.Expr)
+	case *FuncCallExpr:
+		// The params are shared with the function, so update them in place.
+		for i, param := range e.Params {
+			e.Params[i] = SimplifyExpr(param)
+		}
+	}
+	return foldConstant(expr)
+}
+
+// simplifyExprs returns the simplified exprs in a new slice, exprs is shared with the original expr.
+func simplifyExprs(exprs []Expr) []Expr {
+	ret := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		ret[i] = SimplifyExpr(expr)
+	}
+	return ret
+}
+
+// isConstant returns true if expr is a literal or a negative literal.
+func isConstant(expr Expr) bool {
+	switch e := expr.(type) {
+	case LiteralExpr:
+		return true
+	case NegativeExpr:
+		_, ok := e.Expr.(LiteralExpr)
+		return ok
+	default:
+		return false
+	}
+}
+
+// constantValue returns the value and type of expr if it's a constant.
+func constantValue(expr Expr) ([]byte, storage.FieldTP, bool) {
+	if !isConstant(expr) {
+		return nil, storage.FieldTP{}, false
+	}
+	value, err := expr.Compute()
+	if err != nil {
+		return nil, storage.FieldTP{}, false
+	}
+	return value, expr.toField().TP, true
+}
+
+func boolConstant(expr Expr) (value bool, ok bool) {
+	literal, ok := expr.(LiteralExpr)
+	if !ok || literal.toField().TP.Name != storage.Bool {
+		return false, false
+	}
+	return storage.DecodeBool(literal.Value()), true
+}
+
+func operands(expr Expr) []Expr {
+	switch e := expr.(type) {
+	case NegativeExpr:
+		return []Expr{e.Expr}
+	case AddExpr:
+		return []Expr{e.Left, e.Right}
+	case MinusExpr:
+		return []Expr{e.Left, e.Right}
+	case MulExpr:
+		return []Expr{e.Left, e.Right}
+	case DivideExpr:
+		return []Expr{e.Left, e.Right}
+	case ModExpr:
+		return []Expr{e.Left, e.Right}
+	case IsExpr:
+		return []Expr{e.Left, e.Right}
+	case EqualExpr:
+		return []Expr{e.Left, e.Right}
+	case NotEqualExpr:
+		return []Expr{e.Left, e.Right}
+	case GreatExpr:
+		return []Expr{e.Left, e.Right}
+	case GreatEqualExpr:
+		return []Expr{e.Left, e.Right}
+	case LessExpr:
+		return []Expr{e.Left, e.Right}
+	case LessEqualExpr:
+		return []Expr{e.Left, e.Right}
+	case AndExpr:
+		return []Expr{e.Left, e.Right}
+	case OrExpr:
+		return []Expr{e.Left, e.Right}
+	case paramsExpr:
+		return e.params()
+	default:
+		return nil
+	}
+}
+
+// foldConstant computes expr if all its operands are constants.
+func foldConstant(expr Expr) Expr {
+	params := operands(expr)
+	if len(params) == 0 || isConstant(expr) {
+		return expr
+	}
+	for _, param := range params {
+		if !isConstant(param) {
+			return expr
+		}
+	}
+	value, err := expr.Compute()
+	if err != nil {
+		return expr
+	}
+	ret, ok := makeConstant(value, expr.toField().TP)
+	if !ok {
+		return expr
+	}
+	return ret
+}
+
+// makeConstant makes a literal from value. Literals cannot be negative, so negative numbers
+// are negative literals.
+func makeConstant(value []byte, tp storage.FieldTP) (Expr, bool) {
+	// The null literal has no type, so null values keep their expressions.
+	if len(value) == 0 {
+		return nil, false
+	}
+	var data string
+	negative := false
+	switch tp.Name {
+	case storage.Int:
+		v := storage.DecodeInt(value)
+		if v == math.MinInt64 {
+			return nil, false
+		}
+		if v < 0 {
+			negative, v = true, -v
+		}
+		data = strconv.FormatInt(v, 10)
+	case storage.Float:
+		v := storage.DecodeFloat(value)
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, false
+		}
+		if v < 0 {
+			negative, v = true, -v
+		}
+		data = strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(data, ".") {
+			data += ".0"
+		}
+	case storage.Bool:
+		data = strconv.FormatBool(storage.DecodeBool(value))
+	case storage.Text:
+		data = "'" + string(value) + "'"
+	default:
+		return nil, false
+	}
+	literal := LiteralExpr{Data: []byte(data), Str: data}
+	if negative {
+		return NegativeExpr{Expr: literal}, true
+	}
+	return literal, true
+}
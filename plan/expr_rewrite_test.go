@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xiaobogaga/minidb/parser"
+	"testing"
+)
+
+func TestSimplifyExpr(t *testing.T) {
+	initTestStorage(t)
+	tests := []struct {
+		where  string
+		expect string
+	}{
+		{"id > 1 + 2", "id > 3"},
+		{"1 + 2 < id", "id > 3"},
+		{"3 >= id", "id <= 3"},
+		{"1 = id", "id = 1"},
+		{"id = 1 - 3", "id = -2"},
+		{"id = -(-5)", "id = 5"},
+		{"age > 1.5 + 1.5", "age > 3.0"},
+		{"true and id = 1", "id = 1"},
+		{"id = 1 and false", "false"},
+		{"id = 1 or 1 = 2", "id = 1"},
+		{"(1 < 2) and (id < 3 * 2)", "id < 6"},
+		{"id = 1 or (2 > 1 and sex)", "id = 1 or sex"},
+		{"id = 1 or 2 > 1", "true"},
+	}
+	for _, test := range tests {
+		sel := testJoinOrderPlan(t, "select * from test1 where "+test.where+";").(*SelectionPlan)
+		assert.Equal(t, test.expect, SimplifyExpr(sel.Expr).String(), test.where)
+	}
+	p := SimplifyPlan(testJoinOrderPlan(t, "select * from test1 where 1 = 1 and true;"))
+	assert.Equal(t, "Scan", explainOperator(p))
+
+	// Having and the filters below it are simplified too.
+	p, err := MakePlan(toTestStm(t, "select id, count(age) from test1 where true group by id having id > 1 - 1;").(*parser.SelectStm), "db1")
+	assert.Nil(t, err)
+	having := SimplifyPlan(p).(*HavingPlan)
+	assert.Equal(t, "id > 0", having.Expr.String())
+	assert.Equal(t, "Scan", explainOperator(having.Input.Input))
+
+	testSelect(t, "select * from test1 where 1 + 1 > id;", 2, false)
+	testSelect(t, "select id, count(age) from test1 group by id having 1 - 1 < id;", testDataSize-1, false)
+	testSelect(t, "select * from test1 where 1 = 1;", testDataSize, false)
+	testSelect(t, "select * from test1 where id < 0 - 1 or 1 = 2;", 0, false)
+}
@@ -26,6 +26,15 @@ func getFunc(name string, params []Expr) FuncInterface {
 	}
 }
 
+// makeFunc returns the function name, which only accumulates distinct values if distinct.
+func makeFunc(name string, params []Expr, distinct bool) FuncInterface {
+	f := getFunc(name, params)
+	if f == nil || !distinct {
+		return f
+	}
+	return &DistinctFunc{FuncInterface: f, Name: strings.ToUpper(name), Params: params}
+}
+
 type FuncInterface interface {
 	TypeCheck() error
 	FuncParamSize() int
@@ -311,6 +320,10 @@ func (sum *SumFunc) ReturnType() storage.FieldTP {
 
 func (sum *SumFunc) Accumulate(row int, input *storage.RecordBatch) {
 	data := sum.Params[0].EvaluateRow(row, input)
+	// Nulls are skipped.
+	if len(data) == 0 {
+		return
+	}
 	if len(sum.Accumulator) == 0 {
 		sum.Accumulator = data
 		return
@@ -339,3 +352,60 @@ func (sum *SumFunc) String() string {
 	bf.WriteString(")")
 	return bf.String()
 }
+
+// DistinctFunc wraps an aggregation function like count(distinct id), the function only
+// accumulates the rows having a new value, nulls are ignored.
+type DistinctFunc struct {
+	FuncInterface
+	Name   string
+	Params []Expr
+	seen   map[string]bool
+}
+
+func (distinct *DistinctFunc) TypeCheck() error {
+	if distinct.Name != "COUNT" && distinct.Name != "SUM" {
+		return errors.New(fmt.Sprintf("%s: distinct is not supported", distinct.String()))
+	}
+	err := distinct.FuncInterface.TypeCheck()
+	if err != nil {
+		return err
+	}
+	if distinct.Params[0].toField().IsMultiple() {
+		return errors.New(fmt.Sprintf("%s: param type doesn't match", distinct.String()))
+	}
+	return nil
+}
+
+func (distinct *DistinctFunc) Accumulate(row int, input *storage.RecordBatch) {
+	value := distinct.Params[0].EvaluateRow(row, input)
+	if len(value) == 0 || distinct.seen[string(value)] {
+		return
+	}
+	if distinct.seen == nil {
+		distinct.seen = map[string]bool{}
+	}
+	distinct.seen[string(value)] = true
+	distinct.FuncInterface.Accumulate(row, input)
+}
+
+func (distinct *DistinctFunc) AccumulateValue() []byte {
+	value := distinct.FuncInterface.AccumulateValue()
+	if len(value) == 0 && distinct.Name == "COUNT" {
+		return storage.EncodeInt(0)
+	}
+	return value
+}
+
+func (distinct *DistinctFunc) String() string {
+	bf := bytes.Buffer{}
+	bf.WriteString(distinct.Name)
+	bf.WriteString("(distinct ")
+	for i, param := range distinct.Params {
+		bf.WriteString(param.String())
+		if i != len(distinct.Params)-1 {
+			bf.WriteString(", ")
+		}
+	}
+	bf.WriteString(")")
+	return bf.String()
+}
@@ -0,0 +1,597 @@
+package plan
+
+import (
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+	"math"
+	"math/bits"
+)
+
+// The join order of at most dpJoinReorderLimit tables is searched by dynamic programming,
+// more tables are joined greedily.
+var dpJoinReorderLimit = 8
+
+// Without statistics, we assume a = b keeps 1/defaultEqualSelectivity of its input.
+const defaultEqualSelectivity = 10
+
+// A joinUnit is a relation the optimizer can reorder, it's a table or an outer join chain.
+type joinUnit struct {
+	Plan    Plan
+	Rows    float64 // The estimated rows after filtered by its own predicates.
+	Columns int
+}
+
+// A joinCond is a predicate from where or the on clause of inner joins.
+type joinCond struct {
+	Stm *parser.ExpressionStm
+	// Units is a bitmap of the units referred by this predicate. Predicates referring to unknown
+	// columns or nothing are bound to all units, so they are evaluated above the top join.
+	Units       uint64
+	Selectivity float64
+}
+
+// A joinNode is a node of the join tree searched by the optimizer.
+type joinNode struct {
+	Units       uint64
+	Unit        int // Only for leaf.
+	Left, Right *joinNode
+	Rows        float64
+	Cost        float64
+}
+
+type joinOrderer struct {
+	units []*joinUnit
+	conds []*joinCond
+	scope *queryScope
+}
+
+// makeOrderedJoinPlan builds the join plan of tableRefs with where, the inner joins are
+// reordered by estimated cost and predicates are pushed down to the lowest join they can.
+func makeOrderedJoinPlan(tableRefs []parser.TableReferenceStm, where parser.WhereStm, scope *queryScope) (Plan, error) {
+	orderer := &joinOrderer{scope: scope}
+	for _, tableRef := range tableRefs {
+		err := orderer.addTableRef(tableRef, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// A bitmap can hold at most 64 units.
+	if len(orderer.units) > 64 {
+		scanPlans, err := makeScanPlans(tableRefs, scope)
+		if err != nil {
+			return nil, err
+		}
+		return makeSelectPlan(makeJoinPlan(scanPlans), where, scope), nil
+	}
+	if where != nil {
+		orderer.addCond(where)
+	}
+	for _, unit := range orderer.units {
+		err := unit.Plan.TypeCheck()
+		if err != nil {
+			return nil, err
+		}
+		unit.Columns = len(unit.Plan.Schema().Columns)
+	}
+	semiJoins, err := orderer.extractSemiJoins()
+	if err != nil {
+		return nil, err
+	}
+	orderer.estimate()
+	plan := orderer.build(orderer.bestOrder())
+	for _, semi := range semiJoins {
+		plan = semi.build(plan, orderer.scope)
+	}
+	return plan, nil
+}
+
+func (orderer *joinOrderer) addTableRef(tableRef parser.TableReferenceStm, scope *queryScope) error {
+	if tableRef.Tp == parser.TableReferenceTableFactorTp {
+		return orderer.addTableFactor(tableRef.TableReference.(parser.TableReferenceTableFactorStm), scope)
+	}
+	joinTable := tableRef.TableReference.(parser.JoinedTableStm)
+	if !isInnerJoinChain(joinTable) {
+		// Outer joins cannot be reordered, so the whole chain is a unit.
+		plan, err := makeScanPlanForJoin(joinTable, scope)
+		if err != nil {
+			return err
+		}
+		orderer.units = append(orderer.units, &joinUnit{Plan: plan})
+		return nil
+	}
+	err := orderer.addTableFactor(joinTable.TableFactor, scope)
+	if err != nil {
+		return err
+	}
+	for _, joinFactor := range joinTable.JoinFactors {
+		err = orderer.addTableFactor(joinFactor.JoinedTableReference.TableReference.(parser.TableReferenceTableFactorStm), scope)
+		if err != nil {
+			return err
+		}
+		if joinFactor.JoinSpec != nil {
+			orderer.addCond(joinFactor.JoinSpec.Condition.(*parser.ExpressionStm))
+		}
+	}
+	return nil
+}
+
+func (orderer *joinOrderer) addTableFactor(tableFactor parser.TableReferenceTableFactorStm, scope *queryScope) error {
+	plan, err := makeScanPlan(tableFactor, scope)
+	if err != nil {
+		return err
+	}
+	orderer.units = append(orderer.units, &joinUnit{Plan: plan})
+	return nil
+}
+
+// An inner join chain only has inner joins with on or without join specification.
+func isInnerJoinChain(joinTable parser.JoinedTableStm) bool {
+	for _, joinFactor := range joinTable.JoinFactors {
+		if joinFactor.JoinTp != parser.InnerJoin {
+			return false
+		}
+		if joinFactor.JoinSpec != nil && joinFactor.JoinSpec.Tp != parser.JoinSpecificationON {
+			return false
+		}
+	}
+	return true
+}
+
+// addCond splits expr by and and saves each predicate.
+func (orderer *joinOrderer) addCond(expr *parser.ExpressionStm) {
+	for _, conjunct := range splitConjuncts(expr) {
+		orderer.conds = append(orderer.conds, &joinCond{Stm: conjunct})
+	}
+}
+
+func splitConjuncts(expr *parser.ExpressionStm) []*parser.ExpressionStm {
+	if expr.Op == nil {
+		if expr.RightExpr == nil {
+			if sub := subExprStm(expr.LeftExpr); sub != nil {
+				return splitConjuncts(sub)
+			}
+		}
+		return []*parser.ExpressionStm{expr}
+	}
+	if expr.Op.Tp != parser.AND {
+		return []*parser.ExpressionStm{expr}
+	}
+	return append(splitConjuncts(toExprStm(expr.LeftExpr)), splitConjuncts(toExprStm(expr.RightExpr))...)
+}
+
+// subExprStm returns the expression inside the parentheses if expr is like (expression).
+func subExprStm(expr interface{}) *parser.ExpressionStm {
+	term, ok := expr.(*parser.ExpressionTerm)
+	if !ok || term.Tp != parser.SubExpressionTermTP || term.UnaryOp != parser.NoneUnaryOpTp {
+		return nil
+	}
+	return term.RealExprTerm.(*parser.ExpressionStm)
+}
+
+func toExprStm(expr interface{}) *parser.ExpressionStm {
+	if exprStm, ok := expr.(*parser.ExpressionStm); ok {
+		return exprStm
+	}
+	return &parser.ExpressionStm{LeftExpr: expr}
+}
+
+// walkExprStm calls fn with every term of expr, the terms in subqueries are not walked.
+func walkExprStm(expr interface{}, fn func(term *parser.ExpressionTerm)) {
+	switch e := expr.(type) {
+	case *parser.ExpressionStm:
+		walkExprStm(e.LeftExpr, fn)
+		if e.RightExpr != nil {
+			walkExprStm(e.RightExpr, fn)
+		}
+	case *parser.ExpressionTerm:
+		fn(e)
+		switch e.Tp {
+		case parser.SubExpressionTermTP:
+			walkExprStm(e.RealExprTerm, fn)
+		case parser.FuncCallExpressionTermTP:
+			for _, param := range e.RealExprTerm.(parser.FunctionCallExpressionStm).Params {
+				walkExprStm(param, fn)
+			}
+		case parser.ListExpressionTermTP:
+			for _, item := range e.RealExprTerm.(parser.ExpressionListStm) {
+				walkExprStm(item, fn)
+			}
+		case parser.CaseExpressionTermTP:
+			caseExpr := e.RealExprTerm.(parser.CaseExpressionStm)
+			items := append(append([]*parser.ExpressionStm{caseExpr.Value}, caseExpr.Whens...), caseExpr.Thens...)
+			for _, item := range append(items, caseExpr.Else) {
+				if item != nil {
+					walkExprStm(item, fn)
+				}
+			}
+		}
+	}
+}
+
+// collectIdentifiers returns the column names referred by expr.
+func collectIdentifiers(expr interface{}) (ret []string) {
+	walkExprStm(expr, func(term *parser.ExpressionTerm) {
+		if term.Tp == parser.IdentifierExpressionTermTP {
+			ret = append(ret, string(term.RealExprTerm.(parser.IdentifierExpression)))
+		}
+	})
+	return
+}
+
+// hasSubQuery returns true if expr has a subquery.
+func hasSubQuery(expr interface{}) (ret bool) {
+	walkExprStm(expr, func(term *parser.ExpressionTerm) {
+		ret = ret || term.Tp == parser.SubQueryExpressionTermTP || term.Tp == parser.ExistsSubQueryExpressionTermTP
+	})
+	return
+}
+
+// hasAggrFunc returns true if expr has an aggregation function.
+func hasAggrFunc(expr interface{}) (ret bool) {
+	walkExprStm(expr, func(term *parser.ExpressionTerm) {
+		if term.Tp != parser.FuncCallExpressionTermTP {
+			return
+		}
+		f := getFunc(term.RealExprTerm.(parser.FunctionCallExpressionStm).FuncName, nil)
+		ret = ret || (f != nil && f.IsAggrFunc())
+	})
+	return
+}
+
+func (orderer *joinOrderer) allUnits() uint64 {
+	return 1<<uint(len(orderer.units)) - 1
+}
+
+// condUnits returns the units referred by cond. The columns of outer queries are constants, and
+// the predicates having subqueries are bound to all units, the subqueries may refer any unit.
+func (orderer *joinOrderer) condUnits(cond *parser.ExpressionStm) uint64 {
+	if hasSubQuery(cond) {
+		return orderer.allUnits()
+	}
+	ret := uint64(0)
+	for _, ident := range collectIdentifiers(cond) {
+		schemaName, tableName, columnName := getSchemaTableColumnName(ident)
+		found := uint64(0)
+		for i, unit := range orderer.units {
+			if unit.Plan.Schema().HasColumn(schemaName, tableName, columnName) {
+				found |= 1 << uint(i)
+			}
+		}
+		if found == 0 && orderer.scope.findOuter(ident) != nil {
+			continue
+		}
+		// Unknown or ambiguous columns, let the type check report it.
+		if bits.OnesCount64(found) != 1 {
+			return orderer.allUnits()
+		}
+		ret |= found
+	}
+	if ret == 0 {
+		return orderer.allUnits()
+	}
+	return ret
+}
+
+// joinPlanOf joins the units in FROM order, it's only used to bind expressions.
+func (orderer *joinOrderer) joinPlanOf(units uint64) Plan {
+	var plans []Plan
+	for i, unit := range orderer.units {
+		if units&(1<<uint(i)) != 0 {
+			plans = append(plans, unit.Plan)
+		}
+	}
+	return makeJoinPlan(plans)
+}
+
+func (orderer *joinOrderer) estimate() {
+	for _, unit := range orderer.units {
+		unit.Rows = float64(EstimateRows(unit.Plan))
+	}
+	for _, cond := range orderer.conds {
+		cond.Units = orderer.condUnits(cond.Stm)
+		cond.Selectivity = Selectivity(ExprStmToExpr(cond.Stm, orderer.joinPlanOf(cond.Units), orderer.scope))
+		if bits.OnesCount64(cond.Units) == 1 {
+			unit := orderer.units[bits.TrailingZeros64(cond.Units)]
+			unit.Rows *= cond.Selectivity
+		}
+	}
+}
+
+// rows returns the estimated rows of joining units.
+func (orderer *joinOrderer) rows(units uint64) float64 {
+	rows := 1.0
+	for i, unit := range orderer.units {
+		if units&(1<<uint(i)) != 0 {
+			rows *= unit.Rows
+		}
+	}
+	for _, cond := range orderer.conds {
+		if bits.OnesCount64(cond.Units) > 1 && cond.Units&units == cond.Units {
+			rows *= cond.Selectivity
+		}
+	}
+	return rows
+}
+
+func (orderer *joinOrderer) leaf(i int) *joinNode {
+	unit := orderer.units[i]
+	return &joinNode{Units: 1 << uint(i), Unit: i, Rows: unit.Rows, Cost: estimateCost(unit.Plan)}
+}
+
+// estimateCost is the estimated rows produced by all operators of p.
+func estimateCost(p Plan) float64 {
+	ret := float64(EstimateRows(p))
+	for _, child := range p.Child() {
+		ret += estimateCost(child)
+	}
+	return ret
+}
+
+// join estimates the cost of a nested loop join: the right input is executed once for every
+// batch of the left input, and every pair of rows is evaluated.
+func (orderer *joinOrderer) join(left, right *joinNode) *joinNode {
+	leftBatches := math.Max(1, math.Ceil(left.Rows/float64(batchSize)))
+	return &joinNode{
+		Units: left.Units | right.Units,
+		Left:  left,
+		Right: right,
+		Rows:  orderer.rows(left.Units | right.Units),
+		Cost:  left.Cost + right.Cost*leftBatches + left.Rows*right.Rows,
+	}
+}
+
+// bestOrder returns the cheapest join tree, the FROM order is kept unless another order is cheaper.
+func (orderer *joinOrderer) bestOrder() *joinNode {
+	ret := orderer.leaf(0)
+	for i := 1; i < len(orderer.units); i++ {
+		ret = orderer.join(ret, orderer.leaf(i))
+	}
+	var best *joinNode
+	if len(orderer.units) <= dpJoinReorderLimit {
+		best = orderer.dpOrder()
+	} else {
+		best = orderer.greedyOrder()
+	}
+	if best.Cost < ret.Cost {
+		return best
+	}
+	return ret
+}
+
+// dpOrder searches all join trees by dynamic programming over subsets of units.
+func (orderer *joinOrderer) dpOrder() *joinNode {
+	best := make([]*joinNode, orderer.allUnits()+1)
+	for i := range orderer.units {
+		best[1<<uint(i)] = orderer.leaf(i)
+	}
+	for units := uint64(1); units <= orderer.allUnits(); units++ {
+		if bits.OnesCount64(units) < 2 {
+			continue
+		}
+		// Enumerate the proper subsets in ascending order, so FROM order wins on ties.
+		for left := -units & units; left != units; left = (left - units) & units {
+			node := orderer.join(best[left], best[units^left])
+			if best[units] == nil || node.Cost < best[units].Cost {
+				best[units] = node
+			}
+		}
+	}
+	return best[orderer.allUnits()]
+}
+
+// greedyOrder repeatedly joins the pair of trees with the smallest result, and the lowest cost on ties.
+func (orderer *joinOrderer) greedyOrder() *joinNode {
+	var nodes []*joinNode
+	for i := range orderer.units {
+		nodes = append(nodes, orderer.leaf(i))
+	}
+	for len(nodes) > 1 {
+		var best *joinNode
+		bestI, bestJ := 0, 0
+		for i := range nodes {
+			for j := range nodes {
+				if i == j {
+					continue
+				}
+				node := orderer.join(nodes[i], nodes[j])
+				if best == nil || node.Rows < best.Rows || (node.Rows == best.Rows && node.Cost < best.Cost) {
+					best, bestI, bestJ = node, i, j
+				}
+			}
+		}
+		var remain []*joinNode
+		for i, node := range nodes {
+			if i != bestI && i != bestJ {
+				remain = append(remain, node)
+			}
+		}
+		nodes = append(remain, best)
+	}
+	return nodes[0]
+}
+
+// build makes the plan of the join tree. The columns are reordered to FROM order if needed,
+// so select * is not affected by the join order.
+func (orderer *joinOrderer) build(root *joinNode) Plan {
+	plan, leaves := orderer.buildNode(root)
+	offsets := make([]int, len(orderer.units))
+	offset := 0
+	for _, leaf := range leaves {
+		offsets[leaf] = offset
+		offset += orderer.units[leaf].Columns
+	}
+	var order []int
+	reordered := false
+	for i, unit := range orderer.units {
+		for j := 0; j < unit.Columns; j++ {
+			if offsets[i]+j != len(order) {
+				reordered = true
+			}
+			order = append(order, offsets[i]+j)
+		}
+	}
+	if !reordered {
+		return plan
+	}
+	return &ReorderPlan{Input: plan, Order: order}
+}
+
+// buildNode returns the plan of node and its units in the order of the plan columns.
+func (orderer *joinOrderer) buildNode(node *joinNode) (plan Plan, leaves []int) {
+	if node.Left == nil {
+		plan, leaves = orderer.units[node.Unit].Plan, []int{node.Unit}
+	} else {
+		left, leftLeaves := orderer.buildNode(node.Left)
+		right, rightLeaves := orderer.buildNode(node.Right)
+		plan, leaves = NewJoinPlan(left, right, parser.InnerJoin), append(leftLeaves, rightLeaves...)
+	}
+	var expr Expr
+	for _, cond := range orderer.conds {
+		if cond.Units&node.Units != cond.Units {
+			continue
+		}
+		if node.Left != nil && (cond.Units&node.Left.Units == cond.Units || cond.Units&node.Right.Units == cond.Units) {
+			continue
+		}
+		condExpr := ExprStmToExpr(cond.Stm, plan, orderer.scope)
+		if expr == nil {
+			expr = condExpr
+			continue
+		}
+		expr = AndExpr{Left: expr, Right: condExpr, Name: "and"}
+	}
+	if expr != nil {
+		plan = &SelectionPlan{Input: plan, Expr: expr}
+	}
+	return
+}
+
+// Selectivity estimates the fraction of input rows kept by the predicate expr.
+func Selectivity(expr Expr) float64 {
+	switch e := expr.(type) {
+	case AndExpr:
+		return Selectivity(e.Left) * Selectivity(e.Right)
+	case OrExpr:
+		left, right := Selectivity(e.Left), Selectivity(e.Right)
+		return left + right - left*right
+	case EqualExpr:
+		return equalSelectivity(e.Left, e.Right)
+	case NotEqualExpr:
+		return 1 - equalSelectivity(e.Left, e.Right)
+	case LessExpr:
+		return lessSelectivity(e.Left, e.Right, false)
+	case LessEqualExpr:
+		return lessSelectivity(e.Left, e.Right, true)
+	case GreatExpr:
+		return lessSelectivity(e.Right, e.Left, false)
+	case GreatEqualExpr:
+		return lessSelectivity(e.Right, e.Left, true)
+	case NotExpr:
+		return 1 - Selectivity(e.Expr)
+	case InExpr:
+		ret := 0.0
+		for _, value := range e.Values {
+			ret += equalSelectivity(e.Expr, value)
+		}
+		if e.Not {
+			return 1 - math.Min(ret, 1)
+		}
+		return math.Min(ret, 1)
+	case LiteralExpr:
+		if value, ok := boolConstant(e); ok {
+			if value {
+				return 1
+			}
+			return 0
+		}
+		return 1.0 / defaultSelectivity
+	default:
+		return 1.0 / defaultSelectivity
+	}
+}
+
+// columnStats returns the statistics of the column expr refers to, or nil if expr isn't a
+// column or its table isn't analyzed.
+func columnStats(expr Expr) (*storage.ColumnStats, int) {
+	ident, ok := expr.(*IdentifierExpr)
+	if !ok || ident.input == nil {
+		return nil, 0
+	}
+	schemaName, tableName, columnName := getSchemaTableColumnName(string(ident.Ident))
+	schema := ident.input.Schema()
+	if !schema.HasColumn(schemaName, tableName, columnName) || schema.HasAmbiguousColumn(schemaName, tableName, columnName) {
+		return nil, 0
+	}
+	field := ident.toField()
+	dbInfo := storage.GetStorage().GetDbInfo(field.SchemaName)
+	if dbInfo == nil || !dbInfo.HasTable(field.TableName) {
+		return nil, 0
+	}
+	stats := dbInfo.GetTable(field.TableName).Stats
+	if stats == nil || stats.RowCount == 0 {
+		return nil, 0
+	}
+	colStats := stats.GetColumnStats(field.Name)
+	if colStats == nil {
+		return nil, 0
+	}
+	return colStats, stats.RowCount
+}
+
+func equalSelectivity(left, right Expr) float64 {
+	if isConstant(left) {
+		left, right = right, left
+	}
+	leftStats, rowCount := columnStats(left)
+	if value, tp, ok := constantValue(right); ok && leftStats != nil {
+		sel, ok := leftStats.EqualSelectivity(value, tp, rowCount)
+		if ok {
+			return sel
+		}
+	}
+	rightStats, _ := columnStats(right)
+	if leftStats != nil && rightStats != nil {
+		distinct := math.Max(float64(leftStats.DistinctCount), float64(rightStats.DistinctCount))
+		if distinct > 0 {
+			return 1 / distinct
+		}
+	}
+	return 1.0 / defaultEqualSelectivity
+}
+
+// lessSelectivity estimates left < right, or left <= right if orEqual.
+func lessSelectivity(left, right Expr, orEqual bool) float64 {
+	if value, tp, ok := constantValue(right); ok {
+		stats, rowCount := columnStats(left)
+		if stats == nil {
+			return 1.0 / defaultSelectivity
+		}
+		less, ok := stats.LessSelectivity(value, tp, rowCount)
+		if !ok {
+			return 1.0 / defaultSelectivity
+		}
+		if orEqual {
+			equal, _ := stats.EqualSelectivity(value, tp, rowCount)
+			less += equal
+		}
+		return math.Min(less, 1)
+	}
+	if value, tp, ok := constantValue(left); ok {
+		stats, rowCount := columnStats(right)
+		if stats == nil {
+			return 1.0 / defaultSelectivity
+		}
+		less, ok := stats.LessSelectivity(value, tp, rowCount)
+		if !ok {
+			return 1.0 / defaultSelectivity
+		}
+		equal, _ := stats.EqualSelectivity(value, tp, rowCount)
+		if orEqual {
+			equal = 0
+		}
+		// literal < column is neither less nor equal and not null.
+		great := 1 - less - equal - float64(stats.NullCount)/float64(rowCount)
+		return math.Max(great, 0)
+	}
+	return 1.0 / defaultSelectivity
+}
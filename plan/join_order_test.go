@@ -0,0 +1,86 @@
+package plan
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xiaobogaga/minidb/parser"
+	"testing"
+)
+
+func testJoinOrderPlan(t *testing.T, sql string) Plan {
+	stm := toTestStm(t, sql)
+	p, err := MakePlan(stm.(*parser.SelectStm), "db1")
+	assert.Nil(t, err)
+	// Skip the projection.
+	return p.Child()[0]
+}
+
+func TestMakeOrderedJoinPlan(t *testing.T) {
+	initTestStorage(t)
+	// Joining test1 with db2.test1 first avoids the cross product of test1 and test2.
+	sql := "select * from test1, test2, db2.test1 where db1.test1.id = db2.test1.id;"
+	p := testJoinOrderPlan(t, sql)
+	reorder, ok := p.(*ReorderPlan)
+	assert.True(t, ok)
+	join := reorder.Input.(*JoinPlan)
+	assert.Equal(t, "db1.test2", explainDetails(join.RightPlan)[len("table: "):])
+	sel := join.LeftPlan.(*SelectionPlan)
+	assert.Equal(t, "db1.test1.id = db2.test1.id", sel.Expr.String())
+	// Columns are still in FROM order.
+	schema := reorder.Schema()
+	columns := len(schema.Columns) / 3
+	assert.Equal(t, "test1", schema.Columns[0].TableName)
+	assert.Equal(t, "test2", schema.Columns[columns].TableName)
+	assert.Equal(t, "db2", schema.Columns[2*columns].SchemaName)
+	testSelect(t, sql, testDataSize*testDataSize, false)
+
+	// Predicates of a table are pushed down to the table, and the smaller one is joined first.
+	p = testJoinOrderPlan(t, "select * from test1 join test2 on test1.id = test2.id where test2.id > 1 and test1.name != 'a';")
+	sel = p.(*ReorderPlan).Input.(*SelectionPlan)
+	assert.Equal(t, "test1.id = test2.id", sel.Expr.String())
+	join = sel.Input.(*JoinPlan)
+	assert.Equal(t, "test2.id > 1", join.LeftPlan.(*SelectionPlan).Expr.String())
+	assert.Equal(t, "test1.name != 'a'", join.RightPlan.(*SelectionPlan).Expr.String())
+
+	// Outer joins are joined as a whole.
+	p = testJoinOrderPlan(t, "select * from test1 left join test2 on test1.id = test2.id, db2.test1 where db2.test1.id = 1;")
+	join = p.(*ReorderPlan).Input.(*JoinPlan)
+	assert.Equal(t, "db2.test1.id = 1", join.LeftPlan.(*SelectionPlan).Expr.String())
+	outerJoin := join.RightPlan.(*SelectionPlan).Input.(*JoinPlan)
+	assert.Equal(t, parser.LeftOuterJoin, outerJoin.JoinType)
+	assert.Equal(t, "table: db1.test1", explainDetails(outerJoin.LeftPlan))
+
+	// Single table and unknown columns.
+	p = testJoinOrderPlan(t, "select * from test1 where id = 1;")
+	assert.Equal(t, "Selection", explainOperator(p))
+	verifyTestPlanFail(t, "select * from test1, test2 where test3.id = 1;")
+	verifyTestPlanFail(t, "select * from test1, test2 where id = 1;")
+}
+
+func TestMakeOrderedJoinPlan_Greedy(t *testing.T) {
+	initTestStorage(t)
+	dpJoinReorderLimit = 1
+	defer func() { dpJoinReorderLimit = 8 }()
+	sql := "select * from test1, test2, db2.test1 where db1.test1.id = db2.test1.id;"
+	p := testJoinOrderPlan(t, sql)
+	join := p.(*ReorderPlan).Input.(*JoinPlan)
+	assert.Equal(t, "db1.test1.id = db2.test1.id", join.LeftPlan.(*SelectionPlan).Expr.String())
+	testSelect(t, sql, testDataSize*testDataSize, false)
+}
+
+func TestSelectivity(t *testing.T) {
+	initTestStorage(t)
+	selectivity := func(sql string) float64 {
+		return Selectivity(testJoinOrderPlan(t, sql).(*SelectionPlan).Expr)
+	}
+	sql := "select * from test1 where id = 1;"
+	assert.Equal(t, 1.0/defaultEqualSelectivity, selectivity(sql))
+	assert.Equal(t, 1.0/defaultSelectivity, selectivity("select * from test1 where id < 1;"))
+
+	err := ExecuteAnalyzeTableStm(&parser.AnalyzeTableStm{TableNames: []string{"test1", "test2"}}, "db1")
+	assert.Nil(t, err)
+	assert.InDelta(t, 1.0/float64(testDataSize), selectivity(sql), 1e-9)
+	assert.InDelta(t, 1-1.0/float64(testDataSize), selectivity("select * from test1 where id != 1;"), 1e-9)
+	assert.InDelta(t, 1.0, selectivity("select * from test1 where id >= 0;"), 1e-9)
+	assert.InDelta(t, 0.0, selectivity("select * from test1 where id < 0;"), 1e-9)
+	assert.InDelta(t, 2.0/float64(testDataSize), selectivity("select * from test1 where id = 1 or 2 = id;"), 1e-2)
+}
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/xiaobogaga/minidb/parser"
@@ -13,18 +14,55 @@ type Insert struct {
 	Schema string
 	Table  string
 	Cols   []string
-	Values []Expr
+	Values [][]Expr // One expression list per row.
+	Select Plan     // Not nil if the rows are from a select statement.
+	// ReadsTable is true if the select reads the inserted table.
+	ReadsTable bool
+	// For replace into, the old rows having duplicate keys are deleted.
+	Replace bool
+	// For on duplicate key update, the old row having duplicate keys is updated.
+	OnDuplicateUpdate []AssignmentExpr
 }
 
-func MakeInsertPlan(stm *parser.InsertIntoStm, currentDB string) Insert {
+func MakeInsertPlan(stm *parser.InsertIntoStm, currentDB string) (Insert, error) {
+	return makeInsertPlan(stm, &queryScope{CurrentDB: currentDB})
+}
+
+func makeInsertPlan(stm *parser.InsertIntoStm, scope *queryScope) (Insert, error) {
+	currentDB := scope.CurrentDB
 	schemaName, tableName, _ := getSchemaTableName(stm.TableName, currentDB)
-	exprs := ExprStmsToExprs(stm.Values, nil)
-	return Insert{
-		Schema: schemaName,
-		Table:  tableName,
-		Cols:   stm.Cols,
-		Values: exprs,
+	scope.useTable(schemaName, tableName)
+	insert := Insert{
+		Schema:  schemaName,
+		Table:   tableName,
+		Cols:    stm.Cols,
+		Values:  make([][]Expr, len(stm.Values)),
+		Replace: stm.Replace,
+	}
+	insert.OnDuplicateUpdate = AssignmentStmToAssignmentExprs(stm.OnDuplicateUpdate,
+		&upsertInput{SchemaName: schemaName, TableName: tableName}, scope)
+	for i, row := range stm.Values {
+		insert.Values[i] = ExprStmsToExprs(row, nil, scope)
 	}
+	if stm.Select != nil {
+		// The tables read by the select are recorded like preparing, even if it's not prepared.
+		selectScope := *scope
+		if selectScope.prepared == nil {
+			selectScope.prepared = &PreparedStm{}
+		}
+		from := len(selectScope.prepared.tables)
+		selectPlan, err := makeQueryPlan(stm.Select, &selectScope)
+		if err != nil {
+			return insert, err
+		}
+		insert.Select = OptimizePlan(selectPlan)
+		for _, used := range selectScope.prepared.tables[from:] {
+			if used.SchemaName == schemaName && used.Name == tableName {
+				insert.ReadsTable = true
+			}
+		}
+	}
+	return insert, nil
 }
 
 func (insert Insert) GetMulColumns() (ret []string) {
@@ -46,47 +84,181 @@ func (insert Insert) GetMulColumns() (ret []string) {
 	return ret[1:]
 }
 
-func (insert Insert) Execute() error {
+// Execute inserts the rows, nothing is changed if any row fails. See insertRows for how the
+// duplicate keys are handled.
+func (insert Insert) Execute(ctx context.Context) error {
 	// Now we save the values to the table.
 	dbInfo := storage.GetStorage().GetDbInfo(insert.Schema)
 	tableInfo := dbInfo.GetTable(insert.Table)
-	// Now we compute the values and then insert.
-	values := make([][]byte, len(insert.Values))
-	for i, expr := range insert.Values {
-		v, err := expr.Compute()
-		if err != nil {
-			return err
+	cols := insert.GetMulColumns()
+	undo := insertUndo{}
+	committed := false
+	// The query errors of select are panicked, so the changes are rolled back in defer.
+	defer func() {
+		if !committed {
+			undo.rollback()
 		}
-		values[i] = v
+	}()
+	var err error
+	if insert.Select != nil {
+		err = insert.executeSelect(ctx, tableInfo, cols, &undo)
+	} else {
+		err = insert.executeValues(tableInfo, cols, &undo)
 	}
-	tableInfo.InsertData(insert.GetMulColumns(), values)
-	return nil
+	committed = err == nil
+	return err
 }
 
-func (insert Insert) TypeCheckForNoCols() error {
-	dbInfo := storage.GetStorage().GetDbInfo(insert.Schema)
-	tableInfo := dbInfo.GetTable(insert.Table)
-	// One extra row index column
-	if len(insert.Values) != len(tableInfo.TableSchema.Columns)-1 {
-		return errors.New("values doesn't match table columns")
+// executeValues computes all rows before inserting them.
+func (insert Insert) executeValues(tableInfo *storage.TableInfo, cols []string, undo *insertUndo) error {
+	rows := make([][][]byte, len(insert.Values))
+	for i, row := range insert.Values {
+		values := make([][]byte, len(row))
+		for j, expr := range row {
+			v, err := expr.Compute()
+			if err != nil {
+				return err
+			}
+			_, colInfo := tableInfo.GetColumnInfo(cols[j])
+			// The value is stored as the column type, like int to float.
+			values[j], err = storage.ConvertValue(v, expr.toField().TP, *colInfo)
+			if err != nil {
+				return err
+			}
+		}
+		rows[i] = values
 	}
-	// Now we check col, Expr match one by one
-	for i, value := range insert.Values {
-		err := value.TypeCheck()
+	return insert.insertRows(tableInfo, cols, rows, undo)
+}
+
+// executeSelect inserts the selected rows batch by batch. If the select reads the inserted table,
+// all rows are selected before inserting like a temporary table, so the inserted rows are not
+// selected again.
+func (insert Insert) executeSelect(ctx context.Context, tableInfo *storage.TableInfo, cols []string, undo *insertUndo) error {
+	schema := insert.Select.Schema()
+	selected := dataColumns(schema)
+	var rows [][][]byte
+	for {
+		batch := executePlan(ctx, insert.Select)
+		err := queryContextErr(ctx)
 		if err != nil {
 			return err
 		}
-		colInfo := tableInfo.TableSchema.Columns[i+1]
-		// Expr type must match to column type.
-		err = colInfo.CanOp(value.toField(), storage.EqualOpType)
+		if batch == nil {
+			break
+		}
+		for row := 0; row < batch.RowCount(); row++ {
+			values := make([][]byte, len(selected))
+			for i, col := range selected {
+				_, colInfo := tableInfo.GetColumnInfo(cols[i])
+				values[i], err = storage.ConvertValue(batch.Records[col].RawValue(row), schema.Columns[col].TP, *colInfo)
+				if err != nil {
+					return err
+				}
+			}
+			rows = append(rows, values)
+		}
+		if insert.ReadsTable {
+			continue
+		}
+		err = insert.insertRows(tableInfo, cols, rows, undo)
 		if err != nil {
 			return err
 		}
-		v, err := value.Compute()
+		rows = nil
+	}
+	return insert.insertRows(tableInfo, cols, rows, undo)
+}
+
+// insertUndo is the changes made by an insert statement, they are undone in the reverse order
+// if the statement fails.
+type insertUndo []func()
+
+func (undo *insertUndo) add(f func()) {
+	*undo = append(*undo, f)
+}
+
+func (undo insertUndo) rollback() {
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// insertRows inserts rows to table. A row having the same primary key or unique key with
+// an existing row is an error and nothing is inserted, unless for replace into, where the old
+// rows are deleted, or for on duplicate key update, where the old row is updated instead.
+// The changes are recorded in undo.
+func (insert Insert) insertRows(tableInfo *storage.TableInfo, cols []string, rows [][][]byte, undo *insertUndo) error {
+	if !insert.Replace && len(insert.OnDuplicateUpdate) == 0 {
+		err := tableInfo.CheckDuplicate(cols, rows)
+		if err != nil {
+			return err
+		}
+		for _, values := range rows {
+			insertData(tableInfo, cols, values, undo)
+		}
+		return nil
+	}
+	for _, values := range rows {
+		duplicates := tableInfo.DuplicateRows(cols, values)
+		switch {
+		case len(duplicates) == 0:
+			insertData(tableInfo, cols, values, undo)
+		case insert.Replace:
+			for i := len(duplicates) - 1; i >= 0; i-- {
+				row, oldRow := duplicates[i], tableInfo.GetRow(duplicates[i])
+				tableInfo.DeleteRow(row)
+				undo.add(func() { tableInfo.InsertRow(row, oldRow) })
+			}
+			insertData(tableInfo, cols, values, undo)
+		default:
+			err := insert.updateDuplicateRow(tableInfo, duplicates[0], tableInfo.MakeRow(cols, values), undo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func insertData(tableInfo *storage.TableInfo, cols []string, values [][]byte, undo *insertUndo) {
+	tableInfo.InsertData(cols, values)
+	undo.add(func() { tableInfo.DeleteRow(tableInfo.RowCount() - 1) })
+}
+
+// updateDuplicateRow applies the on duplicate key update assignments to row of table, inserted
+// is the row failed to insert.
+func (insert Insert) updateDuplicateRow(tableInfo *storage.TableInfo, row int, inserted [][]byte, undo *insertUndo) error {
+	oldRow := tableInfo.GetRow(row)
+	oldRow[0] = storage.EncodeInt(int64(row))
+	input := createRecordBatch(upsertSchema(tableInfo), append(oldRow, inserted[1:]...))
+	newRow := tableInfo.GetRow(row)
+	for _, assign := range insert.OnDuplicateUpdate {
+		_, _, columnName := getSchemaTableColumnName(assign.Col)
+		index, field := tableInfo.GetColumnInfo(columnName)
+		value, err := storage.ConvertValue(assign.Expr.EvaluateRow(0, input), assign.Expr.toField().TP, *field)
 		if err != nil {
 			return err
 		}
-		err = colInfo.CanAssign(v)
+		newRow[index] = value
+	}
+	// The updated row cannot have the same keys with another row.
+	cols := make([]string, len(tableInfo.Datas)-1)
+	for i := range cols {
+		cols[i] = tableInfo.Datas[i+1].Field.Name
+	}
+	for _, duplicate := range tableInfo.DuplicateRows(cols, newRow[1:]) {
+		if duplicate != row {
+			return errors.New("duplicate entry for key")
+		}
+	}
+	undo.add(func() {
+		for i, col := range cols {
+			tableInfo.UpdateData(col, row, oldRow[i+1])
+		}
+	})
+	for i, col := range cols {
+		err := tableInfo.UpdateData(col, row, newRow[i+1])
 		if err != nil {
 			return err
 		}
@@ -94,6 +266,75 @@ func (insert Insert) TypeCheckForNoCols() error {
 	return nil
 }
 
+// createRecordBatch returns a record batch of one row.
+func createRecordBatch(schema *storage.TableSchema, row [][]byte) *storage.RecordBatch {
+	ret := &storage.RecordBatch{
+		Fields:  schema.Columns,
+		Records: make([]*storage.ColumnVector, len(schema.Columns)),
+	}
+	for i, field := range schema.Columns {
+		ret.Records[i] = &storage.ColumnVector{Field: field}
+		ret.Records[i].Append(row[i])
+	}
+	return ret
+}
+
+// insertedValueName is the column name of values(col).
+func insertedValueName(col string) string {
+	return fmt.Sprintf("VALUES(%s)", col)
+}
+
+// upsertSchema returns the columns of table and the inserted values named by insertedValueName.
+func upsertSchema(tableInfo *storage.TableInfo) *storage.TableSchema {
+	columns := tableInfo.TableSchema.Columns
+	ret := &storage.TableSchema{Columns: append([]storage.Field{}, columns...)}
+	// Skip the row index column.
+	for _, col := range columns[1:] {
+		col.Name = insertedValueName(col.Name)
+		ret.Columns = append(ret.Columns, col)
+	}
+	return ret
+}
+
+// upsertInput is the input of on duplicate key update assignments, it's only used for type check
+// and never executed.
+type upsertInput struct {
+	SchemaName string
+	TableName  string
+}
+
+func (input *upsertInput) Schema() *storage.TableSchema {
+	return upsertSchema(storage.GetStorage().GetDbInfo(input.SchemaName).GetTable(input.TableName))
+}
+
+func (input *upsertInput) Child() []Plan {
+	return nil
+}
+
+func (input *upsertInput) String() string {
+	return fmt.Sprintf("UpsertInput: %s.%s", input.SchemaName, input.TableName)
+}
+
+func (input *upsertInput) TypeCheck() error {
+	return nil
+}
+
+func (input *upsertInput) Execute(ctx context.Context) *storage.RecordBatch {
+	return nil
+}
+
+func (input *upsertInput) Reset() {}
+
+// dataColumns returns the positions of columns in schema except the row index columns.
+func dataColumns(schema *storage.TableSchema) (ret []int) {
+	for i, col := range schema.Columns {
+		if col.Name != storage.DefaultRowKeyName {
+			ret = append(ret, i)
+		}
+	}
+	return
+}
+
 func (insert Insert) HasColumn(colName string) bool {
 	for _, column := range insert.Cols {
 		_, _, columnName := getSchemaTableColumnName(column)
@@ -104,54 +345,118 @@ func (insert Insert) HasColumn(colName string) bool {
 	return false
 }
 
-func (insert Insert) TypeCheck() error {
-	if !storage.GetStorage().HasSchema(insert.Schema) {
-		return errors.New("schema doesn't exist")
-	}
-	dbInfo := storage.GetStorage().GetDbInfo(insert.Schema)
-	if !dbInfo.HasTable(insert.Table) {
-		return errors.New("table doesn't exist")
-	}
-	if len(insert.Cols) != 0 && len(insert.Cols) != len(insert.Values) {
-		return errors.New("insert columns doesn't match")
-	}
+// getColumns returns the columns inserted to, they are the table columns if insert has no cols.
+func (insert Insert) getColumns(tableInfo *storage.TableInfo) ([]*storage.Field, error) {
 	if len(insert.Cols) == 0 {
-		// when no cols in insert. then means the data must be inserted according to the column defined order.
-		return insert.TypeCheckForNoCols()
+		// One extra row index column
+		ret := make([]*storage.Field, len(tableInfo.TableSchema.Columns)-1)
+		for i := range ret {
+			ret[i] = &tableInfo.TableSchema.Columns[i+1]
+		}
+		return ret, nil
 	}
-	// Now cols are not empty.
 	// some columns cannot be missing.
-	tableInfo := dbInfo.GetTable(insert.Table)
 	for _, col := range tableInfo.TableSchema.Columns {
 		if col.CanIgnoreInInsert() {
 			continue
 		}
 		if !insert.HasColumn(col.Name) {
-			return errors.New(fmt.Sprintf("cannot missing column %s", util.BuildDotString(tableInfo.TableSchema.SchemaName(),
+			return nil, errors.New(fmt.Sprintf("cannot missing column %s", util.BuildDotString(tableInfo.TableSchema.SchemaName(),
 				tableInfo.TableSchema.TableName(), col.Name)))
 		}
 	}
-
-	// Now we check whether the column type match Expr type.
+	ret := make([]*storage.Field, len(insert.Cols))
 	for i, col := range insert.Cols {
-		err := insert.Values[i].TypeCheck()
+		_, _, realCol := getSchemaTableColumnName(col)
+		_, ret[i] = tableInfo.GetColumnInfo(realCol)
+		if ret[i] == nil {
+			return nil, errors.New("unknown column")
+		}
+	}
+	return ret, nil
+}
+
+// TypeCheck checks the columns once, and then the values of every row.
+func (insert Insert) TypeCheck() error {
+	if !storage.GetStorage().HasSchema(insert.Schema) {
+		return errors.New("schema doesn't exist")
+	}
+	dbInfo := storage.GetStorage().GetDbInfo(insert.Schema)
+	if !dbInfo.HasTable(insert.Table) {
+		return errors.New("table doesn't exist")
+	}
+	tableInfo := dbInfo.GetTable(insert.Table)
+	cols, err := insert.getColumns(tableInfo)
+	if err != nil {
+		return err
+	}
+	if insert.Select != nil {
+		err = insert.typeCheckSelect(cols)
 		if err != nil {
 			return err
 		}
-		_, _, realCol := getSchemaTableColumnName(col)
-		_, colInfo := tableInfo.GetColumnInfo(realCol)
-		if colInfo == nil {
-			return errors.New("unknown column")
+	}
+	for _, row := range insert.Values {
+		if len(row) != len(cols) {
+			if len(insert.Cols) == 0 {
+				return errors.New("values doesn't match table columns")
+			}
+			return errors.New("insert columns doesn't match")
+		}
+		// Now we check whether the column type match Expr type.
+		for i, colInfo := range cols {
+			setParamType(row[i], *colInfo)
+			err = row[i].TypeCheck()
+			if err != nil {
+				return err
+			}
+			err = colInfo.CanOp(row[i].toField(), storage.EqualOpType)
+			if err != nil {
+				return err
+			}
+			value, err := row[i].Compute()
+			if err != nil {
+				return err
+			}
+			err = colInfo.CanAssign(value)
+			if err != nil {
+				return err
+			}
 		}
-		err = colInfo.CanOp(insert.Values[i].toField(), storage.EqualOpType)
+	}
+	return insert.typeCheckOnDuplicateUpdate(tableInfo)
+}
+
+func (insert Insert) typeCheckOnDuplicateUpdate(tableInfo *storage.TableInfo) error {
+	for _, assign := range insert.OnDuplicateUpdate {
+		err := assign.Expr.TypeCheck()
 		if err != nil {
 			return err
 		}
-		value, err := insert.Values[i].Compute()
+		_, _, columnName := getSchemaTableColumnName(assign.Col)
+		_, f := tableInfo.GetColumnInfo(columnName)
+		if f == nil || columnName == storage.DefaultRowKeyName {
+			return errors.New(fmt.Sprintf("cannot find such column '%s'", assign.Col))
+		}
+		setParamType(assign.Expr, *f)
+		err = f.CanOp(assign.Expr.toField(), storage.EqualOpType)
 		if err != nil {
 			return err
 		}
-		err = colInfo.CanAssign(value)
+	}
+	return nil
+}
+
+// typeCheckSelect checks the select columns can be inserted to cols, the values are checked
+// when inserting.
+func (insert Insert) typeCheckSelect(cols []*storage.Field) error {
+	schema := insert.Select.Schema()
+	selected := dataColumns(schema)
+	if len(selected) != len(cols) {
+		return errors.New("insert columns doesn't match")
+	}
+	for i, col := range selected {
+		err := cols[i].CanOp(schema.Columns[col], storage.EqualOpType)
 		if err != nil {
 			return err
 		}
@@ -171,12 +476,12 @@ type AssignmentExpr struct {
 	Expr Expr
 }
 
-func AssignmentStmToAssignmentExprs(assignments []*parser.AssignmentStm, input Plan) []AssignmentExpr {
+func AssignmentStmToAssignmentExprs(assignments []*parser.AssignmentStm, input Plan, scope *queryScope) []AssignmentExpr {
 	ret := make([]AssignmentExpr, len(assignments))
 	for i, expr := range assignments {
 		ret[i] = AssignmentExpr{
 			Col:  expr.ColName,
-			Expr: ExprStmToExpr(expr.Value, input),
+			Expr: ExprStmToExpr(expr.Value, input, scope),
 		}
 	}
 	return ret
@@ -186,32 +491,35 @@ func AssignmentStmToAssignmentExprs(assignments []*parser.AssignmentStm, input P
 // We start with a select statement to get the row primary key. Then according to the
 // primary key, we update the value accordingly.
 func MakeUpdatePlan(stm *parser.UpdateStm, currentDB string) Update {
-	inputPlan, _ := makeScanPlan(stm.TableRefs.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
-	selectPlan := makeSelectPlan(inputPlan, stm.Where)
-	orderByPlan := makeOrderByPlan(selectPlan, stm.OrderBy, false)
+	return makeUpdatePlan(stm, &queryScope{CurrentDB: currentDB})
+}
+
+func makeUpdatePlan(stm *parser.UpdateStm, scope *queryScope) Update {
+	inputPlan, _ := makeScanPlan(stm.TableRefs.TableReference.(parser.TableReferenceTableFactorStm), scope)
+	selectPlan := makeSelectPlan(inputPlan, stm.Where, scope)
+	orderByPlan := makeOrderByPlan(selectPlan, stm.OrderBy, false, scope)
 	selectAllExpr := parser.SelectExpressionStm{
 		Tp: parser.StarSelectExpressionTp,
 	}
-	projectionPlan := makeProjectionPlan(orderByPlan, &selectAllExpr)
+	projectionPlan := makeProjectionPlan(orderByPlan, &selectAllExpr, scope)
 	limitPlan := makeLimitPlan(projectionPlan, stm.Limit)
 	return Update{
-		DefaultSchema: currentDB,
+		DefaultSchema: scope.CurrentDB,
 		TableName: stm.TableRefs.TableReference.(parser.TableReferenceTableFactorStm).
 			TableFactorReference.(parser.TableReferencePureTableRefStm).TableName,
 		Input:       limitPlan,
-		Assignments: AssignmentStmToAssignmentExprs(stm.Assignments, limitPlan),
+		Assignments: AssignmentStmToAssignmentExprs(stm.Assignments, limitPlan, scope),
 	}
 }
 
-func (update Update) Execute() error {
+func (update Update) Execute(ctx context.Context) error {
 	for {
-		data := update.Input.Execute()
+		data := executePlan(ctx, update.Input)
 		if data == nil {
-			return nil
+			return queryContextErr(ctx)
 		}
 		updateTableData(update.DefaultSchema, update.TableName, data, update.Assignments)
 	}
-	return nil
 }
 
 func (update Update) TypeCheck() error {
@@ -232,6 +540,7 @@ func (update Update) TypeCheck() error {
 		if f == nil {
 			return errors.New(fmt.Sprintf("cannot find such column '%s'", util.BuildDotString(schemaName, tableName, columnName)))
 		}
+		setParamType(assign.Expr, *f)
 		err = f.CanOp(assign.Expr.toField(), storage.EqualOpType)
 		if err != nil {
 			return err
@@ -248,17 +557,21 @@ type MultiUpdate struct {
 }
 
 func MakeMultiUpdatePlan(stm *parser.MultiUpdateStm, currentDB string) MultiUpdate {
-	scanPlans, _ := makeScanPlans(stm.TableRefs, currentDB)
+	return makeMultiUpdatePlan(stm, &queryScope{CurrentDB: currentDB})
+}
+
+func makeMultiUpdatePlan(stm *parser.MultiUpdateStm, scope *queryScope) MultiUpdate {
+	scanPlans, _ := makeScanPlans(stm.TableRefs, scope)
 	joinPlan := makeJoinPlan(scanPlans)
-	selectPlan := makeSelectPlan(joinPlan, stm.Where)
+	selectPlan := makeSelectPlan(joinPlan, stm.Where, scope)
 	selectAllExpr := parser.SelectExpressionStm{
 		Tp: parser.StarSelectExpressionTp,
 	}
-	projectionPlan := makeProjectionPlan(selectPlan, &selectAllExpr)
+	projectionPlan := makeProjectionPlan(selectPlan, &selectAllExpr, scope)
 	return MultiUpdate{
-		DefaultSchema: currentDB,
+		DefaultSchema: scope.CurrentDB,
 		Input:         projectionPlan,
-		Assignments:   AssignmentStmToAssignmentExprs(stm.Assignments, projectionPlan),
+		Assignments:   AssignmentStmToAssignmentExprs(stm.Assignments, projectionPlan, scope),
 	}
 }
 
@@ -279,11 +592,11 @@ func getSchemaTableColumnName(name string) (schema, table, col string) {
 	return
 }
 
-func (update MultiUpdate) Execute() error {
+func (update MultiUpdate) Execute(ctx context.Context) error {
 	for {
-		data := update.Input.Execute()
+		data := executePlan(ctx, update.Input)
 		if data == nil {
-			return nil
+			return queryContextErr(ctx)
 		}
 		// Todo
 		updateTableData(update.DefaultSchema, "", data, update.Assignments)
@@ -314,31 +627,34 @@ type Delete struct {
 }
 
 func MakeDeletePlan(stm *parser.SingleDeleteStm, currentDB string) Delete {
-	inputPlan, _ := makeScanPlan(stm.TableRef.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
-	selectPlan := makeSelectPlan(inputPlan, stm.Where)
-	orderByPlan := makeOrderByPlan(selectPlan, stm.OrderBy, false)
+	return makeDeletePlan(stm, &queryScope{CurrentDB: currentDB})
+}
+
+func makeDeletePlan(stm *parser.SingleDeleteStm, scope *queryScope) Delete {
+	inputPlan, _ := makeScanPlan(stm.TableRef.TableReference.(parser.TableReferenceTableFactorStm), scope)
+	selectPlan := makeSelectPlan(inputPlan, stm.Where, scope)
+	orderByPlan := makeOrderByPlan(selectPlan, stm.OrderBy, false, scope)
 	selectAllExpr := parser.SelectExpressionStm{
 		Tp: parser.StarSelectExpressionTp,
 	}
-	projectionPlan := makeProjectionPlan(orderByPlan, &selectAllExpr)
+	projectionPlan := makeProjectionPlan(orderByPlan, &selectAllExpr, scope)
 	limitPlan := makeLimitPlan(projectionPlan, stm.Limit)
 	return Delete{
-		DefaultSchemaName: currentDB,
+		DefaultSchemaName: scope.CurrentDB,
 		Input:             limitPlan,
 		TableName: stm.TableRef.TableReference.(parser.TableReferenceTableFactorStm).
 			TableFactorReference.(parser.TableReferencePureTableRefStm).TableName,
 	}
 }
 
-func (delete Delete) Execute() error {
+func (delete Delete) Execute(ctx context.Context) error {
 	for {
-		data := delete.Input.Execute()
+		data := executePlan(ctx, delete.Input)
 		if data == nil {
-			return nil
+			return queryContextErr(ctx)
 		}
 		deleteTableData(data, delete.DefaultSchemaName, delete.TableName)
 	}
-	return nil
 }
 
 func deleteTableData(data *storage.RecordBatch, defaultDB string, tables ...string) {
@@ -365,21 +681,25 @@ type MultiDelete struct {
 }
 
 func MakeMultiDeletePlan(stm *parser.MultiDeleteStm, currentDB string) MultiDelete {
-	scanPlans, _ := makeScanPlans(stm.TableReferences, currentDB)
+	return makeMultiDeletePlan(stm, &queryScope{CurrentDB: currentDB})
+}
+
+func makeMultiDeletePlan(stm *parser.MultiDeleteStm, scope *queryScope) MultiDelete {
+	scanPlans, _ := makeScanPlans(stm.TableReferences, scope)
 	joinPlan := makeJoinPlan(scanPlans)
-	selectPlan := makeSelectPlan(joinPlan, stm.Where)
+	selectPlan := makeSelectPlan(joinPlan, stm.Where, scope)
 	selectAllExpr := parser.SelectExpressionStm{
 		Tp: parser.StarSelectExpressionTp,
 	}
-	projectionPlan := makeProjectionPlan(selectPlan, &selectAllExpr)
-	return MultiDelete{Input: projectionPlan, Tables: stm.TableNames, DefaultDB: currentDB}
+	projectionPlan := makeProjectionPlan(selectPlan, &selectAllExpr, scope)
+	return MultiDelete{Input: projectionPlan, Tables: stm.TableNames, DefaultDB: scope.CurrentDB}
 }
 
-func (delete MultiDelete) Execute() error {
+func (delete MultiDelete) Execute(ctx context.Context) error {
 	for {
-		data := delete.Input.Execute()
+		data := executePlan(ctx, delete.Input)
 		if data == nil {
-			return nil
+			return queryContextErr(ctx)
 		}
 		deleteTableData(data, delete.DefaultDB, delete.Tables...)
 	}
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/xiaobogaga/minidb/parser"
 	"github.com/xiaobogaga/minidb/storage"
@@ -9,28 +10,131 @@ import (
 
 func testInsert(t *testing.T, sql string) {
 	stm := toTestStm(t, sql)
-	err := ExecuteInsertStm(stm.(*parser.InsertIntoStm), "db1")
+	err := ExecuteInsertStm(context.Background(), stm.(*parser.InsertIntoStm), "db1")
 	assert.Nil(t, err)
 	storage.PrintStorage(t)
 }
 
 func testInsertFail(t *testing.T, sql string) {
 	stm := toTestStm(t, sql)
-	err := ExecuteInsertStm(stm.(*parser.InsertIntoStm), "db1")
+	err := ExecuteInsertStm(context.Background(), stm.(*parser.InsertIntoStm), "db1")
 	assert.NotNil(t, err)
 	storage.PrintStorage(t)
 }
 
 func TestInsert_Execute(t *testing.T) {
 	initTestStorage(t)
+	table := storage.GetStorage().GetDbInfo("db1").GetTable("test1")
+	testInsert(t, "insert into test1(id, name, age, location, sex, c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13) values"+
+		"(100, 'a', 1, 'l', true, 1, 1.0, false, 'c', 'c', 'c', '2020-01-01', '10:00:00', '2020-01-01 10:00:00', 't', 't', 'b', 'b'),"+
+		"(101, 'b', 2.5, 'l', true, 1, 1.0, false, 'c', 'c', 'c', '2020-01-01', '10:00:00', '2020-01-01 10:00:00', 't', 't', 'b', 'b');")
+	assert.Equal(t, testDataSize+2, table.RowCount())
+	// Int values are stored as float for float columns.
+	index, age := table.GetColumnInfo("age")
+	assert.Equal(t, 1.0, storage.DecodeFloat(table.Datas[index].Values[testDataSize]))
+	assert.Equal(t, storage.Float, age.TP.Name)
+
+	// Nothing is inserted if any row fails.
+	testInsertFail(t, "insert into test1(id, name, age, location, sex, c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13) values"+
+		"(102, 'a', 1, 'l', true, 1, 1.0, false, 'c', 'c', 'c', '2020-01-01', '10:00:00', '2020-01-01 10:00:00', 't', 't', 'b', 'b'),"+
+		"(103, 'b', 2.5, 'l', true, 1, 1.0, false, 'c', 'c', 'c', '2020-01-01', '10:00', '2020-01-01 10:00:00', 't', 't', 'b', 'b');")
+	testInsertFail(t, "insert into test1(id, name) values(102, 'a'), (103);")
+	assert.Equal(t, testDataSize+2, table.RowCount())
+
+	// Missing columns get their default values.
+	err := ExecuteCreateTableStm(toTestStm(t, "create table test3 (id int, score int default 5, note varchar(10) null);").(*parser.CreateTableStm), "db1")
+	assert.Nil(t, err)
+	testInsert(t, "insert into test3(id) values(1), (2);")
+	testInsertFail(t, "insert into test3(score) values(1);")
+	testSelect(t, "select * from test3 where score = 5;", 2, false)
+
+	// Insert select.
+	testInsert(t, "insert into test3(id, score) select id, age from test1 where id >= 100;")
+	testSelect(t, "select * from test3 where score = 3;", 1, false)
+	// The inserted rows are not selected again if the select reads the inserted table.
+	insert, err := MakeInsertPlan(toTestStm(t, "insert into test3(id, score) select id, score from test3;").(*parser.InsertIntoStm), "db1")
+	assert.Nil(t, err)
+	assert.True(t, insert.ReadsTable)
+	testInsert(t, "insert into test3(id, score) select id, score from test3;")
+	assert.Equal(t, 8, storage.GetStorage().GetDbInfo("db1").GetTable("test3").RowCount())
+	testInsertFail(t, "insert into test3(id, score) select id from test1;")
+	testInsertFail(t, "insert into test3(id, score) select id, name from test1;")
+	assert.Equal(t, 8, storage.GetStorage().GetDbInfo("db1").GetTable("test3").RowCount())
+	// Nothing is inserted if a row of a later batch fails.
+	SetBatchSize(1)
+	defer SetBatchSize(1 << 10)
+	err = ExecuteCreateTableStm(toTestStm(t, "create table test4 (v varchar(20));").(*parser.CreateTableStm), "db1")
+	assert.Nil(t, err)
+	testInsert(t, "insert into test4 values ('a'), ('longer than note');")
+	testInsertFail(t, "insert into test3(id, note) select 1, v from test4;")
+	assert.Equal(t, 8, storage.GetStorage().GetDbInfo("db1").GetTable("test3").RowCount())
+	// Empty strings aren't nulls.
+	testInsert(t, "insert into test4 values ('');")
+	testInsert(t, "insert into test4 select v from test4 where v = '';")
+	testInsertFail(t, "insert into test4 values (null);")
+	testSelect(t, "select * from test4 where v = '';", 2, false)
 	// testInsert(t, generateInsertSql(1))
 	//sql = "insert into db1.test1 values(10, 'xiaoboxxxxxxxxxxxxxxxxxxxxxxxxx', 10.0, 'a', 0);"
 	//testInsertFail(t, sql)
 }
 
+func TestInsert_Upsert(t *testing.T) {
+	initTestStorage(t)
+	assert.Nil(t, testExecute(t, "create table test3 (id int primary key, name varchar(20), score int);"))
+	assert.Nil(t, testExecute(t, "alter table test3 add unique key uk_name (name);"))
+	table := storage.GetStorage().GetDbInfo("db1").GetTable("test3")
+	testInsert(t, "insert into test3 values(1, 'a', 10), (2, 'b', 20);")
+	// Duplicate keys, with the existing rows or in the inserted rows.
+	testInsertFail(t, "insert into test3 values(1, 'c', 10);")
+	testInsertFail(t, "insert into test3 values(3, 'a', 10);")
+	testInsertFail(t, "insert into test3 values(3, 'c', 10), (3, 'd', 10);")
+	assert.Equal(t, 2, table.RowCount())
+
+	// On duplicate key update.
+	testInsert(t, "insert into test3 values(1, 'c', 5), (3, 'c', 30) on duplicate key update score = score + values(score);")
+	assert.Equal(t, 3, table.RowCount())
+	testSelect(t, "select * from test3 where id = 1 and name = 'a' and score = 15;", 1, false)
+	testInsert(t, "insert into test3 values(4, 'a', 1) on duplicate key update name = 'e', score = values(id);")
+	testSelect(t, "select * from test3 where id = 1 and name = 'e' and score = 4;", 1, false)
+	// The updated row cannot have the same keys with another row.
+	testInsertFail(t, "insert into test3 values(1, 'x', 1) on duplicate key update name = 'b';")
+	testInsertFail(t, "insert into test3 values(1, 'x', 1) on duplicate key update score = values(c1);")
+	testInsertFail(t, "insert into test3 values(1, 'x', 1) on duplicate key update c1 = 1;")
+	testInsert(t, "insert into test3 select id, location, age from test1 where id = 2 on duplicate key update score = -1;")
+	testSelect(t, "select * from test3 where id = 2 and score = -1;", 1, false)
+
+	// Replace deletes all rows having duplicate keys.
+	testInsert(t, "replace into test3 values(2, 'c', 100);")
+	assert.Equal(t, 2, table.RowCount())
+	testSelect(t, "select * from test3 where id = 2 and name = 'c' and score = 100;", 1, false)
+	testInsert(t, "replace into test3 values(5, 'f', 50);")
+	assert.Equal(t, 3, table.RowCount())
+	testInsert(t, "insert into test3 values(5, 'x', 1) on duplicate key update name = '';")
+	testSelect(t, "select * from test3 where id = 5 and name = '';", 1, false)
+
+	// The inserted, updated and deleted rows are restored if a later row fails.
+	testInsertFail(t, "insert into test3 values(6, 'g', 60), (5, 'x', 1) on duplicate key update name = 'c';")
+	assert.Equal(t, 3, table.RowCount())
+	testSelect(t, "select * from test3 where id = 6 or name = 'g';", 0, false)
+	testInsertFail(t, "insert into test3 values(7, 'h', 70), (1, 'x', 1), (2, 'h', 1) on duplicate key update name = values(name);")
+	assert.Equal(t, 3, table.RowCount())
+	testSelect(t, "select * from test3 where id = 1 and name = 'e' and score = 4;", 1, false)
+	SetBatchSize(1)
+	defer SetBatchSize(1 << 10)
+	assert.Nil(t, testExecute(t, "create table test5 (id int, name varchar(30));"))
+	testInsert(t, "insert into test5 values(2, 'z'), (8, 'longer than the name of test3');")
+	testInsertFail(t, "replace into test3(id, name) select id, name from test5;")
+	assert.Equal(t, 3, table.RowCount())
+	assert.Equal(t, int64(2), storage.DecodeInt(table.GetRow(1)[1]))
+	testSelect(t, "select * from test3 where id = 2 and name = 'c' and score = 100;", 1, false)
+	testInsertFail(t, "insert into test3 values(2, 'q', 1);")
+	testInsertFail(t, "insert into test3 values(9, 'c', 1);")
+	testInsert(t, "insert into test3 values(9, 'z', 1);")
+}
+
 func testUpdate(t *testing.T, sql string) {
 	stm := toTestStm(t, sql)
-	err := ExecuteUpdateStm(stm.(*parser.UpdateStm), "db1")
+	err := ExecuteUpdateStm(context.Background(), stm.(*parser.UpdateStm), "db1")
 	assert.Nil(t, err)
 	storage.PrintStorage(t)
 }
@@ -47,7 +151,7 @@ func TestMultiUpdate_Execute(t *testing.T) {
 
 func testDelete(t *testing.T, sql string) {
 	stm := toTestStm(t, sql)
-	err := ExecuteDeleteStm(stm.(*parser.SingleDeleteStm), "db1")
+	err := ExecuteDeleteStm(context.Background(), stm.(*parser.SingleDeleteStm), "db1")
 	assert.Nil(t, err)
 	storage.PrintStorage(t)
 }
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/xiaobogaga/minidb/parser"
@@ -13,7 +14,7 @@ type Plan interface {
 	Child() []Plan
 	String() string
 	TypeCheck() error
-	Execute() *storage.RecordBatch
+	Execute(ctx context.Context) *storage.RecordBatch
 	Reset()
 }
 
@@ -52,9 +53,9 @@ func (scan *ScanPlan) TypeCheck() error {
 	return scan.Input.TypeCheck()
 }
 
-func (scan *ScanPlan) Execute() *storage.RecordBatch {
+func (scan *ScanPlan) Execute(ctx context.Context) *storage.RecordBatch {
 	// we can return directly.
-	return scan.Input.Execute()
+	return executePlan(ctx, scan.Input)
 }
 
 func (scan *ScanPlan) Reset() {
@@ -64,7 +65,10 @@ func (scan *ScanPlan) Reset() {
 type TableScan struct {
 	Name       string `json:"table_name"`
 	SchemaName string `json:"schema_name"`
-	i          int
+	// Filters are pushed down from the selection above, used to skip row groups by zone maps.
+	Filters       []ZoneFilter `json:"filters"`
+	SkippedGroups int          `json:"-"`
+	i             int
 }
 
 func (tableScan *TableScan) Schema() *storage.TableSchema {
@@ -98,9 +102,19 @@ func SetBatchSize(batch int) {
 	batchSize = batch
 }
 
-func (tableScan *TableScan) Execute() *storage.RecordBatch {
+// TableScan is the leaf of every plan tree, so stopping here when ctx is done makes
+// the whole tree drain quickly.
+func (tableScan *TableScan) Execute(ctx context.Context) *storage.RecordBatch {
+	if ctx.Err() != nil {
+		return nil
+	}
 	dbInfo := storage.GetStorage().GetDbInfo(tableScan.SchemaName)
 	table := dbInfo.GetTable(tableScan.Name)
+	if len(tableScan.Filters) > 0 {
+		var ret *storage.RecordBatch
+		ret, tableScan.i = table.FetchDataSkipping(tableScan.i, batchSize, tableScan.skipRowGroup)
+		return ret
+	}
 	ret := table.FetchData(tableScan.i, batchSize)
 	tableScan.i += ret.RowCount()
 	return ret
@@ -110,13 +124,109 @@ func (tableScan *TableScan) Reset() {
 	tableScan.i = 0
 }
 
+// DerivedPlan is a subquery in from like (select ...) as alias, or a common table expression.
+// The columns of the subquery are re-qualified under the alias and named by Columns if given,
+// otherwise by their alias if any.
+type DerivedPlan struct {
+	Input   Plan     `json:"derived_input"`
+	Alias   string   `json:"alias"`
+	Columns []string `json:"columns"`
+	rows    int
+}
+
+func (derived *DerivedPlan) Schema() *storage.TableSchema {
+	ret := &storage.TableSchema{
+		Columns: []storage.Field{storage.RowIndexField("", derived.Alias)},
+	}
+	for i, col := range derived.dataColumns() {
+		field := derived.derivedField(derived.Input.Schema().Columns[col])
+		if i < len(derived.Columns) {
+			field.Name = derived.Columns[i]
+		}
+		ret.AppendColumn(field)
+	}
+	return ret
+}
+
+// dataColumns returns the index of input columns except the row index columns.
+func (derived *DerivedPlan) dataColumns() (ret []int) {
+	for i, column := range derived.Input.Schema().Columns {
+		if column.Name != storage.DefaultRowKeyName {
+			ret = append(ret, i)
+		}
+	}
+	return
+}
+
+func (derived *DerivedPlan) derivedField(field storage.Field) storage.Field {
+	if field.Alias != "" {
+		field.Name = field.Alias
+	}
+	field.SchemaName, field.TableName, field.Alias = "", derived.Alias, ""
+	return field
+}
+
+func (derived *DerivedPlan) String() string {
+	return fmt.Sprintf("DerivedPlan: %s", derived.Alias)
+}
+
+func (derived *DerivedPlan) Child() []Plan {
+	return []Plan{derived.Input}
+}
+
+func (derived *DerivedPlan) TypeCheck() error {
+	err := derived.Input.TypeCheck()
+	if err != nil {
+		return err
+	}
+	if len(derived.Columns) > 0 && len(derived.Columns) != len(derived.dataColumns()) {
+		return errors.New(fmt.Sprintf("'%s' has %d columns, but %d column names are given", derived.Alias,
+			len(derived.dataColumns()), len(derived.Columns)))
+	}
+	names := map[string]bool{}
+	for _, column := range derived.Schema().Columns[1:] {
+		if names[column.Name] {
+			return errors.New(fmt.Sprintf("duplicate column name '%s' in '%s'", column.Name, derived.Alias))
+		}
+		names[column.Name] = true
+	}
+	return nil
+}
+
+func (derived *DerivedPlan) Execute(ctx context.Context) *storage.RecordBatch {
+	batch := executePlan(ctx, derived.Input)
+	if batch == nil {
+		return nil
+	}
+	ret := MakeEmptyRecordBatchFromSchema(derived.Schema())
+	for i := 0; i < batch.RowCount(); i++ {
+		ret.Records[0].Append(storage.EncodeInt(int64(derived.rows + i)))
+	}
+	derived.rows += batch.RowCount()
+	for i, col := range derived.dataColumns() {
+		ret.Records[i+1].Values = batch.Records[col].Values
+	}
+	return ret
+}
+
+func (derived *DerivedPlan) Reset() {
+	derived.Input.Reset()
+	derived.rows = 0
+}
+
 type JoinPlan struct {
 	LeftPlan   Plan            `json:"left"`
 	JoinType   parser.JoinType `json:"type"`
 	RightPlan  Plan            `json:"right"`
 	LeftBatch  *storage.RecordBatch
 	RightBatch *storage.RecordBatch
-	Expr       Expr
+	// The join condition of full outer join, other joins are filtered by a selection plan.
+	Expr Expr
+	// For full outer join, the right rows are loaded first, and marked when matched.
+	rightBatches []*storage.RecordBatch
+	rightMatched [][]bool
+	rightDone    bool
+	leftDone     bool
 }
 
 func NewJoinPlan(left, right Plan, tp parser.JoinType) *JoinPlan {
@@ -131,6 +241,11 @@ func (join *JoinPlan) Schema() *storage.TableSchema {
 	leftSchema := join.LeftPlan.Schema()
 	rightSchema := join.RightPlan.Schema()
 	mergedSchema, _ := leftSchema.Merge(rightSchema)
+	if join.JoinType == parser.FullOuterJoin {
+		for i := range mergedSchema.Columns {
+			mergedSchema.Columns[i].AllowNull = true
+		}
+	}
 	return mergedSchema
 }
 
@@ -147,7 +262,21 @@ func (join *JoinPlan) TypeCheck() error {
 	if err != nil {
 		return err
 	}
-	return join.RightPlan.TypeCheck()
+	err = join.RightPlan.TypeCheck()
+	if err != nil || join.Expr == nil {
+		return err
+	}
+	err = join.Expr.TypeCheck()
+	if err != nil {
+		return err
+	}
+	if join.Expr.HasGroupFunc() {
+		return errors.New("invalid use of group function")
+	}
+	if join.Expr.toField().TP.Name != storage.Bool {
+		return errors.New(fmt.Sprintf("%s doesn't return bool value", join.Expr.String()))
+	}
+	return nil
 }
 
 func joinTypeToString(joinType parser.JoinType) string {
@@ -158,17 +287,25 @@ func joinTypeToString(joinType parser.JoinType) string {
 		return "leftOuterJoin"
 	case parser.RightOuterJoin:
 		return "rightOuterJoin"
+	case parser.FullOuterJoin:
+		return "fullOuterJoin"
 	default:
 		return ""
 	}
 }
 
-func (join *JoinPlan) Execute() (ret *storage.RecordBatch) {
+func (join *JoinPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
+	if ctx.Err() != nil {
+		return nil
+	}
+	if join.JoinType == parser.FullOuterJoin {
+		return join.executeFullJoin(ctx)
+	}
 	if join.LeftBatch == nil {
-		join.LeftBatch = join.LeftPlan.Execute()
+		join.LeftBatch = executePlan(ctx, join.LeftPlan)
 	}
 	if join.RightBatch == nil {
-		join.RightBatch = join.RightPlan.Execute()
+		join.RightBatch = executePlan(ctx, join.RightPlan)
 	}
 	switch join.JoinType {
 	case parser.LeftOuterJoin:
@@ -176,9 +313,9 @@ func (join *JoinPlan) Execute() (ret *storage.RecordBatch) {
 			return nil
 		}
 		ret = join.LeftBatch.Join(join.RightBatch, join.LeftPlan.Schema(), join.Schema())
-		join.RightBatch = join.RightPlan.Execute()
+		join.RightBatch = executePlan(ctx, join.RightPlan)
 		if join.RightBatch == nil {
-			join.LeftBatch = join.LeftPlan.Execute()
+			join.LeftBatch = executePlan(ctx, join.LeftPlan)
 			join.RightPlan.Reset()
 		}
 	case parser.RightOuterJoin:
@@ -186,9 +323,9 @@ func (join *JoinPlan) Execute() (ret *storage.RecordBatch) {
 			return nil
 		}
 		ret = join.LeftBatch.Join(join.RightBatch, join.LeftPlan.Schema(), join.Schema())
-		join.LeftBatch = join.LeftPlan.Execute()
+		join.LeftBatch = executePlan(ctx, join.LeftPlan)
 		if join.LeftBatch == nil {
-			join.RightBatch = join.RightPlan.Execute()
+			join.RightBatch = executePlan(ctx, join.RightPlan)
 			join.LeftPlan.Reset()
 		}
 	case parser.InnerJoin:
@@ -196,22 +333,354 @@ func (join *JoinPlan) Execute() (ret *storage.RecordBatch) {
 			return nil
 		}
 		ret = join.LeftBatch.Join(join.RightBatch, join.LeftPlan.Schema(), join.Schema())
-		join.RightBatch = join.RightPlan.Execute()
+		join.RightBatch = executePlan(ctx, join.RightPlan)
 		if join.RightBatch == nil {
-			join.LeftBatch = join.LeftPlan.Execute()
+			join.LeftBatch = executePlan(ctx, join.LeftPlan)
 			join.RightPlan.Reset()
 		}
 	}
 	return ret
 }
 
-func (join JoinPlan) Reset() {
+// executeFullJoin returns the matched rows of a left batch and its unmatched rows joined with
+// nulls, the unmatched right rows are returned after all left batches.
+func (join *JoinPlan) executeFullJoin(ctx context.Context) *storage.RecordBatch {
+	for !join.rightDone {
+		batch := executePlan(ctx, join.RightPlan)
+		if batch == nil {
+			join.rightDone = true
+			break
+		}
+		if batch.RowCount() > 0 {
+			join.rightBatches = append(join.rightBatches, batch)
+			join.rightMatched = append(join.rightMatched, make([]bool, batch.RowCount()))
+		}
+	}
+	if join.leftDone {
+		return nil
+	}
+	schema, leftSchema := join.Schema(), join.LeftPlan.Schema()
+	leftColumns := len(leftSchema.Columns)
+	ret := MakeEmptyRecordBatchFromSchema(schema)
+	left := executePlan(ctx, join.LeftPlan)
+	if left == nil {
+		join.leftDone = true
+		for i, right := range join.rightBatches {
+			for row, matched := range join.rightMatched[i] {
+				if !matched {
+					appendNullJoinedRow(ret, right, row, leftColumns)
+				}
+			}
+		}
+		return ret
+	}
+	leftMatched := make([]bool, left.RowCount())
+	for i, right := range join.rightBatches {
+		joined := left.Join(right, leftSchema, schema)
+		selectedRows := join.Expr.Evaluate(joined)
+		for row := 0; row < joined.RowCount(); row++ {
+			if selectedRows.Bool(row) {
+				leftMatched[row/right.RowCount()] = true
+				join.rightMatched[i][row%right.RowCount()] = true
+			}
+		}
+		ret.Append(joined.Filter(selectedRows))
+	}
+	for row, matched := range leftMatched {
+		if !matched {
+			appendNullJoinedRow(ret, left, row, 0)
+		}
+	}
+	return ret
+}
+
+// appendNullJoinedRow appends the row of batch to ret from column offset, other columns are null.
+func appendNullJoinedRow(ret *storage.RecordBatch, batch *storage.RecordBatch, row int, offset int) {
+	for col := 0; col < ret.ColumnCount(); col++ {
+		var value []byte
+		if col >= offset && col < offset+batch.ColumnCount() {
+			value = batch.Records[col-offset].Values[row]
+		}
+		ret.Records[col].Append(value)
+	}
+}
+
+func (join *JoinPlan) Reset() {
 	join.LeftBatch = nil
 	join.RightBatch = nil
+	join.rightBatches = nil
+	join.rightMatched = nil
+	join.rightDone = false
+	join.leftDone = false
 	join.LeftPlan.Reset()
 	join.RightPlan.Reset()
 }
 
+// SetOperationPlan combines the rows of two plans having the same number of columns, like
+// select ... union [all] select .... Columns are named by the left plan, and have the common
+// type of both sides like the branches of case. Without all, duplicate rows are removed, nulls
+// are equal to each other.
+type SetOperationPlan struct {
+	LeftPlan  Plan                  `json:"left"`
+	Tp        parser.SetOperationTp `json:"type"`
+	All       bool                  `json:"all"`
+	RightPlan Plan                  `json:"right"`
+	// keys counts the rows of right plan for intersect and except, or marks the returned rows.
+	keys     map[string]int
+	size     int // The memory size of keys.
+	leftDone bool
+	rows     int
+}
+
+func (set *SetOperationPlan) Schema() *storage.TableSchema {
+	ret := &storage.TableSchema{
+		Columns: []storage.Field{storage.RowIndexField("", "")},
+	}
+	rightFields := dataFields(set.RightPlan.Schema())
+	for i, field := range dataFields(set.LeftPlan.Schema()) {
+		if field.Alias != "" {
+			field.Name = field.Alias
+		}
+		field.SchemaName, field.TableName, field.Alias = "", "", ""
+		if i < len(rightFields) {
+			field.TP, _ = branchType([]storage.FieldTP{field.TP, rightFields[i].TP})
+			field.AllowNull = field.AllowNull || rightFields[i].AllowNull
+		}
+		ret.AppendColumn(field)
+	}
+	return ret
+}
+
+// dataFields returns the columns of schema except the row index columns.
+func dataFields(schema *storage.TableSchema) (ret []storage.Field) {
+	for _, column := range schema.Columns {
+		if column.Name != storage.DefaultRowKeyName {
+			ret = append(ret, column)
+		}
+	}
+	return
+}
+
+func (set *SetOperationPlan) String() string {
+	return fmt.Sprintf("%s(%s, %s)", set.operation(), set.LeftPlan, set.RightPlan)
+}
+
+func (set *SetOperationPlan) operation() string {
+	ret := ""
+	switch set.Tp {
+	case parser.UnionTp:
+		ret = "union"
+	case parser.IntersectTp:
+		ret = "intersect"
+	case parser.ExceptTp:
+		ret = "except"
+	}
+	if set.All {
+		ret += " all"
+	}
+	return ret
+}
+
+func (set *SetOperationPlan) Child() []Plan {
+	return []Plan{set.LeftPlan, set.RightPlan}
+}
+
+func (set *SetOperationPlan) TypeCheck() error {
+	err := set.LeftPlan.TypeCheck()
+	if err != nil {
+		return err
+	}
+	err = set.RightPlan.TypeCheck()
+	if err != nil {
+		return err
+	}
+	leftFields, rightFields := dataFields(set.LeftPlan.Schema()), dataFields(set.RightPlan.Schema())
+	if len(leftFields) != len(rightFields) {
+		return errors.New(fmt.Sprintf("the selects of %s have a different number of columns", set.operation()))
+	}
+	for i, field := range leftFields {
+		_, err = branchType([]storage.FieldTP{field.TP, rightFields[i].TP})
+		if err != nil {
+			return errors.New(fmt.Sprintf("column %s of %s: %s", field.Name, set.operation(), err))
+		}
+	}
+	return nil
+}
+
+func (set *SetOperationPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
+	if set.keys == nil {
+		set.keys = map[string]int{}
+		if set.Tp != parser.UnionTp {
+			set.loadRight(ctx)
+		}
+	}
+	schema := set.Schema()
+	for ret == nil || ret.RowCount() < batchSize {
+		batch := set.nextBatch(ctx)
+		if batch == nil {
+			return
+		}
+		if ret == nil {
+			ret = MakeEmptyRecordBatchFromSchema(schema)
+		}
+		batch = convertBatch(batch, schema)
+		for row := 0; row < batch.RowCount(); row++ {
+			if !set.keep(string(dataRowKey(batch, row))) {
+				continue
+			}
+			ret.Records[0].Append(storage.EncodeInt(int64(set.rows)))
+			set.rows++
+			for col := 1; col < batch.ColumnCount(); col++ {
+				ret.Records[col].Append(batch.Records[col].Values[row])
+			}
+		}
+	}
+	return
+}
+
+// nextBatch returns the batches of left plan, and then right plan for union.
+func (set *SetOperationPlan) nextBatch(ctx context.Context) *storage.RecordBatch {
+	if !set.leftDone {
+		batch := executePlan(ctx, set.LeftPlan)
+		if batch != nil {
+			return batch
+		}
+		set.leftDone = true
+	}
+	if set.Tp != parser.UnionTp {
+		return nil
+	}
+	return executePlan(ctx, set.RightPlan)
+}
+
+func (set *SetOperationPlan) loadRight(ctx context.Context) {
+	schema := set.Schema()
+	for {
+		batch := executePlan(ctx, set.RightPlan)
+		if batch == nil {
+			return
+		}
+		batch = convertBatch(batch, schema)
+		for row := 0; row < batch.RowCount(); row++ {
+			set.addKey(string(dataRowKey(batch, row)), 1)
+		}
+	}
+}
+
+func (set *SetOperationPlan) addKey(key string, delta int) {
+	if _, ok := set.keys[key]; !ok {
+		set.size += len(key)
+	}
+	set.keys[key] += delta
+}
+
+// keep returns whether the left row, or the right row of union having key is returned.
+func (set *SetOperationPlan) keep(key string) bool {
+	count := set.keys[key]
+	switch {
+	case set.Tp == parser.UnionTp && set.All:
+		return true
+	case set.Tp == parser.UnionTp:
+		set.addKey(key, 1)
+		return count == 0
+	case set.Tp == parser.IntersectTp && set.All:
+		if count > 0 {
+			set.keys[key]--
+		}
+		return count > 0
+	case set.Tp == parser.IntersectTp:
+		// The following duplicates are not returned.
+		set.keys[key] = 0
+		return count > 0
+	case set.All:
+		if count > 0 {
+			set.keys[key]--
+		}
+		return count == 0
+	default:
+		// Marks the key as returned.
+		set.addKey(key, 1)
+		return count == 0
+	}
+}
+
+// convertBatch converts the data columns of batch to the types of schema, which has a row index
+// column and the data columns. The row index of the returned batch is the row number in batch.
+func convertBatch(batch *storage.RecordBatch, schema *storage.TableSchema) *storage.RecordBatch {
+	ret := MakeEmptyRecordBatchFromSchema(schema)
+	for row := 0; row < batch.RowCount(); row++ {
+		ret.Records[0].Append(storage.EncodeInt(int64(row)))
+	}
+	i := 1
+	for col := 0; col < batch.ColumnCount(); col++ {
+		if batch.IsRowIdColumn(col) {
+			continue
+		}
+		from, to := batch.Fields[col].TP, schema.Columns[i].TP
+		for _, value := range batch.Records[col].Values {
+			ret.Records[i].Append(convertBranch(value, from, to))
+		}
+		i++
+	}
+	return ret
+}
+
+func (set *SetOperationPlan) Reset() {
+	set.LeftPlan.Reset()
+	set.RightPlan.Reset()
+	set.keys = nil
+	set.size = 0
+	set.leftDone = false
+	set.rows = 0
+}
+
+// ReorderPlan permutes the columns of Input, the i-th column is the Order[i]-th column of Input.
+// It's used to keep the columns in FROM order after joins are reordered.
+type ReorderPlan struct {
+	Input Plan
+	Order []int
+}
+
+func (reorder *ReorderPlan) Schema() *storage.TableSchema {
+	inputSchema := reorder.Input.Schema()
+	ret := &storage.TableSchema{}
+	for _, i := range reorder.Order {
+		ret.AppendColumn(inputSchema.Columns[i])
+	}
+	return ret
+}
+
+func (reorder *ReorderPlan) String() string {
+	return fmt.Sprintf("ReorderPlan: %v, %s", reorder.Order, reorder.Input)
+}
+
+func (reorder *ReorderPlan) Child() []Plan {
+	return []Plan{reorder.Input}
+}
+
+func (reorder *ReorderPlan) TypeCheck() error {
+	return reorder.Input.TypeCheck()
+}
+
+func (reorder *ReorderPlan) Execute(ctx context.Context) *storage.RecordBatch {
+	batch := executePlan(ctx, reorder.Input)
+	if batch == nil {
+		return nil
+	}
+	ret := &storage.RecordBatch{
+		Fields:  make([]storage.Field, len(reorder.Order)),
+		Records: make([]*storage.ColumnVector, len(reorder.Order)),
+	}
+	for i, j := range reorder.Order {
+		ret.Fields[i], ret.Records[i] = batch.Fields[j], batch.Records[j]
+	}
+	return ret
+}
+
+func (reorder *ReorderPlan) Reset() {
+	reorder.Input.Reset()
+}
+
 // For where where_condition
 type SelectionPlan struct {
 	Input Plan `json:"select_input"`
@@ -268,10 +737,10 @@ func MakeEmptyRecordBatchFromSchema(schema *storage.TableSchema) *storage.Record
 	return ret
 }
 
-func (sel *SelectionPlan) Execute() (ret *storage.RecordBatch) {
+func (sel *SelectionPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
 	i := 0
 	for i < batchSize {
-		recordBatch := sel.Input.Execute()
+		recordBatch := executePlan(ctx, sel.Input)
 		if recordBatch == nil {
 			return ret
 		}
@@ -333,9 +802,9 @@ func (orderBy *OrderByPlan) TypeCheck() error {
 	return orderBy.OrderBy.AggrTypeCheck(orderBy.Input.(*GroupByPlan).GroupByExpr)
 }
 
-func (orderBy *OrderByPlan) Execute() *storage.RecordBatch {
+func (orderBy *OrderByPlan) Execute(ctx context.Context) *storage.RecordBatch {
 	if orderBy.data == nil {
-		orderBy.InitializeAndSort()
+		orderBy.InitializeAndSort(ctx)
 	}
 	if orderBy.data == nil {
 		return nil
@@ -345,18 +814,18 @@ func (orderBy *OrderByPlan) Execute() *storage.RecordBatch {
 	return ret
 }
 
-func (orderBy *OrderByPlan) InitializeAndSort() {
+func (orderBy *OrderByPlan) InitializeAndSort(ctx context.Context) {
 	if orderBy.data != nil {
 		return
 	}
-	batch := orderBy.Input.Execute()
+	batch := executePlan(ctx, orderBy.Input)
 	if batch == nil {
 		return
 	}
 	ret := MakeEmptyRecordBatchFromSchema(orderBy.Schema())
 	for batch != nil {
 		ret.Append(batch)
-		batch = orderBy.Input.Execute()
+		batch = executePlan(ctx, orderBy.Input)
 	}
 	columnVector := orderBy.OrderBy.Evaluate(ret)
 	ret.OrderBy(columnVector)
@@ -426,11 +895,11 @@ func (proj *ProjectionPlan) TypeCheck() error {
 	return nil
 }
 
-func (proj *ProjectionPlan) Execute() *storage.RecordBatch {
+func (proj *ProjectionPlan) Execute(ctx context.Context) *storage.RecordBatch {
 	if proj.IsAggr() {
-		return proj.ExecuteAccumulate()
+		return proj.ExecuteAccumulate(ctx)
 	}
-	records := proj.Input.Execute()
+	records := executePlan(ctx, proj.Input)
 	if records == nil {
 		return nil
 	}
@@ -450,8 +919,8 @@ func (proj *ProjectionPlan) Execute() *storage.RecordBatch {
 }
 
 // For query like: select sum(id) from test1;
-func (proj *ProjectionPlan) ExecuteAccumulate() (ret *storage.RecordBatch) {
-	records := proj.Input.Execute()
+func (proj *ProjectionPlan) ExecuteAccumulate(ctx context.Context) (ret *storage.RecordBatch) {
+	records := executePlan(ctx, proj.Input)
 	if records == nil {
 		return nil
 	}
@@ -461,7 +930,7 @@ func (proj *ProjectionPlan) ExecuteAccumulate() (ret *storage.RecordBatch) {
 				expr.Accumulate(i, records)
 			}
 		}
-		records = proj.Input.Execute()
+		records = executePlan(ctx, proj.Input)
 	}
 	ret = MakeEmptyRecordBatchFromSchema(proj.Schema())
 	ret.Records[0].Append(storage.EncodeInt(0))
@@ -474,6 +943,12 @@ func (proj *ProjectionPlan) ExecuteAccumulate() (ret *storage.RecordBatch) {
 
 func (proj *ProjectionPlan) Reset() {
 	proj.Input.Reset()
+	if proj.IsAggr() {
+		// Clears the accumulators, a correlated subquery is executed again after reset.
+		for i, expr := range proj.Exprs {
+			proj.Exprs[i] = expr.Clone(false).(AsExpr)
+		}
+	}
 }
 
 func (proj *ProjectionPlan) IsAggr() bool {
@@ -508,18 +983,18 @@ func (limit *LimitPlan) TypeCheck() error {
 	return limit.Input.TypeCheck()
 }
 
-func (limit *LimitPlan) Execute() *storage.RecordBatch {
+func (limit *LimitPlan) Execute(ctx context.Context) *storage.RecordBatch {
 	if limit.Count <= 0 || limit.Index-limit.Offset >= limit.Count {
 		return nil
 	}
-	batch := limit.Input.Execute()
+	batch := executePlan(ctx, limit.Input)
 	if batch == nil {
 		return nil
 	}
 	// Move index to close to offset first.
 	for batch != nil && limit.Index+batch.RowCount() <= limit.Offset {
 		limit.Index += batch.RowCount()
-		batch = limit.Input.Execute()
+		batch = executePlan(ctx, limit.Input)
 	}
 	// Doesn't have data starting from the offset.
 	if batch == nil {
@@ -544,3 +1019,75 @@ func (limit *LimitPlan) Reset() {
 	limit.Input.Reset()
 	limit.Index = 0
 }
+
+// DistinctPlan removes the duplicate rows of select distinct, the first row of duplicates is kept.
+// Rows are compared by all columns except the row index columns, nulls are equal to each other.
+type DistinctPlan struct {
+	Input Plan `json:"distinct_input"`
+	seen  map[string]bool
+	size  int // The memory size of seen keys.
+}
+
+func (distinct *DistinctPlan) Schema() *storage.TableSchema {
+	return distinct.Input.Schema()
+}
+
+func (distinct *DistinctPlan) String() string {
+	return fmt.Sprintf("DistinctPlan: %s", distinct.Input)
+}
+
+func (distinct *DistinctPlan) Child() []Plan {
+	return []Plan{distinct.Input}
+}
+
+func (distinct *DistinctPlan) TypeCheck() error {
+	return distinct.Input.TypeCheck()
+}
+
+func (distinct *DistinctPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
+	if distinct.seen == nil {
+		distinct.seen = map[string]bool{}
+	}
+	i := 0
+	for i < batchSize {
+		recordBatch := executePlan(ctx, distinct.Input)
+		if recordBatch == nil {
+			return
+		}
+		if ret == nil {
+			ret = MakeEmptyRecordBatchFromSchema(distinct.Schema())
+		}
+		selectedRows := &storage.ColumnVector{Field: storage.Field{TP: storage.DefaultFieldTpMap[storage.Bool]}}
+		for row := 0; row < recordBatch.RowCount(); row++ {
+			key := string(dataRowKey(recordBatch, row))
+			selectedRows.Append(storage.EncodeBool(!distinct.seen[key]))
+			if !distinct.seen[key] {
+				distinct.seen[key] = true
+				distinct.size += len(key)
+			}
+		}
+		selectedRecord := recordBatch.Filter(selectedRows)
+		ret.Append(selectedRecord)
+		i += selectedRecord.RowCount()
+	}
+	return
+}
+
+// dataRowKey is like RecordBatch.RowKey, but skips the row index columns.
+func dataRowKey(recordBatch *storage.RecordBatch, row int) (key []byte) {
+	for col := 0; col < recordBatch.ColumnCount(); col++ {
+		if recordBatch.IsRowIdColumn(col) {
+			continue
+		}
+		value := recordBatch.Records[col].RawValue(row)
+		key = append(key, storage.EncodeInt(int64(len(value)))...)
+		key = append(key, value...)
+	}
+	return
+}
+
+func (distinct *DistinctPlan) Reset() {
+	distinct.Input.Reset()
+	distinct.seen = nil
+	distinct.size = 0
+}
@@ -4,40 +4,87 @@ import (
 	"errors"
 	"fmt"
 	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
 	"strings"
 )
 
 func MakePlan(ast *parser.SelectStm, currentDB string) (Plan, error) {
-	scanPlans, err := makeScanPlans(ast.TableReferences, currentDB)
+	return makeQueryPlan(ast, &queryScope{CurrentDB: currentDB})
+}
+
+// makeQueryPlan makes the type checked plan of ast, scope is not the root scope for subqueries.
+func makeQueryPlan(ast *parser.SelectStm, scope *queryScope) (Plan, error) {
+	if ast.With != nil {
+		return makeWithPlan(ast, scope)
+	}
+	if len(ast.SetOperations) > 0 {
+		return makeSetOperationPlan(ast, scope)
+	}
+	selectPlan, err := makeOrderedJoinPlan(ast.TableReferences, ast.Where, scope)
 	if err != nil {
 		return nil, err
 	}
-	Plan := makeJoinPlan(scanPlans)
-	selectPlan := makeSelectPlan(Plan, ast.Where)
 	if ast.Groupby != nil {
-		return MakeAggrePlan(selectPlan, ast)
+		return MakeAggrePlan(selectPlan, ast, scope)
 	}
 	// having is the same as where when no group by.
 	if ast.Having != nil {
-		selectPlan = makeSelectPlan(selectPlan, parser.WhereStm(ast.Having))
+		selectPlan = makeSelectPlan(selectPlan, parser.WhereStm(ast.Having), scope)
+	}
+	windowPlan := makeWindowPlan(selectPlan, ast, scope)
+	orderByPlan := makeOrderByPlan(windowPlan, ast.OrderBy, false, scope)
+	projectionsPlan := makeProjectionPlan(orderByPlan, ast.SelectExpressions, scope)
+	distinctPlan := makeDistinctPlan(projectionsPlan, ast.Tp)
+	limitPlan := makeLimitPlan(distinctPlan, ast.LimitStm)
+	return limitPlan, limitPlan.TypeCheck()
+}
+
+// makeSetOperationPlan makes the plan of select ... {union | intersect | except} select ....
+// Intersect binds tighter than union and except, which are evaluated from left to right.
+func makeSetOperationPlan(ast *parser.SelectStm, scope *queryScope) (Plan, error) {
+	first := *ast
+	first.SetOperations, first.OrderBy, first.LimitStm = nil, nil, nil
+	plan, err := makeQueryPlan(&first, scope)
+	if err != nil {
+		return nil, err
 	}
-	orderByPlan := makeOrderByPlan(selectPlan, ast.OrderBy, false)
-	projectionsPlan := makeProjectionPlan(orderByPlan, ast.SelectExpressions)
-	limitPlan := makeLimitPlan(projectionsPlan, ast.LimitStm)
+	// The operands of union and except.
+	var plans []Plan
+	var ops []parser.SetOperationStm
+	for _, op := range ast.SetOperations {
+		right, err := makeQueryPlan(op.Select, scope)
+		if err != nil {
+			return nil, err
+		}
+		if op.Tp == parser.IntersectTp {
+			plan = &SetOperationPlan{LeftPlan: plan, Tp: op.Tp, All: op.All, RightPlan: right}
+			continue
+		}
+		plans = append(plans, plan)
+		ops = append(ops, op)
+		plan = right
+	}
+	plans = append(plans, plan)
+	plan = plans[0]
+	for i, op := range ops {
+		plan = &SetOperationPlan{LeftPlan: plan, Tp: op.Tp, All: op.All, RightPlan: plans[i+1]}
+	}
+	orderByPlan := makeOrderByPlan(plan, ast.OrderBy, false, scope)
+	limitPlan := makeLimitPlan(orderByPlan, ast.LimitStm)
 	return limitPlan, limitPlan.TypeCheck()
 }
 
-func makeScanPlans(tableRefs []parser.TableReferenceStm, currentDB string) (ret []Plan, err error) {
+func makeScanPlans(tableRefs []parser.TableReferenceStm, scope *queryScope) (ret []Plan, err error) {
 	for _, tableRef := range tableRefs {
 		switch tableRef.Tp {
 		case parser.TableReferenceTableFactorTp:
-			plan, err := makeScanPlan(tableRef.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
+			plan, err := makeScanPlan(tableRef.TableReference.(parser.TableReferenceTableFactorStm), scope)
 			if err != nil {
 				return nil, err
 			}
 			ret = append(ret, plan)
 		case parser.TableReferenceJoinTableTp: // Build scanPlan for the join op.
-			plan, err := makeScanPlanForJoin(tableRef.TableReference.(parser.JoinedTableStm), currentDB)
+			plan, err := makeScanPlanForJoin(tableRef.TableReference.(parser.JoinedTableStm), scope)
 			if err != nil {
 				return nil, err
 			}
@@ -49,7 +96,7 @@ func makeScanPlans(tableRefs []parser.TableReferenceStm, currentDB string) (ret
 	return
 }
 
-func makeScanPlan(tableRefTableFactorStm parser.TableReferenceTableFactorStm, currentDB string) (Plan, error) {
+func makeScanPlan(tableRefTableFactorStm parser.TableReferenceTableFactorStm, scope *queryScope) (Plan, error) {
 	switch tableRefTableFactorStm.Tp {
 	case parser.TableReferencePureTableNameTp:
 		table := tableRefTableFactorStm.TableFactorReference.(parser.TableReferencePureTableRefStm)
@@ -57,20 +104,39 @@ func makeScanPlan(tableRefTableFactorStm parser.TableReferenceTableFactorStm, cu
 		if err != nil {
 			return nil, err
 		}
-		if schemaName == "" {
-			schemaName = currentDB
-		}
 		if table.Alias == "" {
 			table.Alias = table.TableName
 		}
+		if cte := scope.CTEs.find(table.TableName); cte != nil {
+			input, err := makeCTEPlan(cte, scope)
+			if err != nil {
+				return nil, err
+			}
+			return &DerivedPlan{Input: input, Alias: table.Alias, Columns: cte.Cols}, nil
+		}
+		if schemaName == "" {
+			schemaName = scope.CurrentDB
+		}
+		scope.useTable(schemaName, tableName)
 		return &ScanPlan{
 			Name:       tableName,
 			SchemaName: schemaName,
 			Alias:      table.Alias,
 			Input:      &TableScan{Name: tableName, SchemaName: schemaName},
 		}, nil
-	case parser.TableReferenceTableSubQueryTp, parser.TableReferenceSubTableReferenceStmTP:
-		panic("doesn't support sub query currently")
+	case parser.TableReferenceTableSubQueryTp:
+		subQuery := tableRefTableFactorStm.TableFactorReference.(parser.TableSubQueryStm)
+		// Derived tables cannot refer to the columns of outer queries.
+		input, err := makeQueryPlan(subQuery.Select, &queryScope{CurrentDB: scope.CurrentDB, CTEs: scope.CTEs,
+			Params: scope.Params, prepared: scope.prepared})
+		if err != nil {
+			return nil, err
+		}
+		return &DerivedPlan{Input: input, Alias: subQuery.Alias}, nil
+	case parser.TableReferenceSubTableReferenceStmTP:
+		// The inner joins in the parentheses are reordered separately.
+		tableRef := tableRefTableFactorStm.TableFactorReference.(parser.TableReferenceStm)
+		return makeOrderedJoinPlan([]parser.TableReferenceStm{tableRef}, nil, scope)
 	}
 	return nil, nil
 }
@@ -91,26 +157,44 @@ func splitSchemaAndTableName(schemaTable string) (schema, table string, err erro
 	return
 }
 
-func joinSpecToExpr(joinSpex *parser.JoinSpecification, input *JoinPlan) (expr Expr) {
+func joinSpecToExpr(joinSpex *parser.JoinSpecification, input *JoinPlan, scope *queryScope) (expr Expr) {
 	if joinSpex == nil {
 		return nil
 	}
 	switch joinSpex.Tp {
 	case parser.JoinSpecificationON:
-		expr = ExprStmToExpr(joinSpex.Condition.(*parser.ExpressionStm), input)
+		expr = ExprStmToExpr(joinSpex.Condition.(*parser.ExpressionStm), input, scope)
 	case parser.JoinSpecificationUsing:
 		return buildExprForUsing(joinSpex, input)
+	case parser.JoinSpecificationNatural:
+		using := &parser.JoinSpecification{Tp: parser.JoinSpecificationUsing, Condition: naturalJoinColumns(input)}
+		return buildExprForUsing(using, input)
 	default:
 		panic("unknown join tp")
 	}
 	return
 }
 
+// naturalJoinColumns returns the column names both sides of input have, a natural join without
+// common columns is a cross join.
+func naturalJoinColumns(input *JoinPlan) (cols []string) {
+	rightSchema := input.RightPlan.Schema()
+	seen := map[string]bool{}
+	for _, field := range dataFields(input.LeftPlan.Schema()) {
+		if seen[field.Name] || !rightSchema.HasColumn("", "", field.Name) {
+			continue
+		}
+		seen[field.Name] = true
+		cols = append(cols, field.Name)
+	}
+	return
+}
+
 func buildExprForUsing(joinSpex *parser.JoinSpecification, input *JoinPlan) (expr Expr) {
 	cols := joinSpex.Condition.([]string)
 	for i, col := range cols {
-		leftColName := []byte(fmt.Sprintf("%s.%s", input.LeftPlan.Schema().TableName(), col))
-		rightColName := []byte(fmt.Sprintf("%s.%s", input.RightPlan.Schema().TableName(), col))
+		leftColName := []byte(usingColumnName(input.LeftPlan.Schema(), col))
+		rightColName := []byte(usingColumnName(input.RightPlan.Schema(), col))
 		if i == 0 {
 			expr = EqualExpr{
 				Left:  &IdentifierExpr{Ident: leftColName, input: input},
@@ -132,50 +216,54 @@ func buildExprForUsing(joinSpex *parser.JoinSpecification, input *JoinPlan) (exp
 	return
 }
 
+// usingColumnName qualifies col by the table having it in schema, which can be a join of tables.
+func usingColumnName(schema *storage.TableSchema, col string) string {
+	tableName := schema.TableName()
+	if field := schema.GetField("", "", col); field != nil {
+		tableName = field.TableName
+	}
+	return fmt.Sprintf("%s.%s", tableName, col)
+}
+
 // Build join plan recursively.
-func makeScanPlanForJoin(joinTableStm parser.JoinedTableStm, currentDB string) (Plan, error) {
+func makeScanPlanForJoin(joinTableStm parser.JoinedTableStm, scope *queryScope) (Plan, error) {
 	// a inorder traversal to build  plan.
-	leftPlan, err := makeScanPlan(joinTableStm.TableFactor, currentDB)
+	leftPlan, err := makeScanPlan(joinTableStm.TableFactor, scope)
 	if err != nil {
 		return nil, err
 	}
-	rightPlan, err := makeScanPlan(joinTableStm.JoinFactors[0].JoinedTableReference.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
-	if err != nil {
-		return nil, err
-	}
-	joinPlan := NewJoinPlan(leftPlan, rightPlan, joinTableStm.JoinFactors[0].JoinTp)
-	expr := joinSpecToExpr(joinTableStm.JoinFactors[0].JoinSpec, joinPlan)
-	if expr == nil {
-		return buildRemainJoinPlan(joinPlan, joinTableStm.JoinFactors[1:], currentDB)
-	}
-	plan := &SelectionPlan{Input: joinPlan, Expr: expr}
-	return buildRemainJoinPlan(plan, joinTableStm.JoinFactors[1:], currentDB)
+	return buildRemainJoinPlan(leftPlan, joinTableStm.JoinFactors, scope)
 }
 
-// Build  plan for tableFactors[1:]
-func buildRemainJoinPlan(selectionPlan Plan, tableFactors []parser.JoinFactor, currentDB string) (Plan, error) {
+// Build  plan for tableFactors
+func buildRemainJoinPlan(selectionPlan Plan, tableFactors []parser.JoinFactor, scope *queryScope) (Plan, error) {
 	if len(tableFactors) == 0 {
 		return selectionPlan, nil
 	}
-	rightPlan, err := makeScanPlan(tableFactors[0].JoinedTableReference.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
+	rightPlan, err := makeScanPlan(tableFactors[0].JoinedTableReference.TableReference.(parser.TableReferenceTableFactorStm), scope)
 	if err != nil {
 		return nil, err
 	}
 	joinPlan := NewJoinPlan(selectionPlan, rightPlan, tableFactors[0].JoinTp)
-	expr := joinSpecToExpr(tableFactors[0].JoinSpec, joinPlan)
+	expr := joinSpecToExpr(tableFactors[0].JoinSpec, joinPlan, scope)
 	if expr == nil {
-		return buildRemainJoinPlan(joinPlan, tableFactors[1:], currentDB)
+		return buildRemainJoinPlan(joinPlan, tableFactors[1:], scope)
+	}
+	if joinPlan.JoinType == parser.FullOuterJoin {
+		// The unmatched rows of both sides are kept, so the condition is evaluated by the join.
+		joinPlan.Expr = expr
+		return buildRemainJoinPlan(joinPlan, tableFactors[1:], scope)
 	}
 	plan := &SelectionPlan{Input: joinPlan, Expr: expr}
-	return buildRemainJoinPlan(plan, tableFactors[1:], currentDB)
+	return buildRemainJoinPlan(plan, tableFactors[1:], scope)
 }
 
-func buildPlanForTableReferenceStm(tableRef parser.TableReferenceStm, currentDB string) (Plan, error) {
+func buildPlanForTableReferenceStm(tableRef parser.TableReferenceStm, scope *queryScope) (Plan, error) {
 	switch tableRef.Tp {
 	case parser.TableReferenceTableFactorTp:
-		return makeScanPlan(tableRef.TableReference.(parser.TableReferenceTableFactorStm), currentDB)
+		return makeScanPlan(tableRef.TableReference.(parser.TableReferenceTableFactorStm), scope)
 	case parser.TableReferenceJoinTableTp:
-		return makeScanPlanForJoin(tableRef.TableReference.(parser.JoinedTableStm), currentDB)
+		return makeScanPlanForJoin(tableRef.TableReference.(parser.JoinedTableStm), scope)
 	default:
 		panic("wrong tableRef type")
 	}
@@ -194,36 +282,53 @@ func makeJoinPlan(input []Plan) Plan {
 	return leftPlan
 }
 
-func makeSelectPlan(input Plan, whereStm parser.WhereStm) Plan {
+func makeSelectPlan(input Plan, whereStm parser.WhereStm, scope *queryScope) Plan {
 	if whereStm == nil {
 		return input
 	}
 	return &SelectionPlan{
 		Input: input,
-		Expr:  ExprStmToExpr(whereStm, input),
+		Expr:  ExprStmToExpr(whereStm, input, scope),
 	}
 }
 
-func ExprStmToExpr(expr *parser.ExpressionStm, input Plan) Expr {
+func ExprStmToExpr(expr *parser.ExpressionStm, input Plan, scope *queryScope) Expr {
 	if expr == nil {
 		return nil
 	}
 	var leftExpr, rightExpr Expr
 	_, isLeftExprExprStm := expr.LeftExpr.(*parser.ExpressionStm)
 	if isLeftExprExprStm {
-		leftExpr = ExprStmToExpr(expr.LeftExpr.(*parser.ExpressionStm), input)
+		leftExpr = ExprStmToExpr(expr.LeftExpr.(*parser.ExpressionStm), input, scope)
 	} else {
-		leftExpr = ExprTermStmToExpr(expr.LeftExpr.(*parser.ExpressionTerm), input)
+		leftExpr = ExprTermStmToExpr(expr.LeftExpr.(*parser.ExpressionTerm), input, scope)
 	}
 	if expr.RightExpr == nil {
 		return leftExpr
 	}
+	if term, ok := expr.RightExpr.(*parser.ExpressionTerm); ok && term.Tp == parser.ListExpressionTermTP {
+		list := ExprStmsToExprs(term.RealExprTerm.(parser.ExpressionListStm), input, scope)
+		for _, value := range list {
+			inferParamType(value, leftExpr)
+		}
+		if len(list) > 0 {
+			inferParamType(leftExpr, list[0])
+		}
+		return buildExprWithList(leftExpr, list, expr.Op)
+	}
+	if term, ok := expr.RightExpr.(*parser.ExpressionTerm); ok && term.Tp == parser.SubQueryExpressionTermTP &&
+		(expr.Op.Tp == parser.IN || expr.Op.Tp == parser.NOTIN) {
+		subQuery := makeSubQuery(term.RealExprTerm.(parser.SubQueryStm), input, scope)
+		return &InSubQueryExpr{Expr: leftExpr, subQuery: subQuery, Not: expr.Op.Tp == parser.NOTIN}
+	}
 	_, isRightExprExprStm := expr.RightExpr.(*parser.ExpressionStm)
 	if isRightExprExprStm {
-		rightExpr = ExprStmToExpr(expr.RightExpr.(*parser.ExpressionStm), input)
+		rightExpr = ExprStmToExpr(expr.RightExpr.(*parser.ExpressionStm), input, scope)
 	} else {
-		rightExpr = ExprTermStmToExpr(expr.RightExpr.(*parser.ExpressionTerm), input)
+		rightExpr = ExprTermStmToExpr(expr.RightExpr.(*parser.ExpressionTerm), input, scope)
 	}
+	inferParamType(leftExpr, rightExpr)
+	inferParamType(rightExpr, leftExpr)
 	return buildExprWithOp(leftExpr, rightExpr, expr.Op)
 }
 
@@ -243,6 +348,8 @@ func buildExprWithOp(leftExpr, rightExpr Expr, op *parser.ExpressionOp) Expr {
 		return EqualExpr{Left: leftExpr, Right: rightExpr, Name: "="}
 	case parser.IS:
 		return IsExpr{Left: leftExpr, Right: rightExpr, Name: "is"}
+	case parser.ISNOT:
+		return IsNotExpr{Left: leftExpr, Right: rightExpr, Name: "is not"}
 	case parser.NOTEQUAL:
 		return NotEqualExpr{Left: leftExpr, Right: rightExpr, Name: "!="}
 	case parser.GREAT:
@@ -269,24 +376,56 @@ func buildExprWithOp(leftExpr, rightExpr Expr, op *parser.ExpressionOp) Expr {
 	}
 }
 
-func ExprTermStmToExpr(exprTerm *parser.ExpressionTerm, input Plan) Expr {
+// buildExprWithList builds the in, between and like expression, list is the right operand, see
+// parser.ExpressionListStm.
+func buildExprWithList(leftExpr Expr, list []Expr, op *parser.ExpressionOp) Expr {
+	switch op.Tp {
+	case parser.IN, parser.NOTIN:
+		return InExpr{Expr: leftExpr, Values: list, Not: op.Tp == parser.NOTIN, Name: op.Name}
+	case parser.BETWEEN, parser.NOTBETWEEN:
+		return BetweenExpr{Expr: leftExpr, Low: list[0], High: list[1], Not: op.Tp == parser.NOTBETWEEN, Name: op.Name}
+	case parser.LIKE, parser.NOTLIKE:
+		like := LikeExpr{Expr: leftExpr, Pattern: list[0], Not: op.Tp == parser.NOTLIKE, Name: op.Name}
+		if len(list) > 1 {
+			like.Escape = list[1]
+		}
+		return like
+	default:
+		panic("wrong op type")
+	}
+}
+
+func ExprTermStmToExpr(exprTerm *parser.ExpressionTerm, input Plan, scope *queryScope) Expr {
 	var expr Expr
 	switch exprTerm.Tp {
 	case parser.LiteralExpressionTermTP:
 		expr = LiteralExprToLiteralExpr(exprTerm.RealExprTerm.(parser.LiteralExpressionStm))
 	case parser.IdentifierExpressionTermTP:
-		expr = IdentifierExprToIdentifierExpr(exprTerm.RealExprTerm.(parser.IdentifierExpression), input)
+		expr = IdentifierExprToIdentifierExpr(exprTerm.RealExprTerm.(parser.IdentifierExpression), input, scope)
 	case parser.FuncCallExpressionTermTP:
-		expr = FuncCallExprToExpr(exprTerm.RealExprTerm.(parser.FunctionCallExpressionStm), input)
+		expr = FuncCallExprToExpr(exprTerm.RealExprTerm.(parser.FunctionCallExpressionStm), input, scope)
 	case parser.SubExpressionTermTP:
-		expr = ExprStmToExpr(exprTerm.RealExprTerm.(*parser.ExpressionStm), input)
+		expr = ExprStmToExpr(exprTerm.RealExprTerm.(*parser.ExpressionStm), input, scope)
 	case parser.AllExpressionTermTP:
 		expr = &AllExpr{input: input, Str: "*"}
+	case parser.CaseExpressionTermTP:
+		expr = CaseExprToExpr(exprTerm.RealExprTerm.(parser.CaseExpressionStm), input, scope)
+	case parser.SubQueryExpressionTermTP:
+		subQuery := makeSubQuery(exprTerm.RealExprTerm.(parser.SubQueryStm), input, scope)
+		expr = &SubQueryExpr{subQuery: subQuery, Str: "(subquery)"}
+	case parser.ExistsSubQueryExpressionTermTP:
+		exists := exprTerm.RealExprTerm.(parser.ExistsSubQueryExpressionStm)
+		expr = &ExistsExpr{subQuery: makeSubQuery(exists.SubQuery, input, scope), Not: !exists.Exists}
+	case parser.ParamExpressionTermTP:
+		expr = scope.paramExpr(int(exprTerm.RealExprTerm.(parser.ParamMarkerStm)))
 	default:
 		panic("unknown expr term type")
 	}
-	if exprTerm.UnaryOp == parser.NegativeUnaryOpTp {
+	switch exprTerm.UnaryOp {
+	case parser.NegativeUnaryOpTp:
 		return NegativeExpr{Expr: expr}
+	case parser.NotUnaryOpTp:
+		return NotExpr{Expr: expr, Name: "not"}
 	}
 	return expr
 }
@@ -297,44 +436,97 @@ func LiteralExprToLiteralExpr(literalExprStm parser.LiteralExpressionStm) Expr {
 	return ret
 }
 
-func IdentifierExprToIdentifierExpr(identifierExpr parser.IdentifierExpression, input Plan) Expr {
+func IdentifierExprToIdentifierExpr(identifierExpr parser.IdentifierExpression, input Plan, scope *queryScope) Expr {
+	if outerRef := scope.outerRef(identifierExpr, input); outerRef != nil {
+		return outerRef
+	}
 	return &IdentifierExpr{Ident: identifierExpr, input: input, Str: string(identifierExpr)}
 }
 
-func FuncCallExprToExpr(funcCallExpr parser.FunctionCallExpressionStm, input Plan) Expr {
+func FuncCallExprToExpr(funcCallExpr parser.FunctionCallExpressionStm, input Plan, scope *queryScope) Expr {
+	// values(col) references the inserted value of col, see upsertSchema.
+	if funcCallExpr.FuncName == "VALUES" {
+		ident := funcCallExpr.Params[0].LeftExpr.(*parser.ExpressionTerm).RealExprTerm.(parser.IdentifierExpression)
+		_, _, columnName := getSchemaTableColumnName(string(ident))
+		return IdentifierExprToIdentifierExpr(parser.IdentifierExpression(insertedValueName(columnName)), input, scope)
+	}
 	params := make([]Expr, len(funcCallExpr.Params))
 	for i, param := range funcCallExpr.Params {
-		params[i] = ExprStmToExpr(param, input)
+		params[i] = ExprStmToExpr(param, input, scope)
+	}
+	if funcCallExpr.Over != nil {
+		return WindowExprToExpr(funcCallExpr, params, input, scope)
+	}
+	switch strings.ToUpper(funcCallExpr.FuncName) {
+	case "IF":
+		return IfExpr{FuncName: funcCallExpr.FuncName, Params: params}
+	case "IFNULL", "COALESCE":
+		return CoalesceExpr{FuncName: funcCallExpr.FuncName, Params: params}
+	case "NULLIF":
+		return NullIfExpr{FuncName: funcCallExpr.FuncName, Params: params}
+	}
+	ret := MakeFuncCallExpr(funcCallExpr.FuncName, funcCallExpr.Distinct, params)
+	return ret
+}
+
+func WindowExprToExpr(funcCallExpr parser.FunctionCallExpressionStm, params []Expr, input Plan, scope *queryScope) Expr {
+	ret := &WindowExpr{
+		FuncName:    funcCallExpr.FuncName,
+		Distinct:    funcCallExpr.Distinct,
+		Params:      params,
+		PartitionBy: ExprStmsToExprs(funcCallExpr.Over.PartitionBy, input, scope),
+		Frame:       funcCallExpr.Over.Frame,
+		input:       input,
+	}
+	if funcCallExpr.Over.OrderBy != nil {
+		ret.OrderBy = OrderedExpressionToOrderedExprs(funcCallExpr.Over.OrderBy.Expressions, input, scope)
+	}
+	return ret
+}
+
+func CaseExprToExpr(caseExpr parser.CaseExpressionStm, input Plan, scope *queryScope) Expr {
+	ret := CaseExpr{
+		Value: ExprStmToExpr(caseExpr.Value, input, scope),
+		Whens: ExprStmsToExprs(caseExpr.Whens, input, scope),
+		Thens: ExprStmsToExprs(caseExpr.Thens, input, scope),
+		Else:  ExprStmToExpr(caseExpr.Else, input, scope),
+		Name:  "case",
 	}
-	ret := MakeFuncCallExpr(funcCallExpr.FuncName, params)
 	return ret
 }
 
 //func SubExprTermToExpr(subExpr parser.SubExpressionTerm, input Plan) Expr {
 //	Expr := parser.ExpressionTerm(subExpr)
-//	return ExprTermStmToExpr(&Expr, input)
+//	return ExprTermStmToExpr(&Expr, input, scope)
 //}
 
-func OrderedExpressionToOrderedExprs(orderedExprs []*parser.OrderedExpressionStm, input Plan) OrderByExpr {
+func OrderedExpressionToOrderedExprs(orderedExprs []*parser.OrderedExpressionStm, input Plan, scope *queryScope) OrderByExpr {
 	ret := OrderByExpr{}
 	for _, expr := range orderedExprs {
-		ret.Expr = append(ret.Expr, ExprStmToExpr(expr.Expression, input))
+		ret.Expr = append(ret.Expr, ExprStmToExpr(expr.Expression, input, scope))
 		ret.Asc = append(ret.Asc, expr.Asc)
 	}
 	return ret
 }
 
-func makeOrderByPlan(input Plan, orderBy *parser.OrderByStm, isAggr bool) Plan {
+func makeOrderByPlan(input Plan, orderBy *parser.OrderByStm, isAggr bool, scope *queryScope) Plan {
 	if orderBy == nil {
 		return input
 	}
 	return &OrderByPlan{
 		Input:   input,
-		OrderBy: OrderedExpressionToOrderedExprs(orderBy.Expressions, input),
+		OrderBy: OrderedExpressionToOrderedExprs(orderBy.Expressions, input, scope),
 		IsAggr:  isAggr,
 	}
 }
 
+func makeDistinctPlan(input Plan, tp parser.SelectTp) Plan {
+	if tp == parser.SelectAllTp {
+		return input
+	}
+	return &DistinctPlan{Input: input}
+}
+
 func makeLimitPlan(input Plan, limitStm *parser.LimitStm) Plan {
 	if limitStm == nil {
 		return input
@@ -346,18 +538,18 @@ func makeLimitPlan(input Plan, limitStm *parser.LimitStm) Plan {
 	}
 }
 
-func SelectExprToAsExpr(selectExprs []*parser.SelectExpr, input Plan) []AsExpr {
+func SelectExprToAsExpr(selectExprs []*parser.SelectExpr, input Plan, scope *queryScope) []AsExpr {
 	ret := make([]AsExpr, len(selectExprs))
 	for i := 0; i < len(selectExprs); i++ {
 		as := AsExpr{}
-		as.Expr = ExprStmToExpr(selectExprs[i].Expr, input)
+		as.Expr = ExprStmToExpr(selectExprs[i].Expr, input, scope)
 		as.Alias = selectExprs[i].Alias
 		ret[i] = as
 	}
 	return ret
 }
 
-func makeProjectionPlan(input Plan, selectExprStm *parser.SelectExpressionStm) *ProjectionPlan {
+func makeProjectionPlan(input Plan, selectExprStm *parser.SelectExpressionStm, scope *queryScope) *ProjectionPlan {
 	projectionPlan := &ProjectionPlan{
 		Input: input,
 	}
@@ -365,7 +557,7 @@ func makeProjectionPlan(input Plan, selectExprStm *parser.SelectExpressionStm) *
 	case parser.StarSelectExpressionTp:
 		return projectionPlan
 	case parser.ExprSelectExpressionTp:
-		projectionPlan.Exprs = SelectExprToAsExpr(selectExprStm.Expr.([]*parser.SelectExpr), input)
+		projectionPlan.Exprs = SelectExprToAsExpr(selectExprStm.Expr.([]*parser.SelectExpr), input, scope)
 	}
 	return projectionPlan
 }
@@ -0,0 +1,515 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/storage"
+	"strings"
+)
+
+// A paramsExpr is an expression computed from the values of its params row by row, like
+// in, between and like. The methods of Expr can be implemented by the helpers below.
+type paramsExpr interface {
+	Expr
+	params() []Expr
+	// compute returns the result of a row, tps are the types of params.
+	compute(values [][]byte, tps []storage.FieldTP) []byte
+}
+
+func paramTypes(params []Expr) []storage.FieldTP {
+	ret := make([]storage.FieldTP, len(params))
+	for i, param := range params {
+		ret[i] = param.toField().TP
+	}
+	return ret
+}
+
+func evaluateParamsExpr(expr paramsExpr, input *storage.RecordBatch) *storage.ColumnVector {
+	params := expr.params()
+	tps := paramTypes(params)
+	columnVectors := make([]*storage.ColumnVector, len(params))
+	for i, param := range params {
+		columnVectors[i] = param.Evaluate(input)
+	}
+	ret := &storage.ColumnVector{Field: expr.toField()}
+	for row := 0; row < input.RowCount(); row++ {
+		values := make([][]byte, len(params))
+		for i, columnVector := range columnVectors {
+			values[i] = columnVector.RawValue(row)
+		}
+		ret.Append(expr.compute(values, tps))
+	}
+	return ret
+}
+
+func evaluateRowParamsExpr(expr paramsExpr, row int, input *storage.RecordBatch) []byte {
+	params := expr.params()
+	values := make([][]byte, len(params))
+	for i, param := range params {
+		values[i] = param.EvaluateRow(row, input)
+	}
+	return expr.compute(values, paramTypes(params))
+}
+
+func aggrTypeCheckParamsExpr(expr paramsExpr, groupByExpr []Expr) error {
+	matched := true
+	for _, param := range expr.params() {
+		matched = matched && param.AggrTypeCheck(groupByExpr) == nil
+	}
+	if matched {
+		return nil
+	}
+	for _, groupBy := range groupByExpr {
+		if expr.String() == groupBy.String() {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("%s doesn't match group by clause", expr))
+}
+
+func accumulateParamsExpr(expr paramsExpr, row int, input *storage.RecordBatch) {
+	for _, param := range expr.params() {
+		param.Accumulate(row, input)
+	}
+}
+
+func accumulateValueParamsExpr(expr paramsExpr) []byte {
+	params := expr.params()
+	values := make([][]byte, len(params))
+	for i, param := range params {
+		values[i] = param.AccumulateValue()
+	}
+	return expr.compute(values, paramTypes(params))
+}
+
+func hasGroupFuncParamsExpr(expr paramsExpr) bool {
+	for _, param := range expr.params() {
+		if param.HasGroupFunc() {
+			return true
+		}
+	}
+	return false
+}
+
+func computeParamsExpr(expr paramsExpr) ([]byte, error) {
+	params := expr.params()
+	values := make([][]byte, len(params))
+	for i, param := range params {
+		value, err := param.Compute()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return expr.compute(values, paramTypes(params)), nil
+}
+
+func cloneExprs(exprs []Expr, cloneAccumulator bool) []Expr {
+	ret := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		ret[i] = expr.Clone(cloneAccumulator)
+	}
+	return ret
+}
+
+// typeCheckOp type checks expr and params, and checks expr can op with every param.
+func typeCheckOp(expr Expr, params []Expr, opType storage.OpType) error {
+	err := expr.TypeCheck()
+	if err != nil {
+		return err
+	}
+	field := expr.toField()
+	for _, param := range params {
+		err = param.TypeCheck()
+		if err != nil {
+			return err
+		}
+		err = field.CanOp(param.toField(), opType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notPrefix(not bool) string {
+	if not {
+		return "not "
+	}
+	return ""
+}
+
+type NotExpr struct {
+	Expr Expr
+	Name string
+}
+
+func (not NotExpr) params() []Expr {
+	return []Expr{not.Expr}
+}
+
+func (not NotExpr) compute(values [][]byte, _ []storage.FieldTP) []byte {
+	return storage.Not(values[0])
+}
+
+func (not NotExpr) toField() storage.Field {
+	return storage.Field{Name: not.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (not NotExpr) String() string {
+	return fmt.Sprintf("not %s", not.Expr)
+}
+
+func (not NotExpr) TypeCheck() error {
+	err := not.Expr.TypeCheck()
+	if err != nil {
+		return err
+	}
+	field := not.Expr.toField()
+	return field.CanOp(field, storage.NotOpType)
+}
+
+func (not NotExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(not, input)
+}
+
+func (not NotExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(not, row, input)
+}
+
+func (not NotExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(not, groupByExpr)
+}
+
+func (not NotExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(not, row, input)
+}
+
+func (not NotExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(not)
+}
+
+func (not NotExpr) Clone(cloneAccumulator bool) Expr {
+	return NotExpr{Expr: not.Expr.Clone(cloneAccumulator), Name: not.Name}
+}
+
+func (not NotExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(not)
+}
+
+func (not NotExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(not)
+}
+
+type IsNotExpr struct {
+	Left  Expr
+	Right Expr
+	Name  string
+}
+
+func (isNot IsNotExpr) params() []Expr {
+	return []Expr{isNot.Left, isNot.Right}
+}
+
+func (isNot IsNotExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	return storage.IsNot(values[0], tps[0], values[1], tps[1])
+}
+
+func (isNot IsNotExpr) toField() storage.Field {
+	return storage.Field{Name: isNot.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (isNot IsNotExpr) String() string {
+	return fmt.Sprintf("%s is not %s", isNot.Left, isNot.Right)
+}
+
+func (isNot IsNotExpr) TypeCheck() error {
+	return typeCheckOp(isNot.Left, []Expr{isNot.Right}, storage.IsNotOpType)
+}
+
+func (isNot IsNotExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(isNot, input)
+}
+
+func (isNot IsNotExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(isNot, row, input)
+}
+
+func (isNot IsNotExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(isNot, groupByExpr)
+}
+
+func (isNot IsNotExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(isNot, row, input)
+}
+
+func (isNot IsNotExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(isNot)
+}
+
+func (isNot IsNotExpr) Clone(cloneAccumulator bool) Expr {
+	return IsNotExpr{
+		Left:  isNot.Left.Clone(cloneAccumulator),
+		Right: isNot.Right.Clone(cloneAccumulator),
+		Name:  isNot.Name,
+	}
+}
+
+func (isNot IsNotExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(isNot)
+}
+
+func (isNot IsNotExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(isNot)
+}
+
+// InExpr is like expr [not] in (value, ...).
+type InExpr struct {
+	Expr   Expr
+	Values []Expr
+	Not    bool
+	Name   string
+}
+
+func (in InExpr) params() []Expr {
+	return append([]Expr{in.Expr}, in.Values...)
+}
+
+// compute returns null if expr is null, or no value matches and some value is null.
+func (in InExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	if storage.IsNullValue(values[0], tps[0]) {
+		return nil
+	}
+	found, hasNull := false, false
+	for i := 1; i < len(values) && !found; i++ {
+		if storage.IsNullValue(values[i], tps[i]) {
+			hasNull = true
+			continue
+		}
+		found = storage.DecodeBool(storage.Equal(values[0], tps[0], values[i], tps[i]))
+	}
+	if !found && hasNull {
+		return nil
+	}
+	return storage.EncodeBool(found != in.Not)
+}
+
+func (in InExpr) toField() storage.Field {
+	return storage.Field{Name: in.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (in InExpr) String() string {
+	values := make([]string, len(in.Values))
+	for i, value := range in.Values {
+		values[i] = value.String()
+	}
+	return fmt.Sprintf("%s %sin (%s)", in.Expr, notPrefix(in.Not), strings.Join(values, ", "))
+}
+
+func (in InExpr) TypeCheck() error {
+	return typeCheckOp(in.Expr, in.Values, storage.InOpType)
+}
+
+func (in InExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(in, input)
+}
+
+func (in InExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(in, row, input)
+}
+
+func (in InExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(in, groupByExpr)
+}
+
+func (in InExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(in, row, input)
+}
+
+func (in InExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(in)
+}
+
+func (in InExpr) Clone(cloneAccumulator bool) Expr {
+	return InExpr{
+		Expr:   in.Expr.Clone(cloneAccumulator),
+		Values: cloneExprs(in.Values, cloneAccumulator),
+		Not:    in.Not,
+		Name:   in.Name,
+	}
+}
+
+func (in InExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(in)
+}
+
+func (in InExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(in)
+}
+
+// BetweenExpr is like expr [not] between low and high, both low and high are included.
+type BetweenExpr struct {
+	Expr Expr
+	Low  Expr
+	High Expr
+	Not  bool
+	Name string
+}
+
+func (between BetweenExpr) params() []Expr {
+	return []Expr{between.Expr, between.Low, between.High}
+}
+
+func (between BetweenExpr) compute(values [][]byte, tps []storage.FieldTP) []byte {
+	// It's null like and if any of them is null.
+	ret := storage.And(storage.GreatEqual(values[0], tps[0], values[1], tps[1]),
+		storage.LessEqual(values[0], tps[0], values[2], tps[2]))
+	if between.Not {
+		return storage.Not(ret)
+	}
+	return ret
+}
+
+func (between BetweenExpr) toField() storage.Field {
+	return storage.Field{Name: between.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (between BetweenExpr) String() string {
+	return fmt.Sprintf("%s %sbetween %s and %s", between.Expr, notPrefix(between.Not), between.Low, between.High)
+}
+
+func (between BetweenExpr) TypeCheck() error {
+	return typeCheckOp(between.Expr, []Expr{between.Low, between.High}, storage.BetweenOpType)
+}
+
+func (between BetweenExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(between, input)
+}
+
+func (between BetweenExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(between, row, input)
+}
+
+func (between BetweenExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(between, groupByExpr)
+}
+
+func (between BetweenExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(between, row, input)
+}
+
+func (between BetweenExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(between)
+}
+
+func (between BetweenExpr) Clone(cloneAccumulator bool) Expr {
+	return BetweenExpr{
+		Expr: between.Expr.Clone(cloneAccumulator),
+		Low:  between.Low.Clone(cloneAccumulator),
+		High: between.High.Clone(cloneAccumulator),
+		Not:  between.Not,
+		Name: between.Name,
+	}
+}
+
+func (between BetweenExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(between)
+}
+
+func (between BetweenExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(between)
+}
+
+// defaultLikeEscape is the escape char of like if no escape is given.
+const defaultLikeEscape = `\`
+
+// LikeExpr is like expr [not] like pattern [escape escape_char], Escape is nil if not given.
+type LikeExpr struct {
+	Expr    Expr
+	Pattern Expr
+	Escape  Expr
+	Not     bool
+	Name    string
+}
+
+func (like LikeExpr) params() []Expr {
+	if like.Escape == nil {
+		return []Expr{like.Expr, like.Pattern}
+	}
+	return []Expr{like.Expr, like.Pattern, like.Escape}
+}
+
+func (like LikeExpr) compute(values [][]byte, _ []storage.FieldTP) []byte {
+	escape := []byte(defaultLikeEscape)
+	if len(values) > 2 {
+		escape = values[2]
+	}
+	ret := storage.DecodeBool(storage.Like(values[0], values[1], escape))
+	return storage.EncodeBool(ret != like.Not)
+}
+
+func (like LikeExpr) toField() storage.Field {
+	return storage.Field{Name: like.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (like LikeExpr) String() string {
+	ret := fmt.Sprintf("%s %slike %s", like.Expr, notPrefix(like.Not), like.Pattern)
+	if like.Escape != nil {
+		ret = fmt.Sprintf("%s escape %s", ret, like.Escape)
+	}
+	return ret
+}
+
+func (like LikeExpr) TypeCheck() error {
+	err := typeCheckOp(like.Expr, like.params()[1:], storage.LikeOpType)
+	if err != nil || like.Escape == nil {
+		return err
+	}
+	// The escape char is checked here if it's a constant.
+	escape, err := like.Escape.Compute()
+	if err == nil && len([]rune(string(escape))) > 1 {
+		return errors.New("escape must be one character")
+	}
+	return nil
+}
+
+func (like LikeExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return evaluateParamsExpr(like, input)
+}
+
+func (like LikeExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return evaluateRowParamsExpr(like, row, input)
+}
+
+func (like LikeExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return aggrTypeCheckParamsExpr(like, groupByExpr)
+}
+
+func (like LikeExpr) Accumulate(row int, input *storage.RecordBatch) {
+	accumulateParamsExpr(like, row, input)
+}
+
+func (like LikeExpr) AccumulateValue() []byte {
+	return accumulateValueParamsExpr(like)
+}
+
+func (like LikeExpr) Clone(cloneAccumulator bool) Expr {
+	ret := LikeExpr{
+		Expr:    like.Expr.Clone(cloneAccumulator),
+		Pattern: like.Pattern.Clone(cloneAccumulator),
+		Not:     like.Not,
+		Name:    like.Name,
+	}
+	if like.Escape != nil {
+		ret.Escape = like.Escape.Clone(cloneAccumulator)
+	}
+	return ret
+}
+
+func (like LikeExpr) HasGroupFunc() bool {
+	return hasGroupFuncParamsExpr(like)
+}
+
+func (like LikeExpr) Compute() ([]byte, error) {
+	return computeParamsExpr(like)
+}
@@ -0,0 +1,330 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+	"github.com/xiaobogaga/minidb/util"
+)
+
+// Param is the parameter of a ? placeholder in a prepared statement. Its type is inferred from
+// the expression it's compared or computed with when the statement is prepared, like id in
+// id = ?, or text if there is no such expression. The bound values are converted to the type.
+type Param struct {
+	TP       storage.FieldTP
+	inferred bool
+	Value    []byte // The bound value, nil for null.
+}
+
+// paramExpr returns the expr of the index-th ? placeholder, whose Param is nil if the statement
+// isn't prepared.
+func (scope *queryScope) paramExpr(index int) Expr {
+	ret := &ParamExpr{Index: index}
+	if index < len(scope.Params) {
+		ret.Param = scope.Params[index]
+	}
+	return ret
+}
+
+// inferParamType sets the type of expr to the type of another if expr is a parameter whose
+// type isn't inferred.
+func inferParamType(expr Expr, another Expr) {
+	if _, ok := another.(*ParamExpr); ok || !isUninferredParam(expr) || another.TypeCheck() != nil {
+		return
+	}
+	setParamType(expr, another.toField())
+}
+
+func isUninferredParam(expr Expr) bool {
+	param, ok := expr.(*ParamExpr)
+	return ok && param.Param != nil && !param.Param.inferred
+}
+
+// setParamType sets the type of expr to the type of field if expr is a parameter whose type
+// isn't inferred, like the parameters inserted or assigned to columns.
+func setParamType(expr Expr, field storage.Field) {
+	if !isUninferredParam(expr) {
+		return
+	}
+	param := expr.(*ParamExpr).Param
+	switch {
+	case field.IsNumerical() || field.IsBool():
+		param.TP = field.TP
+	case field.IsString():
+		// Like text, a value longer than a char column can be compared with it.
+		param.TP = storage.DefaultFieldTpMap[storage.Text]
+	default:
+		return
+	}
+	param.inferred = true
+}
+
+// ParamExpr is a ? placeholder, it's evaluated to the value bound to its parameter.
+type ParamExpr struct {
+	Index int
+	Param *Param
+}
+
+func (param *ParamExpr) toField() storage.Field {
+	f := storage.Field{Name: "?", AllowNull: true}
+	if param.Param != nil {
+		f.TP = param.Param.TP
+	}
+	return f
+}
+
+func (param *ParamExpr) String() string {
+	return "?"
+}
+
+func (param *ParamExpr) TypeCheck() error {
+	if param.Param == nil {
+		return errors.New("? can only be used in prepared statements")
+	}
+	return nil
+}
+
+func (param *ParamExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	return nil
+}
+
+func (param *ParamExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	ret := &storage.ColumnVector{Field: param.toField()}
+	for i := 0; i < input.RowCount(); i++ {
+		ret.Append(param.Param.Value)
+	}
+	return ret
+}
+
+func (param *ParamExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return param.Param.Value
+}
+
+func (param *ParamExpr) Accumulate(row int, input *storage.RecordBatch) {
+	return
+}
+
+func (param *ParamExpr) AccumulateValue() []byte {
+	return param.Param.Value
+}
+
+func (param *ParamExpr) Clone(cloneAccumulator bool) Expr {
+	return param
+}
+
+func (param *ParamExpr) HasGroupFunc() bool { return false }
+
+func (param *ParamExpr) Compute() ([]byte, error) {
+	err := param.TypeCheck()
+	if err != nil {
+		return nil, err
+	}
+	return param.Param.Value, nil
+}
+
+// PreparedStm is a statement prepared with ? placeholders. It's planned once when prepared, and
+// executed with the values bound to the placeholders every time.
+type PreparedStm struct {
+	Stm    parser.Stm
+	Params []*Param
+	// The plan of select, or the insert, update and delete plans.
+	plan interface{}
+	// The tables used by the plan when it's prepared.
+	tables []usedTable
+	// The subqueries of the plan, whose results are cleared before every execution.
+	subQueries []*subQuery
+}
+
+// usedTable is a table used by a prepared statement, and its schema version when the statement
+// is prepared.
+type usedTable struct {
+	SchemaName string
+	Name       string
+	table      *storage.TableInfo
+	version    int
+}
+
+// useTable records the table used by the statement being prepared.
+func (scope *queryScope) useTable(schemaName, tableName string) {
+	if scope.prepared == nil {
+		return
+	}
+	used := usedTable{SchemaName: schemaName, Name: tableName}
+	if dbInfo := storage.GetStorage().GetDbInfo(schemaName); dbInfo != nil {
+		used.table = dbInfo.GetTable(tableName)
+	}
+	if used.table != nil {
+		used.version = used.table.SchemaVersion
+	}
+	scope.prepared.tables = append(scope.prepared.tables, used)
+}
+
+// check returns an error if any table used by the statement is dropped or its columns are changed
+// after the statement is prepared, the statement must be prepared again then.
+func (prepared *PreparedStm) check() error {
+	for _, used := range prepared.tables {
+		var table *storage.TableInfo
+		if dbInfo := storage.GetStorage().GetDbInfo(used.SchemaName); dbInfo != nil {
+			table = dbInfo.GetTable(used.Name)
+		}
+		if table == nil || table != used.table || table.SchemaVersion != used.version {
+			return errors.New(fmt.Sprintf("table %s is changed after the statement is prepared, please prepare it again",
+				util.BuildDotString(used.SchemaName, used.Name)))
+		}
+	}
+	return nil
+}
+
+// mulPlan is the plan of insert, update and delete statements.
+type mulPlan interface {
+	TypeCheck() error
+	Execute(ctx context.Context) error
+}
+
+// Prepare plans stm having paramCount ? placeholders, only select, insert, update and delete
+// statements can be prepared. The statement cannot be executed if the tables it uses are dropped
+// or altered, see check.
+func Prepare(stm parser.Stm, paramCount int, currentDB string) (*PreparedStm, error) {
+	prepared := &PreparedStm{Stm: stm, Params: make([]*Param, paramCount)}
+	for i := range prepared.Params {
+		prepared.Params[i] = &Param{TP: storage.DefaultFieldTpMap[storage.Text]}
+	}
+	scope := &queryScope{CurrentDB: currentDB, Params: prepared.Params, prepared: prepared}
+	var mul mulPlan
+	switch stm := stm.(type) {
+	case *parser.SelectStm:
+		p, err := makeQueryPlan(stm, scope)
+		if err != nil {
+			return nil, err
+		}
+		prepared.plan = OptimizePlan(p)
+		return prepared, nil
+	case *parser.InsertIntoStm:
+		insert, err := makeInsertPlan(stm, scope)
+		if err != nil {
+			return nil, err
+		}
+		mul = insert
+	case *parser.UpdateStm:
+		mul = makeUpdatePlan(stm, scope)
+	case *parser.MultiUpdateStm:
+		mul = makeMultiUpdatePlan(stm, scope)
+	case *parser.SingleDeleteStm:
+		mul = makeDeletePlan(stm, scope)
+	case *parser.MultiDeleteStm:
+		mul = makeMultiDeletePlan(stm, scope)
+	default:
+		return nil, errors.New("only select, insert, update and delete statements can be prepared")
+	}
+	err := mul.TypeCheck()
+	if err != nil {
+		return nil, err
+	}
+	switch p := mul.(type) {
+	case Update:
+		p.Input = OptimizePlan(p.Input)
+		mul = p
+	case MultiUpdate:
+		p.Input = OptimizePlan(p.Input)
+		mul = p
+	case Delete:
+		p.Input = OptimizePlan(p.Input)
+		mul = p
+	case MultiDelete:
+		p.Input = OptimizePlan(p.Input)
+		mul = p
+	}
+	prepared.plan = mul
+	return prepared, nil
+}
+
+// Schema returns the schema of the rows returned by the statement, it's nil if the statement
+// isn't a select.
+func (prepared *PreparedStm) Schema() *storage.TableSchema {
+	p, ok := prepared.plan.(Plan)
+	if !ok {
+		return nil
+	}
+	return p.Schema()
+}
+
+// Bind binds values to the parameters, values[i] is of types[i] and is converted to the type of
+// the i-th parameter.
+func (prepared *PreparedStm) Bind(values [][]byte, types []storage.FieldTP) error {
+	if len(values) != len(prepared.Params) {
+		return errors.New(fmt.Sprintf("prepared statement needs %d parameters, but %d are given",
+			len(prepared.Params), len(values)))
+	}
+	for i, param := range prepared.Params {
+		value, err := storage.ConvertValue(values[i], types[i], storage.Field{TP: param.TP, AllowNull: true})
+		if err != nil {
+			return errors.New(fmt.Sprintf("cannot convert '%s' to %s for parameter %d",
+				storage.DecodeToString(values[i], types[i]), param.TP.Name, i+1))
+		}
+		param.Value = value
+	}
+	return nil
+}
+
+// BindExprs binds the values of execute ... using exprs to the parameters, the exprs must be
+// constants.
+func (prepared *PreparedStm) BindExprs(exprs []*parser.ExpressionStm) error {
+	values, types := make([][]byte, len(exprs)), make([]storage.FieldTP, len(exprs))
+	for i, stm := range exprs {
+		if !isConstantStm(stm) {
+			return errors.New(fmt.Sprintf("parameter %d must be a constant", i+1))
+		}
+		expr := ExprStmToExpr(stm, nil, &queryScope{})
+		err := expr.TypeCheck()
+		if err != nil {
+			return err
+		}
+		values[i], err = expr.Compute()
+		if err != nil {
+			return err
+		}
+		types[i] = expr.toField().TP
+	}
+	return prepared.Bind(values, types)
+}
+
+// isConstantStm returns true if stm is made of literals, like -1 or 1 + 2.
+func isConstantStm(stm interface{}) bool {
+	switch stm := stm.(type) {
+	case *parser.ExpressionStm:
+		return isConstantStm(stm.LeftExpr) && (stm.RightExpr == nil || isConstantStm(stm.RightExpr))
+	case *parser.ExpressionTerm:
+		switch stm.Tp {
+		case parser.LiteralExpressionTermTP:
+			return true
+		case parser.SubExpressionTermTP:
+			return isConstantStm(stm.RealExprTerm)
+		}
+	}
+	return false
+}
+
+// reset makes the plan ready to be executed again.
+func (prepared *PreparedStm) reset() {
+	for _, sub := range prepared.subQueries {
+		sub.values, sub.executed = nil, false
+	}
+	switch p := prepared.plan.(type) {
+	case Plan:
+		p.Reset()
+	case Insert:
+		if p.Select != nil {
+			p.Select.Reset()
+		}
+	case Update:
+		p.Input.Reset()
+	case MultiUpdate:
+		p.Input.Reset()
+	case Delete:
+		p.Input.Reset()
+	case MultiDelete:
+		p.Input.Reset()
+	}
+}
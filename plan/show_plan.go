@@ -26,6 +26,16 @@ func FillShowPlanData(stm *parser.ShowStm, currentDB string) (*storage.RecordBat
 	if stm.TP == parser.ShowCreateTableTP {
 		return FillShowCreateTableData(stm, currentDB)
 	}
+	if stm.TP == parser.ShowStatsTP {
+		return FillShowStatsData(stm, currentDB)
+	}
+	if stm.TP == parser.ShowCreateDatabaseTP {
+		dbInfo := storage.GetStorage().GetDbInfo(stm.Database)
+		if dbInfo == nil {
+			return nil, errors.New(fmt.Sprintf("cannot find such db: '%s'", stm.Database))
+		}
+		return dbInfo.Describe(), nil
+	}
 	name := "tables"
 	if stm.TP == parser.ShowDatabaseTP {
 		name = "databases"
@@ -78,3 +88,41 @@ func FillShowCreateTableData(stm *parser.ShowStm, currentDB string) (*storage.Re
 	}
 	return tbInfo.Describe(), nil
 }
+
+// FillShowStatsData returns the statistics of table, one row per column. If the table
+// is never analyzed, no rows are returned.
+func FillShowStatsData(stm *parser.ShowStm, currentDB string) (*storage.RecordBatch, error) {
+	dbName, tableName, err := getSchemaTableName(stm.Table, currentDB)
+	if err != nil {
+		return nil, err
+	}
+	if !storage.GetStorage().HasTable(dbName, tableName) {
+		return nil, errors.New(fmt.Sprintf("cannot find such table: '%s.%s'", dbName, tableName))
+	}
+	textTP, intTP := storage.DefaultFieldTpMap[storage.Text], storage.DefaultFieldTpMap[storage.Int]
+	ret := MakeEmptyRecordBatchFromSchema(&storage.TableSchema{Columns: []storage.Field{
+		storage.RowIndexField("", ""),
+		{TP: textTP, Name: "column"},
+		{TP: intTP, Name: "row_count"},
+		{TP: textTP, Name: "min"},
+		{TP: textTP, Name: "max"},
+		{TP: intTP, Name: "null_count"},
+		{TP: intTP, Name: "distinct_count"},
+		{TP: textTP, Name: "histogram"},
+	}})
+	stats := storage.GetStorage().GetDbInfo(dbName).GetTable(tableName).Stats
+	if stats == nil {
+		return ret, nil
+	}
+	for i, col := range stats.Columns {
+		ret.Records[0].Append(storage.EncodeInt(int64(i)))
+		ret.Records[1].Append([]byte(col.Field.Name))
+		ret.Records[2].Append(storage.EncodeInt(int64(stats.RowCount)))
+		ret.Records[3].Append([]byte(storage.DecodeToString(col.Min, col.Field.TP)))
+		ret.Records[4].Append([]byte(storage.DecodeToString(col.Max, col.Field.TP)))
+		ret.Records[5].Append(storage.EncodeInt(int64(col.NullCount)))
+		ret.Records[6].Append(storage.EncodeInt(int64(col.DistinctCount)))
+		ret.Records[7].Append([]byte(col.HistogramString()))
+	}
+	return ret, nil
+}
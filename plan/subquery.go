@@ -0,0 +1,755 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+)
+
+// queryScope is the context to plan a query block. A subquery is planned in a child scope whose
+// Outer is the input of the expression having the subquery, the columns not found in the subquery
+// are found in the outer queries.
+type queryScope struct {
+	CurrentDB string
+	Outer     Plan // nil for the top query block.
+	Parent    *queryScope
+	// Correlated is true if the subquery refers to the columns of outer queries.
+	Correlated bool
+	// The outer row the subquery is executed for.
+	batch *storage.RecordBatch
+	row   int
+	// The common table expressions visible to the query block, the inner ones first.
+	CTEs *cteDef
+	// The parameters of the ? placeholders if the statement is prepared.
+	Params []*Param
+	// The statement being prepared, which records the tables it uses.
+	prepared *PreparedStm
+}
+
+func (scope *queryScope) child(outer Plan) *queryScope {
+	return &queryScope{CurrentDB: scope.CurrentDB, Outer: outer, Parent: scope, CTEs: scope.CTEs, Params: scope.Params,
+		prepared: scope.prepared}
+}
+
+// findOuter returns the nearest scope whose Outer has column ident.
+func (scope *queryScope) findOuter(ident string) *queryScope {
+	schemaName, tableName, columnName := getSchemaTableColumnName(ident)
+	for s := scope; s != nil; s = s.Parent {
+		if s.Outer != nil && s.Outer.Schema().HasColumn(schemaName, tableName, columnName) {
+			return s
+		}
+	}
+	return nil
+}
+
+// outerRef returns the outer column ident refers to if it cannot be found in input, the subqueries
+// between are correlated. It returns nil if ident isn't an outer column.
+func (scope *queryScope) outerRef(ident parser.IdentifierExpression, input Plan) Expr {
+	if scope == nil || input == nil {
+		return nil
+	}
+	outer := scope.findOuter(string(ident))
+	if outer == nil {
+		return nil
+	}
+	schemaName, tableName, columnName := getSchemaTableColumnName(string(ident))
+	if input.Schema().HasColumn(schemaName, tableName, columnName) {
+		return nil
+	}
+	for s := scope; s != outer.Parent; s = s.Parent {
+		s.Correlated = true
+	}
+	return &OuterRefExpr{Ident: ident, scope: outer, Str: string(ident)}
+}
+
+// OuterRefExpr is a column of an outer query referred by a correlated subquery, its value is the
+// column of the outer row the subquery is executed for.
+type OuterRefExpr struct {
+	Ident []byte
+	scope *queryScope
+	Str   string
+}
+
+func (ref *OuterRefExpr) toField() storage.Field {
+	schemaName, tableName, columnName := getSchemaTableColumnName(string(ref.Ident))
+	return *ref.scope.Outer.Schema().GetField(schemaName, tableName, columnName)
+}
+
+func (ref *OuterRefExpr) String() string {
+	return string(ref.Ident)
+}
+
+func (ref *OuterRefExpr) TypeCheck() error {
+	schemaName, tableName, columnName := getSchemaTableColumnName(string(ref.Ident))
+	if ref.scope.Outer.Schema().HasAmbiguousColumn(schemaName, tableName, columnName) {
+		return errors.New(fmt.Sprintf("column '%s' is ambiguous", ref.Ident))
+	}
+	return nil
+}
+
+// AggrTypeCheck returns nil, an outer column is a constant for the subquery.
+func (ref *OuterRefExpr) AggrTypeCheck(_ []Expr) error {
+	return nil
+}
+
+func (ref *OuterRefExpr) value() []byte {
+	schemaName, tableName, columnName := getSchemaTableColumnName(string(ref.Ident))
+	return ref.scope.batch.GetColumnValue(schemaName, tableName, columnName).RawValue(ref.scope.row)
+}
+
+func (ref *OuterRefExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	ret := &storage.ColumnVector{Field: ref.toField()}
+	value := ref.value()
+	for i := 0; i < input.RowCount(); i++ {
+		ret.Append(value)
+	}
+	return ret
+}
+
+func (ref *OuterRefExpr) EvaluateRow(_ int, _ *storage.RecordBatch) []byte {
+	return ref.value()
+}
+
+func (ref *OuterRefExpr) Accumulate(_ int, _ *storage.RecordBatch) {}
+
+func (ref *OuterRefExpr) AccumulateValue() []byte {
+	return ref.value()
+}
+
+func (ref *OuterRefExpr) Clone(_ bool) Expr {
+	return &OuterRefExpr{Ident: ref.Ident, scope: ref.scope, Str: ref.Str}
+}
+
+func (ref *OuterRefExpr) HasGroupFunc() bool { return false }
+
+func (ref *OuterRefExpr) Compute() ([]byte, error) {
+	return ref.value(), nil
+}
+
+// subQuery is the plan of a subquery expression. An uncorrelated subquery is executed once,
+// a correlated one is executed for every outer row.
+type subQuery struct {
+	Plan     Plan
+	scope    *queryScope
+	err      error // The error of making plan, reported by type check.
+	values   [][]byte
+	executed bool
+}
+
+func makeSubQuery(stm parser.SubQueryStm, input Plan, scope *queryScope) *subQuery {
+	if scope == nil {
+		return &subQuery{err: errors.New("subquery is not supported here")}
+	}
+	childScope := scope.child(input)
+	plan, err := makeQueryPlan(stm, childScope)
+	if err != nil {
+		return &subQuery{err: err}
+	}
+	sub := &subQuery{Plan: OptimizePlan(plan), scope: childScope}
+	if scope.prepared != nil {
+		scope.prepared.subQueries = append(scope.prepared.subQueries, sub)
+	}
+	return sub
+}
+
+// typeCheck checks the subquery returns columns columns, 0 means any.
+func (sub *subQuery) typeCheck(columns int) error {
+	if sub.err != nil {
+		return sub.err
+	}
+	if columns > 0 && len(sub.dataColumns()) != columns {
+		return errors.New(fmt.Sprintf("operand should contain %d column", columns))
+	}
+	return nil
+}
+
+func (sub *subQuery) aggrTypeCheck(expr Expr) error {
+	if sub.err != nil {
+		return sub.err
+	}
+	if sub.scope.Correlated {
+		return errors.New(fmt.Sprintf("%s doesn't match group by clause", expr))
+	}
+	return nil
+}
+
+func (sub *subQuery) dataColumns() (ret []int) {
+	for i, column := range sub.Plan.Schema().Columns {
+		if column.Name != storage.DefaultRowKeyName {
+			ret = append(ret, i)
+		}
+	}
+	return
+}
+
+func (sub *subQuery) field() storage.Field {
+	field := sub.Plan.Schema().Columns[sub.dataColumns()[0]]
+	field.AllowNull = true
+	return field
+}
+
+// execute executes the subquery for the row of input, and returns the values of its first column.
+// At most limit values are returned if limit > 0.
+func (sub *subQuery) execute(row int, input *storage.RecordBatch, limit int) [][]byte {
+	if sub.executed && !sub.scope.Correlated {
+		return sub.values
+	}
+	sub.scope.batch, sub.scope.row = input, row
+	sub.Plan.Reset()
+	col := sub.dataColumns()[0]
+	var values [][]byte
+	for limit <= 0 || len(values) < limit {
+		batch := executePlan(context.Background(), sub.Plan)
+		if batch == nil {
+			break
+		}
+		for i := 0; i < batch.RowCount() && (limit <= 0 || len(values) < limit); i++ {
+			values = append(values, batch.Records[col].RawValue(i))
+		}
+	}
+	sub.values, sub.executed = values, true
+	return values
+}
+
+// computable returns an error if the subquery is correlated, there is no outer row to compute it.
+func (sub *subQuery) computable() error {
+	if sub.scope.Correlated {
+		return errors.New("unsupported action")
+	}
+	return nil
+}
+
+// errSubQueryRows is the error of a scalar subquery returning more than one row.
+var errSubQueryRows = errors.New("subquery returns more than 1 row")
+
+// SubQueryExpr is a scalar subquery like (select ...). It's null if the subquery returns no row,
+// and it's an error if the subquery returns more than one row.
+type SubQueryExpr struct {
+	*subQuery
+	Str string
+}
+
+func (expr *SubQueryExpr) toField() storage.Field {
+	field := expr.field()
+	field.Name, field.TableName, field.SchemaName, field.Alias = expr.Str, "", "", ""
+	return field
+}
+
+func (expr *SubQueryExpr) String() string {
+	return expr.Str
+}
+
+func (expr *SubQueryExpr) TypeCheck() error {
+	return expr.typeCheck(1)
+}
+
+func (expr *SubQueryExpr) AggrTypeCheck(_ []Expr) error {
+	return expr.aggrTypeCheck(expr)
+}
+
+func (expr *SubQueryExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	ret := &storage.ColumnVector{Field: expr.toField()}
+	for row := 0; row < input.RowCount(); row++ {
+		ret.Append(expr.EvaluateRow(row, input))
+	}
+	return ret
+}
+
+func (expr *SubQueryExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	value, err := expr.value(row, input)
+	if err != nil {
+		panic(queryError{err: err})
+	}
+	return value
+}
+
+// value returns the only value returned by the subquery for the row of input.
+func (expr *SubQueryExpr) value(row int, input *storage.RecordBatch) ([]byte, error) {
+	values := expr.execute(row, input, 2)
+	switch len(values) {
+	case 0:
+		return nil, nil
+	case 1:
+		return values[0], nil
+	default:
+		return nil, errSubQueryRows
+	}
+}
+
+// Accumulate does nothing, the subquery in aggregation cannot be correlated.
+func (expr *SubQueryExpr) Accumulate(_ int, _ *storage.RecordBatch) {}
+
+func (expr *SubQueryExpr) AccumulateValue() []byte {
+	return expr.EvaluateRow(0, nil)
+}
+
+func (expr *SubQueryExpr) Clone(_ bool) Expr {
+	return &SubQueryExpr{subQuery: expr.subQuery, Str: expr.Str}
+}
+
+func (expr *SubQueryExpr) HasGroupFunc() bool { return false }
+
+func (expr *SubQueryExpr) Compute() ([]byte, error) {
+	err := expr.computable()
+	if err != nil {
+		return nil, err
+	}
+	return expr.value(0, nil)
+}
+
+// ExistsExpr is [not] exists (select ...).
+type ExistsExpr struct {
+	*subQuery
+	Not bool
+}
+
+func (exists *ExistsExpr) toField() storage.Field {
+	return storage.Field{Name: exists.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (exists *ExistsExpr) String() string {
+	return fmt.Sprintf("%sexists (subquery)", notPrefix(exists.Not))
+}
+
+func (exists *ExistsExpr) TypeCheck() error {
+	return exists.typeCheck(0)
+}
+
+func (exists *ExistsExpr) AggrTypeCheck(_ []Expr) error {
+	return exists.aggrTypeCheck(exists)
+}
+
+func (exists *ExistsExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	ret := &storage.ColumnVector{Field: exists.toField()}
+	for row := 0; row < input.RowCount(); row++ {
+		ret.Append(exists.EvaluateRow(row, input))
+	}
+	return ret
+}
+
+func (exists *ExistsExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	values := exists.execute(row, input, 1)
+	return storage.EncodeBool((len(values) > 0) != exists.Not)
+}
+
+// Accumulate does nothing, the subquery in aggregation cannot be correlated.
+func (exists *ExistsExpr) Accumulate(_ int, _ *storage.RecordBatch) {}
+
+func (exists *ExistsExpr) AccumulateValue() []byte {
+	return exists.EvaluateRow(0, nil)
+}
+
+func (exists *ExistsExpr) Clone(_ bool) Expr {
+	return &ExistsExpr{subQuery: exists.subQuery, Not: exists.Not}
+}
+
+func (exists *ExistsExpr) HasGroupFunc() bool { return false }
+
+func (exists *ExistsExpr) Compute() ([]byte, error) {
+	err := exists.computable()
+	if err != nil {
+		return nil, err
+	}
+	return exists.EvaluateRow(0, nil), nil
+}
+
+// InSubQueryExpr is expr [not] in (select ...).
+type InSubQueryExpr struct {
+	Expr Expr
+	*subQuery
+	Not bool
+}
+
+func (in *InSubQueryExpr) toField() storage.Field {
+	return storage.Field{Name: in.String(), TP: storage.DefaultFieldTpMap[storage.Bool]}
+}
+
+func (in *InSubQueryExpr) String() string {
+	return fmt.Sprintf("%s %sin (subquery)", in.Expr, notPrefix(in.Not))
+}
+
+func (in *InSubQueryExpr) TypeCheck() error {
+	err := in.Expr.TypeCheck()
+	if err != nil {
+		return err
+	}
+	err = in.typeCheck(1)
+	if err != nil {
+		return err
+	}
+	field := in.Expr.toField()
+	return field.CanOp(in.field(), storage.InOpType)
+}
+
+func (in *InSubQueryExpr) AggrTypeCheck(groupByExpr []Expr) error {
+	err := in.Expr.AggrTypeCheck(groupByExpr)
+	if err != nil {
+		return err
+	}
+	return in.aggrTypeCheck(in)
+}
+
+// compute returns null like InExpr if the subquery returns rows, but value is null or no value
+// matches and some value is null.
+func (in *InSubQueryExpr) compute(value []byte, values [][]byte) []byte {
+	tp, valueTp := in.Expr.toField().TP, in.field().TP
+	if len(values) > 0 && storage.IsNullValue(value, tp) {
+		return nil
+	}
+	found, hasNull := false, false
+	for i := 0; i < len(values) && !found; i++ {
+		if storage.IsNullValue(values[i], valueTp) {
+			hasNull = true
+			continue
+		}
+		found = storage.DecodeBool(storage.Equal(value, tp, values[i], valueTp))
+	}
+	if !found && hasNull {
+		return nil
+	}
+	return storage.EncodeBool(found != in.Not)
+}
+
+func (in *InSubQueryExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	ret := &storage.ColumnVector{Field: in.toField()}
+	for row := 0; row < input.RowCount(); row++ {
+		ret.Append(in.EvaluateRow(row, input))
+	}
+	return ret
+}
+
+func (in *InSubQueryExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return in.compute(in.Expr.EvaluateRow(row, input), in.execute(row, input, 0))
+}
+
+func (in *InSubQueryExpr) Accumulate(row int, input *storage.RecordBatch) {
+	in.Expr.Accumulate(row, input)
+}
+
+func (in *InSubQueryExpr) AccumulateValue() []byte {
+	return in.compute(in.Expr.AccumulateValue(), in.execute(0, nil, 0))
+}
+
+func (in *InSubQueryExpr) Clone(cloneAccumulator bool) Expr {
+	return &InSubQueryExpr{Expr: in.Expr.Clone(cloneAccumulator), subQuery: in.subQuery, Not: in.Not}
+}
+
+func (in *InSubQueryExpr) HasGroupFunc() bool {
+	return in.Expr.HasGroupFunc()
+}
+
+func (in *InSubQueryExpr) Compute() ([]byte, error) {
+	err := in.computable()
+	if err != nil {
+		return nil, err
+	}
+	value, err := in.Expr.Compute()
+	if err != nil {
+		return nil, err
+	}
+	return in.compute(value, in.execute(0, nil, 0)), nil
+}
+
+// SemiJoinPlan returns the rows of LeftPlan having a matched row in RightPlan, or having no matched
+// row for anti join. It's a [not] exists or [not] in subquery decorrelated, so the subquery is
+// executed once instead of for every row.
+type SemiJoinPlan struct {
+	LeftPlan  Plan
+	RightPlan Plan
+	Expr      Expr // The join condition, every row matches if it's nil.
+	// In is expr = select_expr of a [not] in subquery, nil for exists. A not in is null if no
+	// row matches, but it's null for a row satisfying Expr.
+	In           Expr
+	Anti         bool
+	rightBatches []*storage.RecordBatch
+	rightDone    bool
+}
+
+func (semi *SemiJoinPlan) Schema() *storage.TableSchema {
+	return semi.LeftPlan.Schema()
+}
+
+func (semi *SemiJoinPlan) joinSchema() *storage.TableSchema {
+	mergedSchema, _ := semi.LeftPlan.Schema().Merge(semi.RightPlan.Schema())
+	return mergedSchema
+}
+
+func (semi *SemiJoinPlan) String() string {
+	return fmt.Sprintf("Join(%s, %s, %s)\n", semi.joinType(), semi.LeftPlan, semi.RightPlan)
+}
+
+func (semi *SemiJoinPlan) joinType() string {
+	if semi.Anti {
+		return "antiJoin"
+	}
+	return "semiJoin"
+}
+
+func (semi *SemiJoinPlan) Child() []Plan {
+	return []Plan{semi.LeftPlan, semi.RightPlan}
+}
+
+func (semi *SemiJoinPlan) TypeCheck() error {
+	err := semi.LeftPlan.TypeCheck()
+	if err != nil {
+		return err
+	}
+	err = semi.RightPlan.TypeCheck()
+	if err != nil {
+		return err
+	}
+	if semi.In != nil {
+		err = semi.In.TypeCheck()
+		if err != nil {
+			return err
+		}
+	}
+	if semi.Expr == nil {
+		return nil
+	}
+	return semi.Expr.TypeCheck()
+}
+
+func (semi *SemiJoinPlan) Execute(ctx context.Context) (ret *storage.RecordBatch) {
+	for !semi.rightDone {
+		batch := executePlan(ctx, semi.RightPlan)
+		if batch == nil {
+			semi.rightDone = true
+			break
+		}
+		if batch.RowCount() > 0 {
+			semi.rightBatches = append(semi.rightBatches, batch)
+		}
+	}
+	i := 0
+	for i < batchSize {
+		batch := executePlan(ctx, semi.LeftPlan)
+		if batch == nil {
+			return ret
+		}
+		if ret == nil {
+			ret = MakeEmptyRecordBatchFromSchema(semi.Schema())
+		}
+		selectedRecords := batch.Filter(semi.match(batch))
+		ret.Append(selectedRecords)
+		i += selectedRecords.RowCount()
+	}
+	return
+}
+
+// match returns whether the rows of left are selected. A row of left is unknown if In is null for
+// a row of right satisfying Expr, it isn't selected by not in either.
+func (semi *SemiJoinPlan) match(left *storage.RecordBatch) *storage.ColumnVector {
+	matched, unknown := make([]bool, left.RowCount()), make([]bool, left.RowCount())
+	for _, right := range semi.rightBatches {
+		if semi.Expr == nil && semi.In == nil {
+			for i := range matched {
+				matched[i] = true
+			}
+			break
+		}
+		joined := left.Join(right, semi.LeftPlan.Schema(), semi.joinSchema())
+		var selectedRows, equalRows *storage.ColumnVector
+		if semi.Expr != nil {
+			selectedRows = semi.Expr.Evaluate(joined)
+		}
+		if semi.In != nil {
+			equalRows = semi.In.Evaluate(joined)
+		}
+		for row := 0; row < joined.RowCount(); row++ {
+			i := row / right.RowCount()
+			switch {
+			case selectedRows != nil && !selectedRows.Bool(row):
+			case equalRows == nil || equalRows.Bool(row):
+				matched[i] = true
+			case len(equalRows.RawValue(row)) == 0:
+				unknown[i] = true
+			}
+		}
+	}
+	ret := &storage.ColumnVector{Field: storage.Field{TP: storage.DefaultFieldTpMap[storage.Bool]}}
+	for i, m := range matched {
+		if semi.Anti {
+			m = !m && !unknown[i]
+		}
+		ret.Append(storage.EncodeBool(m))
+	}
+	return ret
+}
+
+func (semi *SemiJoinPlan) Reset() {
+	semi.rightBatches = nil
+	semi.rightDone = false
+	semi.LeftPlan.Reset()
+	semi.RightPlan.Reset()
+}
+
+// A semiJoin is a predicate [not] exists (select ...) or expr [not] in (select ...) decorrelated,
+// its subquery is joined with the units instead of executed for every row.
+type semiJoin struct {
+	Right Plan
+	Cond  *parser.ExpressionStm // The predicates referring the units, nil if there is none.
+	In    *parser.ExpressionStm // expr = select_expr of in, nil for exists.
+	Anti  bool
+}
+
+func (semi *semiJoin) build(left Plan, scope *queryScope) Plan {
+	ret := &SemiJoinPlan{LeftPlan: left, RightPlan: semi.Right, Anti: semi.Anti}
+	joined := NewJoinPlan(left, semi.Right, parser.InnerJoin)
+	if semi.Cond != nil {
+		ret.Expr = ExprStmToExpr(semi.Cond, joined, scope)
+	}
+	if semi.In != nil {
+		ret.In = ExprStmToExpr(semi.In, joined, scope)
+	}
+	return ret
+}
+
+// extractSemiJoins removes the predicates which can be decorrelated from orderer.conds.
+func (orderer *joinOrderer) extractSemiJoins() ([]*semiJoin, error) {
+	var conds []*joinCond
+	var ret []*semiJoin
+	for _, cond := range orderer.conds {
+		semi, err := orderer.semiJoinOf(cond.Stm)
+		if err != nil {
+			return nil, err
+		}
+		if semi == nil {
+			conds = append(conds, cond)
+			continue
+		}
+		ret = append(ret, semi)
+	}
+	orderer.conds = conds
+	return ret, nil
+}
+
+// semiJoinOf returns the semi join of cond, or nil if cond cannot be decorrelated. The subquery must
+// select from tables without group by, having, limit, aggregation functions and subqueries, and every
+// column it refers must be found either in its tables or in the units, but not both.
+func (orderer *joinOrderer) semiJoinOf(cond *parser.ExpressionStm) (*semiJoin, error) {
+	stm, left, anti := subQueryPredicate(cond)
+	if stm == nil || !isSimpleSubQuery(stm) {
+		return nil, nil
+	}
+	scope := &queryScope{CurrentDB: orderer.scope.CurrentDB, Params: orderer.scope.Params, prepared: orderer.scope.prepared}
+	tables, err := makeScanPlans(stm.TableReferences, scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		// Let the subquery report the error.
+		if table.TypeCheck() != nil {
+			return nil, nil
+		}
+	}
+	inner, outer := makeJoinPlan(tables).Schema(), orderer.joinPlanOf(orderer.allUnits()).Schema()
+	var innerConds, joinConds []*parser.ExpressionStm
+	if stm.Where != nil {
+		for _, conjunct := range splitConjuncts(stm.Where) {
+			_, refOuter, ok := referredSides(conjunct, inner, outer)
+			if !ok {
+				return nil, nil
+			}
+			if refOuter {
+				joinConds = append(joinConds, conjunct)
+			} else {
+				innerConds = append(innerConds, conjunct)
+			}
+		}
+	}
+	var in *parser.ExpressionStm
+	if left != nil {
+		selectExpr := stm.SelectExpressions.Expr.([]*parser.SelectExpr)[0].Expr
+		refInner, _, ok := referredSides(left, inner, outer)
+		_, _, selectOk := referredSides(selectExpr, inner, outer)
+		if refInner || !ok || !selectOk {
+			return nil, nil
+		}
+		in = &parser.ExpressionStm{LeftExpr: left, Op: parser.OperationEqual, RightExpr: selectExpr}
+	}
+	right, err := makeOrderedJoinPlan(stm.TableReferences, andExprStm(innerConds), scope)
+	if err != nil {
+		return nil, err
+	}
+	return &semiJoin{Right: right, Cond: andExprStm(joinConds), In: in, Anti: anti}, nil
+}
+
+// subQueryPredicate returns the subquery of cond if it's [not] exists (select ...) or
+// expr [not] in (select ...), left is the expr of in.
+func subQueryPredicate(cond *parser.ExpressionStm) (stm *parser.SelectStm, left *parser.ExpressionStm, anti bool) {
+	if cond.Op == nil {
+		term, ok := cond.LeftExpr.(*parser.ExpressionTerm)
+		if cond.RightExpr != nil || !ok || term.Tp != parser.ExistsSubQueryExpressionTermTP || term.UnaryOp != parser.NoneUnaryOpTp {
+			return nil, nil, false
+		}
+		exists := term.RealExprTerm.(parser.ExistsSubQueryExpressionStm)
+		return exists.SubQuery, nil, !exists.Exists
+	}
+	term, ok := cond.RightExpr.(*parser.ExpressionTerm)
+	if (cond.Op.Tp != parser.IN && cond.Op.Tp != parser.NOTIN) || !ok || term.Tp != parser.SubQueryExpressionTermTP {
+		return nil, nil, false
+	}
+	stm = term.RealExprTerm.(parser.SubQueryStm)
+	selectExprs, ok := stm.SelectExpressions.Expr.([]*parser.SelectExpr)
+	if !ok || len(selectExprs) != 1 || hasSubQuery(cond.LeftExpr) {
+		return nil, nil, false
+	}
+	return stm, toExprStm(cond.LeftExpr), cond.Op.Tp == parser.NOTIN
+}
+
+func isSimpleSubQuery(stm *parser.SelectStm) bool {
+	if stm.Groupby != nil || stm.Having != nil || stm.LimitStm != nil || len(stm.SetOperations) > 0 {
+		return false
+	}
+	for _, tableRef := range stm.TableReferences {
+		if tableRef.Tp != parser.TableReferenceTableFactorTp ||
+			tableRef.TableReference.(parser.TableReferenceTableFactorStm).Tp == parser.TableReferenceSubTableReferenceStmTP {
+			return false
+		}
+	}
+	var exprs []*parser.ExpressionStm
+	if stm.Where != nil {
+		exprs = append(exprs, stm.Where)
+	}
+	if selectExprs, ok := stm.SelectExpressions.Expr.([]*parser.SelectExpr); ok {
+		for _, selectExpr := range selectExprs {
+			exprs = append(exprs, selectExpr.Expr)
+		}
+	}
+	for _, expr := range exprs {
+		if hasSubQuery(expr) || hasAggrFunc(expr) || hasWindowFunc(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+// referredSides returns whether expr refers the columns of inner and outer. ok is false if a
+// column is found in both or neither of them, or it's ambiguous.
+func referredSides(expr interface{}, inner, outer *storage.TableSchema) (refInner, refOuter, ok bool) {
+	for _, ident := range collectIdentifiers(expr) {
+		schemaName, tableName, columnName := getSchemaTableColumnName(ident)
+		inInner, inOuter := inner.HasColumn(schemaName, tableName, columnName), outer.HasColumn(schemaName, tableName, columnName)
+		if inInner == inOuter || inner.HasAmbiguousColumn(schemaName, tableName, columnName) ||
+			outer.HasAmbiguousColumn(schemaName, tableName, columnName) {
+			return false, false, false
+		}
+		refInner, refOuter = refInner || inInner, refOuter || inOuter
+	}
+	return refInner, refOuter, true
+}
+
+// andExprStm joins exprs by and, it returns nil if exprs is empty.
+func andExprStm(exprs []*parser.ExpressionStm) (ret *parser.ExpressionStm) {
+	for _, expr := range exprs {
+		if ret == nil {
+			ret = expr
+			continue
+		}
+		ret = &parser.ExpressionStm{LeftExpr: ret, Op: parser.OperationAnd, RightExpr: expr}
+	}
+	return
+}
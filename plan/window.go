@@ -0,0 +1,512 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+	"strings"
+)
+
+// WindowExpr is a window function like func(params) over ([partition by expr...] [order by ...] [frame]).
+// The window functions are computed by WindowPlan, which appends a column named by the expr for
+// each of them. The expr used in select expressions or order by only reads that column.
+type WindowExpr struct {
+	FuncName    string
+	Distinct    bool
+	Params      []Expr
+	PartitionBy []Expr
+	OrderBy     OrderByExpr
+	Frame       *parser.WindowFrameStm // nil for the default frame.
+	Accumulator []byte
+	input       Plan
+}
+
+func (window *WindowExpr) toField() storage.Field {
+	if field := window.input.Schema().GetField("", "", window.String()); field != nil {
+		return *field
+	}
+	return storage.Field{Name: window.String(), TP: window.returnType(), AllowNull: true}
+}
+
+func (window *WindowExpr) returnType() storage.FieldTP {
+	switch strings.ToUpper(window.FuncName) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK", "COUNT":
+		return storage.DefaultFieldTpMap[storage.Int]
+	case "LAG", "LEAD":
+		if len(window.Params) == 3 {
+			tp, _ := branchType([]storage.FieldTP{window.Params[0].toField().TP, window.Params[2].toField().TP})
+			return tp
+		}
+	}
+	if len(window.Params) == 0 {
+		return storage.FieldTP{}
+	}
+	return window.Params[0].toField().TP
+}
+
+func (window *WindowExpr) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteString(window.FuncName + "(")
+	if window.Distinct {
+		buf.WriteString("distinct ")
+	}
+	for i, param := range window.Params {
+		buf.WriteString(param.String())
+		if i != len(window.Params)-1 {
+			buf.WriteString(",")
+		}
+	}
+	buf.WriteString(") over (")
+	var spec []string
+	if len(window.PartitionBy) > 0 {
+		spec = append(spec, "partition by "+exprsToString(window.PartitionBy))
+	}
+	if len(window.OrderBy.Expr) > 0 {
+		orderBy := make([]string, len(window.OrderBy.Expr))
+		for i, expr := range window.OrderBy.Expr {
+			orderBy[i] = expr.String()
+			if !window.OrderBy.Asc[i] {
+				orderBy[i] += " desc"
+			}
+		}
+		spec = append(spec, "order by "+strings.Join(orderBy, ", "))
+	}
+	if window.Frame != nil {
+		spec = append(spec, frameString(window.Frame))
+	}
+	buf.WriteString(strings.Join(spec, " "))
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func frameString(frame *parser.WindowFrameStm) string {
+	tp := "rows"
+	if frame.Tp == parser.RangeFrameTp {
+		tp = "range"
+	}
+	return fmt.Sprintf("%s between %s and %s", tp, frameBoundString(frame.Start), frameBoundString(frame.End))
+}
+
+func frameBoundString(bound parser.FrameBoundStm) string {
+	switch bound.Tp {
+	case parser.UnboundedPrecedingTp:
+		return "unbounded preceding"
+	case parser.PrecedingTp:
+		return fmt.Sprintf("%d preceding", bound.Offset)
+	case parser.CurrentRowTp:
+		return "current row"
+	case parser.FollowingTp:
+		return fmt.Sprintf("%d following", bound.Offset)
+	default:
+		return "unbounded following"
+	}
+}
+
+// TypeCheck checks the window function is computed by the input, which is false if it's used
+// in where, group by or having.
+func (window *WindowExpr) TypeCheck() error {
+	if !window.input.Schema().HasColumn("", "", window.String()) {
+		return errors.New(fmt.Sprintf("window function %s is not allowed here", window))
+	}
+	return nil
+}
+
+// check type checks the window function computed by WindowPlan.
+func (window *WindowExpr) check() error {
+	if window.Distinct {
+		return errors.New(fmt.Sprintf("%s: distinct is not supported", window))
+	}
+	for _, expr := range append(append([]Expr{}, window.Params...), window.PartitionBy...) {
+		err := expr.TypeCheck()
+		if err != nil {
+			return err
+		}
+		if expr.HasGroupFunc() {
+			return errors.New("invalid use of group function")
+		}
+	}
+	err := window.OrderBy.TypeCheck()
+	if err != nil {
+		return err
+	}
+	for _, expr := range window.OrderBy.Expr {
+		if expr.HasGroupFunc() {
+			return errors.New("invalid use of group function")
+		}
+	}
+	if window.Frame != nil && window.Frame.Tp == parser.RangeFrameTp &&
+		(window.Frame.Start.Offset > 0 || window.Frame.End.Offset > 0) {
+		return errors.New(fmt.Sprintf("%s: range frame only supports unbounded and current row bounds", window))
+	}
+	switch strings.ToUpper(window.FuncName) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		if len(window.Params) != 0 {
+			return errors.New(fmt.Sprintf("%s: param size doesn't match", window))
+		}
+	case "FIRST_VALUE":
+		if len(window.Params) != 1 {
+			return errors.New(fmt.Sprintf("%s: param size doesn't match", window))
+		}
+	case "LAG", "LEAD":
+		if len(window.Params) == 0 || len(window.Params) > 3 {
+			return errors.New(fmt.Sprintf("%s: param size doesn't match", window))
+		}
+		if _, err := window.offset(); err != nil {
+			return err
+		}
+		if len(window.Params) == 3 {
+			_, err = branchType([]storage.FieldTP{window.Params[0].toField().TP, window.Params[2].toField().TP})
+			if err != nil {
+				return errors.New(fmt.Sprintf("%s: %s", window, err))
+			}
+		}
+	case "SUM", "COUNT", "MIN", "MAX":
+		return getFunc(window.FuncName, window.Params).TypeCheck()
+	default:
+		return errors.New(fmt.Sprintf("%s is not a window function", window.FuncName))
+	}
+	return nil
+}
+
+// offset returns the offset of lag or lead, which must be a non negative int constant.
+func (window *WindowExpr) offset() (int, error) {
+	if len(window.Params) < 2 {
+		return 1, nil
+	}
+	value, err := window.Params[1].Compute()
+	if err != nil || len(value) == 0 || !window.Params[1].toField().IsInteger() || storage.DecodeInt(value) < 0 {
+		return 0, errors.New(fmt.Sprintf("%s: offset must be a non negative int", window))
+	}
+	return int(storage.DecodeInt(value)), nil
+}
+
+func (window *WindowExpr) AggrTypeCheck(_ []Expr) error {
+	return errors.New(fmt.Sprintf("window function %s is not allowed here", window))
+}
+
+func (window *WindowExpr) Evaluate(input *storage.RecordBatch) *storage.ColumnVector {
+	return input.GetColumnValue("", "", window.String())
+}
+
+func (window *WindowExpr) EvaluateRow(row int, input *storage.RecordBatch) []byte {
+	return input.GetColumnValue("", "", window.String()).RawValue(row)
+}
+
+func (window *WindowExpr) Accumulate(row int, input *storage.RecordBatch) {
+	window.Accumulator = window.EvaluateRow(row, input)
+}
+
+func (window *WindowExpr) AccumulateValue() []byte {
+	return window.Accumulator
+}
+
+func (window *WindowExpr) Clone(cloneAccumulator bool) Expr {
+	ret := *window
+	if !cloneAccumulator {
+		ret.Accumulator = nil
+	}
+	return &ret
+}
+
+func (window *WindowExpr) HasGroupFunc() bool { return false }
+
+func (window *WindowExpr) Compute() ([]byte, error) {
+	return nil, errors.New("unsupported action")
+}
+
+// compute returns the values of the window function for all rows of data, data must have the
+// columns of the input of window.
+func (window *WindowExpr) compute(data *storage.RecordBatch) *storage.ColumnVector {
+	rows := data.RowCount()
+	ret := &storage.ColumnVector{Field: window.toField(), Values: make([][]byte, rows)}
+	sorted := window.sort(data)
+	partitionKeys := windowKeys(window.PartitionBy, data, sorted)
+	orderKeys := windowKeys(window.OrderBy.Expr, data, sorted)
+	for start := 0; start < rows; {
+		end := start + 1
+		for end < rows && partitionKeys[end] == partitionKeys[start] {
+			end++
+		}
+		window.computePartition(data, sorted, orderKeys, start, end, ret)
+		start = end
+	}
+	return ret
+}
+
+// sort returns the rows of data sorted by the partition by and order by exprs. Rows in the
+// same order are kept in their original order.
+func (window *WindowExpr) sort(data *storage.RecordBatch) []int {
+	rowIndex := &storage.ColumnVector{Field: storage.Field{TP: storage.DefaultFieldTpMap[storage.Int]}}
+	for i := 0; i < data.RowCount(); i++ {
+		rowIndex.Append(storage.EncodeInt(int64(i)))
+	}
+	var vectors []*storage.ColumnVector
+	var asc []bool
+	for _, expr := range window.PartitionBy {
+		vectors = append(vectors, expr.Evaluate(data))
+		asc = append(asc, true)
+	}
+	for i, expr := range window.OrderBy.Expr {
+		vectors = append(vectors, expr.Evaluate(data))
+		asc = append(asc, window.OrderBy.Asc[i])
+	}
+	order := rowIndex.Sort(append(vectors, rowIndex), append(asc, true))
+	ret := make([]int, order.Size())
+	for i := range ret {
+		ret[i] = int(order.Int(i))
+	}
+	return ret
+}
+
+// windowKeys returns the key of exprs for each sorted row.
+func windowKeys(exprs []Expr, data *storage.RecordBatch, sorted []int) []string {
+	vectors := make([]*storage.ColumnVector, len(exprs))
+	for i, expr := range exprs {
+		vectors[i] = expr.Evaluate(data)
+	}
+	ret := make([]string, len(sorted))
+	for i, row := range sorted {
+		var key []byte
+		for _, vector := range vectors {
+			value := vector.RawValue(row)
+			key = append(key, storage.EncodeInt(int64(len(value)))...)
+			key = append(key, value...)
+		}
+		ret[i] = string(key)
+	}
+	return ret
+}
+
+// computePartition computes the values of the sorted rows [start, end) in a partition.
+func (window *WindowExpr) computePartition(data *storage.RecordBatch, sorted []int, orderKeys []string,
+	start, end int, ret *storage.ColumnVector) {
+	name := strings.ToUpper(window.FuncName)
+	// The rows having the same order are peers, peers are [peerStart, peerEnd).
+	peerStart, peerEnd, rank := start, start, 0
+	// For aggregations whose frame starts from the partition start.
+	var f FuncInterface
+	accumulated := start
+	for i := start; i < end; i++ {
+		if i == peerEnd {
+			peerStart, peerEnd = i, i+1
+			for peerEnd < end && orderKeys[peerEnd] == orderKeys[i] {
+				peerEnd++
+			}
+			rank++
+		}
+		row := sorted[i]
+		switch name {
+		case "ROW_NUMBER":
+			ret.Values[row] = storage.EncodeInt(int64(i - start + 1))
+		case "RANK":
+			ret.Values[row] = storage.EncodeInt(int64(peerStart - start + 1))
+		case "DENSE_RANK":
+			ret.Values[row] = storage.EncodeInt(int64(rank))
+		case "LAG", "LEAD":
+			offset, _ := window.offset()
+			target := i - offset
+			if name == "LEAD" {
+				target = i + offset
+			}
+			ret.Values[row] = window.lagValue(data, sorted, row, target, start, end)
+		case "FIRST_VALUE":
+			frameStart, frameEnd := window.frame(i, start, end, peerStart, peerEnd)
+			if frameStart < frameEnd {
+				ret.Values[row] = window.Params[0].EvaluateRow(sorted[frameStart], data)
+			}
+		default:
+			frameStart, frameEnd := window.frame(i, start, end, peerStart, peerEnd)
+			if frameStart != start || f == nil {
+				f, accumulated = getFunc(window.FuncName, window.Params), frameStart
+			}
+			for ; accumulated < frameEnd; accumulated++ {
+				f.Accumulate(sorted[accumulated], data)
+			}
+			ret.Values[row] = f.AccumulateValue()
+			if len(ret.Values[row]) == 0 && name == "COUNT" {
+				ret.Values[row] = storage.EncodeInt(0)
+			}
+		}
+	}
+}
+
+// lagValue returns the value of lag or lead, the value is the default value if target is out of
+// the partition [start, end).
+func (window *WindowExpr) lagValue(data *storage.RecordBatch, sorted []int, row, target, start, end int) []byte {
+	tp := window.returnType()
+	if target >= start && target < end {
+		return convertBranch(window.Params[0].EvaluateRow(sorted[target], data), window.Params[0].toField().TP, tp)
+	}
+	if len(window.Params) < 3 {
+		return nil
+	}
+	return convertBranch(window.Params[2].EvaluateRow(row, data), window.Params[2].toField().TP, tp)
+}
+
+// frame returns the frame [frameStart, frameEnd) of the sorted row i in partition [start, end),
+// peers of row i are [peerStart, peerEnd). Without frame, the frame is from the partition start
+// to the last peer of the row if the window has order by, otherwise the whole partition.
+func (window *WindowExpr) frame(i, start, end, peerStart, peerEnd int) (frameStart, frameEnd int) {
+	frame := window.Frame
+	if frame == nil {
+		if len(window.OrderBy.Expr) == 0 {
+			return start, end
+		}
+		frame = &parser.WindowFrameStm{
+			Tp:    parser.RangeFrameTp,
+			Start: parser.FrameBoundStm{Tp: parser.UnboundedPrecedingTp},
+			End:   parser.FrameBoundStm{Tp: parser.CurrentRowTp},
+		}
+	}
+	position := func(bound parser.FrameBoundStm, isStart bool) int {
+		switch bound.Tp {
+		case parser.UnboundedPrecedingTp:
+			return start
+		case parser.PrecedingTp:
+			return i - bound.Offset
+		case parser.FollowingTp:
+			return i + bound.Offset
+		case parser.UnboundedFollowingTp:
+			return end - 1
+		}
+		// Current row of range frame includes the peers.
+		if frame.Tp == parser.RowsFrameTp {
+			return i
+		}
+		if isStart {
+			return peerStart
+		}
+		return peerEnd - 1
+	}
+	frameStart, frameEnd = position(frame.Start, true), position(frame.End, false)+1
+	if frameStart < start {
+		frameStart = start
+	}
+	if frameEnd > end {
+		frameEnd = end
+	}
+	if frameStart > frameEnd {
+		frameStart = frameEnd
+	}
+	return frameStart, frameEnd
+}
+
+// WindowPlan computes the window functions after where and having. It reads all rows of the
+// input and appends a column for each window function.
+type WindowPlan struct {
+	Input   Plan          `json:"window_input"`
+	Windows []*WindowExpr `json:"windows"`
+	data    *storage.RecordBatch
+	index   int
+}
+
+func (window *WindowPlan) Schema() *storage.TableSchema {
+	ret := &storage.TableSchema{}
+	ret.Columns = append(ret.Columns, window.Input.Schema().Columns...)
+	for _, expr := range window.Windows {
+		ret.AppendColumn(expr.toField())
+	}
+	return ret
+}
+
+func (window *WindowPlan) String() string {
+	return fmt.Sprintf("WindowPlan: %s", window.Input)
+}
+
+func (window *WindowPlan) Child() []Plan {
+	return []Plan{window.Input}
+}
+
+func (window *WindowPlan) TypeCheck() error {
+	err := window.Input.TypeCheck()
+	if err != nil {
+		return err
+	}
+	for _, expr := range window.Windows {
+		err = expr.check()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (window *WindowPlan) Execute(ctx context.Context) *storage.RecordBatch {
+	if window.data == nil {
+		window.initialize(ctx)
+	}
+	if window.data == nil {
+		return nil
+	}
+	ret := window.data.Slice(window.index, batchSize)
+	window.index += batchSize
+	return ret
+}
+
+func (window *WindowPlan) initialize(ctx context.Context) {
+	batch := executePlan(ctx, window.Input)
+	if batch == nil {
+		return
+	}
+	ret := MakeEmptyRecordBatchFromSchema(window.Schema())
+	for batch != nil {
+		ret.Append(batch)
+		batch = executePlan(ctx, window.Input)
+	}
+	columns := len(window.Input.Schema().Columns)
+	for i, expr := range window.Windows {
+		ret.SetColumnValue(columns+i, expr.compute(ret))
+	}
+	window.data = ret
+}
+
+func (window *WindowPlan) Reset() {
+	window.Input.Reset()
+	window.data = nil
+	window.index = 0
+}
+
+// makeWindowPlan makes a WindowPlan computing the window functions in the select expressions and
+// order by, it returns input if there is none.
+func makeWindowPlan(input Plan, ast *parser.SelectStm, scope *queryScope) Plan {
+	var exprs []*parser.ExpressionStm
+	if selectExprs, ok := ast.SelectExpressions.Expr.([]*parser.SelectExpr); ok {
+		for _, selectExpr := range selectExprs {
+			exprs = append(exprs, selectExpr.Expr)
+		}
+	}
+	if ast.OrderBy != nil {
+		for _, orderedExpr := range ast.OrderBy.Expressions {
+			exprs = append(exprs, orderedExpr.Expression)
+		}
+	}
+	ret := &WindowPlan{Input: input}
+	seen := map[string]bool{}
+	for _, expr := range exprs {
+		walkExprStm(expr, func(term *parser.ExpressionTerm) {
+			if term.Tp != parser.FuncCallExpressionTermTP || term.RealExprTerm.(parser.FunctionCallExpressionStm).Over == nil {
+				return
+			}
+			window := FuncCallExprToExpr(term.RealExprTerm.(parser.FunctionCallExpressionStm), input, scope).(*WindowExpr)
+			if !seen[window.String()] {
+				seen[window.String()] = true
+				ret.Windows = append(ret.Windows, window)
+			}
+		})
+	}
+	if len(ret.Windows) == 0 {
+		return input
+	}
+	return ret
+}
+
+// hasWindowFunc returns true if expr has a window function.
+func hasWindowFunc(expr interface{}) (ret bool) {
+	walkExprStm(expr, func(term *parser.ExpressionTerm) {
+		ret = ret || (term.Tp == parser.FuncCallExpressionTermTP && term.RealExprTerm.(parser.FunctionCallExpressionStm).Over != nil)
+	})
+	return
+}
@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"bytes"
+	"github.com/xiaobogaga/minidb/storage"
+)
+
+// A ZoneFilter is a predicate `column op value` pushed down to TableScan, the row groups whose
+// zone maps cannot satisfy it are skipped.
+type ZoneFilter struct {
+	Column int // The index of the column in table.
+	Op     storage.OpType
+	Value  []byte
+	TP     storage.FieldTP
+	Str    string // For explain.
+}
+
+// OptimizePlan rewrites the type checked plan p before execution.
+func OptimizePlan(p Plan) Plan {
+	p = SimplifyPlan(p)
+	pushDownZoneFilters(p)
+	return p
+}
+
+// pushDownZoneFilters pushes the `column op constant` predicates of a selection right above a
+// scan to the table scan. The selection is kept, since a row group might partially match.
+func pushDownZoneFilters(p Plan) {
+	if sel, ok := p.(*SelectionPlan); ok {
+		if scan, ok := sel.Input.(*ScanPlan); ok {
+			for _, expr := range splitAndExpr(sel.Expr) {
+				filter, ok := makeZoneFilter(scan.Input, expr)
+				if ok {
+					scan.Input.Filters = append(scan.Input.Filters, filter)
+				}
+			}
+		}
+	}
+	if having, ok := p.(*HavingPlan); ok {
+		pushDownZoneFilters(having.Input)
+		return
+	}
+	for _, child := range p.Child() {
+		pushDownZoneFilters(child)
+	}
+}
+
+func splitAndExpr(expr Expr) []Expr {
+	if and, ok := expr.(AndExpr); ok {
+		return append(splitAndExpr(and.Left), splitAndExpr(and.Right)...)
+	}
+	return []Expr{expr}
+}
+
+func makeZoneFilter(tableScan *TableScan, expr Expr) (ZoneFilter, bool) {
+	var op storage.OpType
+	var left, right Expr
+	switch e := expr.(type) {
+	case EqualExpr:
+		op, left, right = storage.EqualOpType, e.Left, e.Right
+	case NotEqualExpr:
+		op, left, right = storage.NotEqualOpType, e.Left, e.Right
+	case GreatExpr:
+		op, left, right = storage.GreatOpType, e.Left, e.Right
+	case GreatEqualExpr:
+		op, left, right = storage.GreatEqualOpType, e.Left, e.Right
+	case LessExpr:
+		op, left, right = storage.LessOpType, e.Left, e.Right
+	case LessEqualExpr:
+		op, left, right = storage.LessEqualOpType, e.Left, e.Right
+	default:
+		return ZoneFilter{}, false
+	}
+	ident, ok := left.(*IdentifierExpr)
+	if !ok {
+		return ZoneFilter{}, false
+	}
+	value, tp, ok := constantValue(right)
+	if !ok || tp.Name == storage.Null {
+		return ZoneFilter{}, false
+	}
+	table := storage.GetStorage().GetDbInfo(tableScan.SchemaName).GetTable(tableScan.Name)
+	column, _ := table.GetColumnInfo(ident.toField().Name)
+	if column <= 0 {
+		return ZoneFilter{}, false
+	}
+	return ZoneFilter{Column: column, Op: op, Value: value, TP: tp, Str: expr.String()}, true
+}
+
+// skipRowGroup returns true if no row of group can satisfy all filters.
+func (tableScan *TableScan) skipRowGroup(group *storage.RowGroup) bool {
+	for _, filter := range tableScan.Filters {
+		if !group.MayMatch(filter.Column, filter.Op, filter.Value, filter.TP) {
+			tableScan.SkippedGroups++
+			return true
+		}
+	}
+	return false
+}
+
+func zoneFiltersToString(filters []ZoneFilter) string {
+	buf := bytes.Buffer{}
+	for i, filter := range filters {
+		if i != 0 {
+			buf.WriteString(" and ")
+		}
+		buf.WriteString(filter.Str)
+	}
+	return buf.String()
+}
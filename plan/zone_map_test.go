@@ -0,0 +1,62 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/storage"
+	"testing"
+)
+
+func TestTableScanWithZoneMap(t *testing.T) {
+	storage.RowGroupSize = 4
+	defer func() { storage.RowGroupSize = 1024 }()
+	initTestStorage(t)
+	bound := testDataSize - 2
+	sql := fmt.Sprintf("select * from test1 where %d <= id and name != 'x' and id + 1 > 0;", bound)
+	p, err := MakeSelectPlan(toTestStm(t, sql).(*parser.SelectStm), "db1")
+	assert.Nil(t, err)
+	tableScan := p.Child()[0].Child()[0].Child()[0].(*TableScan)
+	assert.Equal(t, 2, len(tableScan.Filters))
+	assert.Equal(t, fmt.Sprintf("id >= %d", bound), tableScan.Filters[0].Str)
+	assert.Equal(t, "name != 'x'", tableScan.Filters[1].Str)
+	testSelect(t, sql, 2, false)
+
+	// Only the row groups might have id >= bound are scanned.
+	explain := &Explain{Analyze: true, Input: p}
+	stats, err := explain.collectExecStats(context.Background())
+	assert.Nil(t, err)
+	node := MakeExplainNode(p, stats)
+	scanNode := node.Children[0].Children[0].Children[0]
+	assert.Equal(t, "TableScan", scanNode.Operator)
+	skipped := bound / storage.RowGroupSize
+	assert.Equal(t, testDataSize-skipped*storage.RowGroupSize, scanNode.Runtime.Rows)
+	assert.Equal(t, skipped, tableScan.SkippedGroups)
+
+	// Zone maps are kept up to date by update and delete.
+	testUpdate := func(sql string) {
+		db := "db1"
+		exec, err := MakeExecutor(toTestStm(t, sql), &db)
+		assert.Nil(t, err)
+		_, err = exec.Exec()
+		assert.Nil(t, err)
+	}
+	testUpdate("update test1 set id = 1000 where id = 0;")
+	testSelect(t, "select * from test1 where id > 999;", 1, false)
+	testUpdate("delete from test1 where id = 1000;")
+	testSelect(t, "select * from test1 where id > 999;", 0, false)
+	testSelect(t, fmt.Sprintf("select * from test1 where id < %d;", bound), bound-1, false)
+
+	// Aggregations over a scan skipping all row groups still return one row.
+	countOf := func(sql string) []byte {
+		db := "db1"
+		exec, err := MakeExecutor(toTestStm(t, sql), &db)
+		assert.Nil(t, err)
+		ret, err := exec.Exec()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, ret.RowCount())
+		return ret.Records[1].RawValue(0)
+	}
+	assert.Equal(t, countOf("select count(id) from test1 where id + 0 > 999;"), countOf("select count(id) from test1 where id > 999;"))
+}
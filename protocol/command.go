@@ -27,6 +27,12 @@ func decodeCommand(packet []byte) (Command, ErrMsg) {
 		return Command{Tp: TpComQuit, Command: ComQuit("")}, OkMsg
 	case TpComPing:
 		return Command{Tp: TpComPing, Command: ComPing("")}, OkMsg
+	case TpComPrepare:
+		return Command{Tp: TpComPrepare, arg: packet[1:], Command: ComPrepare(packet[1:])}, OkMsg
+	case TpComExecute:
+		return Command{Tp: TpComExecute, arg: packet[1:], Command: ComExecute{}}, OkMsg
+	case TpComClose:
+		return Command{Tp: TpComClose, arg: packet[1:], Command: ComClose(0)}, OkMsg
 	default:
 		return Command{}, ErrMsg{errCode: ErrUnknownCommand}
 	}
@@ -51,6 +57,9 @@ const (
 	TpComQuery CommandType = iota
 	TpComQuit
 	TpComPing
+	TpComPrepare
+	TpComExecute
+	TpComClose
 )
 
 type ComQuit string
@@ -79,16 +88,26 @@ func (c ComPing) Encode() []byte {
 type ComQuery string
 
 func (c ComQuery) Do(conn ConnectionWrapperInterface, packet []byte) (bool, ErrMsg) {
-	// Parse a query and execute it.
+	// Parse a query of one or more statements and execute them.
 	query := string(packet)
 	commandLog.InfoF("ComQuery: try to do a query: %s", query)
 	parser := parser.NewParser()
-	stm, err := parser.Parse(packet)
+	stms, err := parser.ParseScript(packet)
 	if err != nil {
 		return false, makeErrMsg(ErrSyntax, err.Error())
 	}
-	msg := c.HandleOneStm(stm, conn)
-	return false, msg
+	// Statements are executed in order until one of them fails, the last message is returned.
+	for i, stm := range stms {
+		msg := c.HandleOneStm(stm, conn)
+		if !msg.IsOk() || i == len(stms)-1 {
+			return false, msg
+		}
+		msg = conn.SendMoreResults(msg)
+		if !msg.IsOk() {
+			return false, msg
+		}
+	}
+	return false, OkMsg
 }
 
 func isSelect(stm parser.Stm) bool {
@@ -96,15 +115,47 @@ func isSelect(stm parser.Stm) bool {
 	return ok
 }
 
+func queryErrMsg(err error) ErrMsg {
+	if err == plan.ErrQueryInterrupted || err == plan.ErrQueryTimeout {
+		return makeErrMsg(ErrQueryCanceled, err.Error())
+	}
+	return makeErrMsg(ErrQuery, err.Error())
+}
+
 func (c ComQuery) HandleOneStm(stm parser.Stm, conn ConnectionWrapperInterface) ErrMsg {
-	exec, err := plan.MakeExecutor(stm, conn.CurrentDB())
+	// Kill, set and prepare statements are about connections, so handle them here.
+	switch stm.(type) {
+	case *parser.KillStm:
+		err := killQuery(stm.(*parser.KillStm).ConnectionID)
+		if err != nil {
+			return makeErrMsg(ErrQuery, err.Error())
+		}
+		return OkMsg
+	case *parser.SetVariableStm:
+		setStm := stm.(*parser.SetVariableStm)
+		err := conn.Session().SetVariable(setStm.Name, setStm.Value)
+		if err != nil {
+			return makeErrMsg(ErrQuery, err.Error())
+		}
+		return OkMsg
+	case *parser.PrepareStm, *parser.ExecuteStm, *parser.DeallocatePrepareStm:
+		return handlePrepareStms(stm, conn)
+	}
+	ctx, cancel := conn.QueryContext()
+	defer cancel()
+	exec, err := plan.MakeExecutorWithContext(ctx, stm, conn.CurrentDB())
 	if err != nil {
 		return makeErrMsg(ErrQuery, err.Error())
 	}
+	return sendExecResults(conn, exec, stm)
+}
+
+// sendExecResults executes stm by exec and sends the results.
+func sendExecResults(conn ConnectionWrapperInterface, exec *plan.Executor, stm parser.Stm) ErrMsg {
 	for {
 		data, err := exec.Exec()
 		if err != nil {
-			return makeErrMsg(ErrQuery, err.Error())
+			return queryErrMsg(err)
 		}
 		if data != nil {
 			conn.SendQueryResult(data)
@@ -2,12 +2,14 @@ package protocol
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/xiaobogaga/minidb/parser"
 	"github.com/xiaobogaga/minidb/plan"
 	"github.com/xiaobogaga/minidb/storage"
 	"github.com/xiaobogaga/minidb/util"
+	"net"
 	"testing"
 	"time"
 )
@@ -97,11 +99,25 @@ func printTestRecordBatch(record *storage.RecordBatch) {
 }
 
 type connectionWrapperForTest struct {
-	db string
+	session        Session
+	moreResults    []ErrMsg
+	prepareResults []PrepareResult
+	rows           int
 }
 
 func (con *connectionWrapperForTest) CurrentDB() *string {
-	return &con.db
+	return &con.session.CurrentDB
+}
+
+func (con *connectionWrapperForTest) Session() *Session {
+	return &con.session
+}
+
+func (con *connectionWrapperForTest) QueryContext() (context.Context, context.CancelFunc) {
+	if con.session.MaxExecutionTime > 0 {
+		return context.WithTimeout(context.Background(), con.session.MaxExecutionTime)
+	}
+	return context.WithCancel(context.Background())
 }
 
 func (con *connectionWrapperForTest) SendErrMsg(msg ErrMsg) {
@@ -110,13 +126,186 @@ func (con *connectionWrapperForTest) SendErrMsg(msg ErrMsg) {
 
 func (con *connectionWrapperForTest) SendQueryResult(ret *storage.RecordBatch) ErrMsg {
 	printTestRecordBatch(ret)
+	con.rows += ret.RowCount()
+	return OkMsg
+}
+
+func (con *connectionWrapperForTest) SendMoreResults(msg ErrMsg) ErrMsg {
+	con.moreResults = append(con.moreResults, msg)
+	return OkMsg
+}
+
+func (con *connectionWrapperForTest) SendPrepareResult(id uint32, paramCount int, schema *storage.TableSchema) ErrMsg {
+	con.prepareResults = append(con.prepareResults, PrepareResult{ID: id, ParamCount: paramCount, Schema: schema})
 	return OkMsg
 }
 
 func TestComQuery_Do(t *testing.T) {
 	util.InitLogger("", 1024, time.Second, true)
-	con := &connectionWrapperForTest{db: "db1"}
+	con := &connectionWrapperForTest{session: Session{CurrentDB: "db1"}}
 	commandQuery := ComQuery("test")
 	sql := "select * from test1;"
 	commandQuery.Do(con, []byte(sql))
 }
+
+func TestComQuery_KillAndSet(t *testing.T) {
+	util.InitLogger("", 1024, time.Second, true)
+	initTestStorage(t)
+	con := &connectionWrapperForTest{session: Session{CurrentDB: "db1"}}
+	commandQuery := ComQuery("test")
+	_, msg := commandQuery.Do(con, []byte("set max_execution_time = 1000;"))
+	assert.True(t, msg.IsOk())
+	assert.Equal(t, time.Second, con.session.MaxExecutionTime)
+	_, msg = commandQuery.Do(con, []byte("set max_execution_time = 'abc';"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("set unknown_variable = 1;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("kill query 1000;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+
+	// Register a connection running a query and kill it.
+	wrap := NewConnectionWrapper(time.Second, time.Second, context.Background())
+	wrap.id = nextConnectionID()
+	registerConnection(wrap)
+	defer unregisterConnection(wrap.id)
+	ctx, cancel := wrap.QueryContext()
+	defer cancel()
+	_, msg = commandQuery.Do(con, []byte(fmt.Sprintf("kill query %d;", wrap.id)))
+	assert.True(t, msg.IsOk())
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// A query exceeding max_execution_time gets a cancellation error.
+	con.session.MaxExecutionTime = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	_, msg = commandQuery.Do(con, []byte("select * from test1, test2;"))
+	assert.Equal(t, ErrQueryCanceled, msg.errCode)
+	con.session.MaxExecutionTime = 0
+	_, msg = commandQuery.Do(con, []byte("select * from test1, test2;"))
+	assert.True(t, msg.IsOk())
+}
+
+func TestComQuery_Script(t *testing.T) {
+	util.InitLogger("", 1024, time.Second, true)
+	initTestStorage(t)
+	con := &connectionWrapperForTest{session: Session{CurrentDB: "db1"}}
+	commandQuery := ComQuery("test")
+	_, msg := commandQuery.Do(con, []byte("create table script (id int); insert into script values (1), (2);\nselect * from script;"))
+	assert.Equal(t, OkQueryMsg, msg)
+	assert.Equal(t, []ErrMsg{OkMsg, OkMsg}, con.moreResults)
+
+	// Statements after the failed one are not executed.
+	con.moreResults = nil
+	_, msg = commandQuery.Do(con, []byte("use db2; insert into script values (3); drop table test1;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	assert.Equal(t, []ErrMsg{OkMsg}, con.moreResults)
+	assert.Equal(t, "db2", con.session.CurrentDB)
+	assert.True(t, storage.GetStorage().GetDbInfo("db2").HasTable("test1"))
+
+	// Nothing is executed if the script has syntax errors.
+	con.moreResults = nil
+	_, msg = commandQuery.Do(con, []byte("use db1; drop table script; selec 1;"))
+	assert.Equal(t, ErrSyntax, msg.errCode)
+	assert.Empty(t, con.moreResults)
+	assert.True(t, storage.GetStorage().GetDbInfo("db1").HasTable("script"))
+}
+
+func TestComQuery_Prepare(t *testing.T) {
+	util.InitLogger("", 1024, time.Second, true)
+	initTestStorage(t)
+	con := &connectionWrapperForTest{session: Session{CurrentDB: "db1"}}
+	commandQuery := ComQuery("test")
+	_, msg := commandQuery.Do(con, []byte("prepare stm1 from 'select * from test1 where id >= ? and name != ?';"))
+	assert.True(t, msg.IsOk())
+	_, msg = commandQuery.Do(con, []byte("execute stm1 using 1, '2';"))
+	assert.Equal(t, OkQueryMsg, msg)
+	assert.Equal(t, 2, con.rows)
+	con.rows = 0
+	_, msg = commandQuery.Do(con, []byte("execute stm1 using 0 + 3, 2;"))
+	assert.Equal(t, OkQueryMsg, msg)
+	assert.Equal(t, 1, con.rows)
+	_, msg = commandQuery.Do(con, []byte("execute stm1 using 1;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("execute stm1 using id, 1;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("deallocate prepare stm1;"))
+	assert.True(t, msg.IsOk())
+	_, msg = commandQuery.Do(con, []byte("execute stm1 using 1, '2';"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("drop prepare stm1;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("prepare stm1 from 'selec ?';"))
+	assert.Equal(t, ErrSyntax, msg.errCode)
+	_, msg = commandQuery.Do(con, []byte("select * from test1 where id = ?;"))
+	assert.Equal(t, ErrQuery, msg.errCode)
+}
+
+func TestComPrepare(t *testing.T) {
+	util.InitLogger("", 1024, time.Second, true)
+	initTestStorage(t)
+	con := &connectionWrapperForTest{session: Session{CurrentDB: "db1"}}
+	_, msg := ComPrepare("").Do(con, []byte("select id, name from test1 where id > ? and age < ?;"))
+	assert.True(t, msg.IsOk())
+	assert.Equal(t, 1, len(con.prepareResults))
+	result := con.prepareResults[0]
+	assert.Equal(t, 2, result.ParamCount)
+	columns := result.Schema.Columns
+	assert.Equal(t, "name", columns[len(columns)-1].Name)
+	_, msg = ComPrepare("").Do(con, []byte("select * from test1 where id = ? and;"))
+	assert.Equal(t, ErrSyntax, msg.errCode)
+
+	// Parameters are converted to the inferred types.
+	execute := ComExecute{ID: result.ID, Params: []ExecuteParam{
+		{Tp: ParamInt, Value: storage.EncodeInt(0)},
+		{Tp: ParamText, Value: []byte("3")},
+	}}
+	packet := execute.Encode()
+	_, msg = ComExecute{}.Do(con, packet)
+	assert.Equal(t, OkQueryMsg, msg)
+	assert.Equal(t, 2, con.rows)
+	execute.Params[1] = ExecuteParam{Tp: ParamText, Value: []byte("abc")}
+	_, msg = ComExecute{}.Do(con, execute.Encode())
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = ComExecute{}.Do(con, packet[:len(packet)-1])
+	assert.Equal(t, ErrMsgFormat, msg.errCode)
+
+	// Closed statements cannot be executed.
+	_, msg = ComClose(0).Do(con, ComClose(result.ID).Encode())
+	assert.True(t, msg.IsOk())
+	_, msg = ComExecute{}.Do(con, packet)
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = ComClose(0).Do(con, ComClose(result.ID).Encode())
+	assert.Equal(t, ErrQuery, msg.errCode)
+
+	// Statements cannot be executed after the tables are altered or dropped.
+	_, msg = ComPrepare("").Do(con, []byte("select * from test1 where id > ?;"))
+	assert.True(t, msg.IsOk())
+	execute = ComExecute{ID: con.prepareResults[1].ID, Params: []ExecuteParam{{Tp: ParamInt, Value: storage.EncodeInt(0)}}}
+	_, msg = ComQuery("").Do(con, []byte("alter table test1 drop column age;"))
+	assert.True(t, msg.IsOk())
+	_, msg = ComExecute{}.Do(con, execute.Encode())
+	assert.Equal(t, ErrQuery, msg.errCode)
+	_, msg = ComQuery("").Do(con, []byte("drop table test1;"))
+	assert.True(t, msg.IsOk())
+	_, msg = ComExecute{}.Do(con, execute.Encode())
+	assert.Equal(t, ErrQuery, msg.errCode)
+}
+
+func TestSendPrepareResult(t *testing.T) {
+	util.InitLogger("", 1024, time.Second, true)
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	wrap := NewConnectionWrapper(time.Second, time.Second, context.Background())
+	wrap.conn = server
+	schema := &storage.TableSchema{Columns: []storage.Field{{Name: "id", TP: storage.DefaultFieldTpMap[storage.Int]}}}
+	go wrap.SendPrepareResult(3, 2, schema)
+	msg := ReadResp(client, 0, time.Second)
+	assert.Equal(t, MsgType(PrepareOkMsgType), msg.TP)
+	result := msg.Msg.(*PrepareResult)
+	assert.Equal(t, uint32(3), result.ID)
+	assert.Equal(t, 2, result.ParamCount)
+	assert.Equal(t, "id", result.Schema.Columns[0].Name)
+	go wrap.SendPrepareResult(4, 0, nil)
+	msg = ReadResp(client, 0, time.Second)
+	assert.Nil(t, msg.Msg.(*PrepareResult).Schema)
+}
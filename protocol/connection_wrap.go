@@ -5,18 +5,34 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/plan"
 	"github.com/xiaobogaga/minidb/storage"
 	"github.com/xiaobogaga/minidb/util"
 	"io"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type ConnectionWrapperInterface interface {
 	CurrentDB() *string
+	Session() *Session
+	// QueryContext returns a context for running one query on this connection, the query
+	// can be canceled by kill query or when exceeding max_execution_time.
+	QueryContext() (context.Context, context.CancelFunc)
 	SendErrMsg(msg ErrMsg)
 	SendQueryResult(ret *storage.RecordBatch) ErrMsg
+	// SendMoreResults sends the ok message of a statement followed by other statements in the
+	// same query.
+	SendMoreResults(msg ErrMsg) ErrMsg
+	// SendPrepareResult sends the id, the parameter count and the result schema of a prepared
+	// statement.
+	SendPrepareResult(id uint32, paramCount int, schema *storage.TableSchema) ErrMsg
 }
 
 var connectionWrapperLog = util.GetLog("ConnectionWrapper")
@@ -29,6 +45,46 @@ type connectionWrapper struct {
 	packetCounter byte
 	ctx           context.Context
 	session       Session
+	queryLock     sync.Mutex
+	queryCancel   context.CancelFunc
+}
+
+var (
+	connectionIDCounter uint32
+	connectionsLock     sync.Mutex
+	connections         = map[uint32]*connectionWrapper{}
+)
+
+func nextConnectionID() uint32 {
+	return atomic.AddUint32(&connectionIDCounter, 1)
+}
+
+func registerConnection(wrap *connectionWrapper) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	connections[wrap.id] = wrap
+}
+
+func unregisterConnection(id uint32) {
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	delete(connections, id)
+}
+
+// killQuery cancels the query running on connection id if any.
+func killQuery(id uint32) error {
+	connectionsLock.Lock()
+	wrap, ok := connections[id]
+	connectionsLock.Unlock()
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown connection id: %d", id))
+	}
+	wrap.queryLock.Lock()
+	defer wrap.queryLock.Unlock()
+	if wrap.queryCancel != nil {
+		wrap.queryCancel()
+	}
+	return nil
 }
 
 func NewConnectionWrapper(readTimeout, writeTimeout time.Duration, ctx context.Context) *connectionWrapper {
@@ -54,6 +110,7 @@ const (
 	ErrSendQueryResult
 	ErrMsgFormat
 	ErrPacketType
+	ErrQueryCanceled
 )
 
 func wrapNetErrToErrMsg(err error) ErrMsg {
@@ -129,6 +186,8 @@ const (
 	OkMsgType = iota
 	ErrMsgType
 	DataMsgType
+	MoreResultsMsgType
+	PrepareOkMsgType
 )
 
 // There are several different packet types, before list the packet type,
@@ -170,6 +229,41 @@ func (wrap *connectionWrapper) sendErr(err ErrMsg) ErrMsg {
 	return WritePacket(wrap.conn, wrap.packetCounter, buf, wrap.writeTimeout)
 }
 
+// Send More_Results_Packet, it's the same as OK_Packet, but the results of the next statement
+// in the query follow.
+// +-------------+------------+--------+
+// + packet type + msg len    +  msg   +
+// +-------------+------------+--------+
+// +      3      +
+// +-------------+
+func (wrap *connectionWrapper) SendMoreResults(okMsg ErrMsg) ErrMsg {
+	buf := bytes.Buffer{}
+	buf.WriteByte(MoreResultsMsgType)
+	message := okMsg.Msg
+	buf.Write(int4ToBytes(uint32(len(message))))
+	buf.Write([]byte(message))
+	connectionWrapperLog.InfoF("send more results packet.")
+	return WritePacket(wrap.conn, wrap.packetCounter, buf, wrap.writeTimeout)
+}
+
+// Send Prepare_OK_Packet, the schema is encoded as json, it's null if the statement isn't a select.
+// +-------------+--------------+-------------+------------+--------+
+// + packet type + statement id + param count + schema len + schema +
+// +-------------+--------------+-------------+------------+--------+
+// +      4      +
+// +-------------+
+func (wrap *connectionWrapper) SendPrepareResult(id uint32, paramCount int, schema *storage.TableSchema) ErrMsg {
+	bs, _ := json.Marshal(schema)
+	buf := bytes.Buffer{}
+	buf.WriteByte(PrepareOkMsgType)
+	buf.Write(int4ToBytes(id))
+	buf.Write(int4ToBytes(uint32(paramCount)))
+	buf.Write(int4ToBytes(uint32(len(bs))))
+	buf.Write(bs)
+	connectionWrapperLog.InfoF("send prepare ok packet.")
+	return WritePacket(wrap.conn, wrap.packetCounter, buf, wrap.writeTimeout)
+}
+
 var ErrCodeMsgMap = map[ErrCodeType]string{
 	ErrorOk:                  "Ok",
 	ErrorOkQuery:             "OK: no more data.",
@@ -178,17 +272,20 @@ var ErrCodeMsgMap = map[ErrCodeType]string{
 	ErrSyntax:                "parser: %s",
 	ErrQuery:                 "query: %s",
 	ErrSendQueryResult:       "server send query result failed: %s",
+	ErrQueryCanceled:         "query: %s",
 }
 
 func (wrap *connectionWrapper) setConnection(id uint32, conn net.Conn, fromUnixSocket bool) {
 	wrap.packetCounter = 0
 	wrap.id, wrap.conn = id, conn
 	wrap.session = Session{CurrentDB: "", sessionID: uint64(time.Now().Unix())}
+	registerConnection(wrap)
 }
 
 // Parsing sql commands until exit.
 func (wrap *connectionWrapper) parseCommand() {
 	defer wrap.conn.Close()
+	defer unregisterConnection(wrap.id)
 	// currentDataBase := ""
 	for {
 		select {
@@ -224,6 +321,26 @@ func (wrap *connectionWrapper) CurrentDB() *string {
 	return &wrap.session.CurrentDB
 }
 
+func (wrap *connectionWrapper) Session() *Session {
+	return &wrap.session
+}
+
+func (wrap *connectionWrapper) QueryContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(wrap.ctx)
+	if wrap.session.MaxExecutionTime > 0 {
+		ctx, cancel = context.WithTimeout(wrap.ctx, wrap.session.MaxExecutionTime)
+	}
+	wrap.queryLock.Lock()
+	wrap.queryCancel = cancel
+	wrap.queryLock.Unlock()
+	return ctx, func() {
+		wrap.queryLock.Lock()
+		wrap.queryCancel = nil
+		wrap.queryLock.Unlock()
+		cancel()
+	}
+}
+
 var emptyCommand = Command{}
 
 func (wrap *connectionWrapper) readCommand() (Command, ErrMsg) {
@@ -285,11 +402,16 @@ func ReadResp(conn net.Conn, packetCounter byte, readTimeout time.Duration) Msg
 	case OkMsgType:
 		msg := decodeOkMsg(packet)
 		return Msg{TP: OkMsgType, Msg: msg}
+	case MoreResultsMsgType:
+		msg := decodeOkMsg(packet)
+		return Msg{TP: MoreResultsMsgType, Msg: msg}
 	case ErrMsgType:
 		msg := decodeErrMsg(packet)
 		return Msg{TP: ErrMsgType, Msg: msg}
 	case DataMsgType:
 		return decodeQueryMessage(packet)
+	case PrepareOkMsgType:
+		return decodePrepareMessage(packet)
 	default:
 		return Msg{TP: ErrMsgType, Msg: ErrMsg{errCode: ErrPacketType, Msg: "unknown message"}}
 	}
@@ -298,9 +420,10 @@ func ReadResp(conn net.Conn, packetCounter byte, readTimeout time.Duration) Msg
 var emptyErrMsg = ErrMsg{}
 
 var (
-	okMsgMinLength   = 5
-	errMsgMinLength  = 6
-	dataMsgMinLength = 5
+	okMsgMinLength      = 5
+	errMsgMinLength     = 6
+	dataMsgMinLength    = 5
+	prepareMsgMinLength = 13
 )
 
 func decodeOkMsg(packet []byte) ErrMsg {
@@ -340,6 +463,16 @@ func decodeQueryMessage(packet []byte) Msg {
 	return Msg{TP: DataMsgType, Msg: ret}
 }
 
+func decodePrepareMessage(packet []byte) Msg {
+	if len(packet) <= prepareMsgMinLength {
+		return Msg{TP: ErrMsgType, Msg: ErrMsg{errCode: ErrMsgFormat, Msg: "wrong prepare ok message format"}}
+	}
+	ret := &PrepareResult{ID: BytesToInt4(packet[1:5]), ParamCount: int(BytesToInt4(packet[5:9]))}
+	messageLen := BytesToInt4(packet[9:13])
+	json.Unmarshal(packet[13:13+messageLen], &ret.Schema)
+	return Msg{TP: PrepareOkMsgType, Msg: ret}
+}
+
 type ErrMsg struct {
 	errCode ErrCodeType
 	Msg     string
@@ -356,6 +489,46 @@ func (msg ErrMsg) IsTimeout() bool {
 type Session struct {
 	sessionID uint64
 	CurrentDB string
+	// MaxExecutionTime limits the running time of a query, 0 means no limit.
+	MaxExecutionTime time.Duration
+	// The prepared statements by the prepare command and by prepare statements.
+	preparedStms  map[uint32]*plan.PreparedStm
+	namedStms     map[string]*plan.PreparedStm
+	lastPrepareID uint32
+}
+
+// addPreparedStm keeps prepared in session and returns its id.
+func (session *Session) addPreparedStm(prepared *plan.PreparedStm) uint32 {
+	if session.preparedStms == nil {
+		session.preparedStms = map[uint32]*plan.PreparedStm{}
+	}
+	session.lastPrepareID++
+	session.preparedStms[session.lastPrepareID] = prepared
+	return session.lastPrepareID
+}
+
+// setNamedStm keeps prepared in session by name, the statement having the same name is replaced.
+func (session *Session) setNamedStm(name string, prepared *plan.PreparedStm) {
+	if session.namedStms == nil {
+		session.namedStms = map[string]*plan.PreparedStm{}
+	}
+	session.namedStms[name] = prepared
+}
+
+// SetVariable sets a session variable by set statement.
+func (session *Session) SetVariable(name string, value []byte) error {
+	switch strings.ToLower(name) {
+	case "max_execution_time":
+		// In milliseconds as mysql does.
+		ms, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil || ms < 0 {
+			return errors.New(fmt.Sprintf("wrong value for max_execution_time: '%s'", value))
+		}
+		session.MaxExecutionTime = time.Duration(ms) * time.Millisecond
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("unknown session variable: '%s'", name))
+	}
 }
 
 type Msg struct {
@@ -374,7 +547,7 @@ func (msg Msg) IsFatal() bool {
 	}
 	errMsg := msg.Msg.(ErrMsg)
 	switch errMsg.errCode {
-	case ErrorOk, ErrorNetTimeout, ErrorNetPacketOutOfOrder, ErrMsgFormat, ErrPacketType, ErrSyntax, ErrQuery:
+	case ErrorOk, ErrorNetTimeout, ErrorNetPacketOutOfOrder, ErrMsgFormat, ErrPacketType, ErrSyntax, ErrQuery, ErrQueryCanceled:
 		return false
 	default:
 		return true
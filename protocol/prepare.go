@@ -0,0 +1,211 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/xiaobogaga/minidb/parser"
+	"github.com/xiaobogaga/minidb/plan"
+	"github.com/xiaobogaga/minidb/storage"
+)
+
+// ComPrepare prepares a statement with ? placeholders, the packet is the statement. The server
+// sends a Prepare_OK_Packet having the statement id, the parameter count and the result schema.
+type ComPrepare string
+
+func (c ComPrepare) Do(conn ConnectionWrapperInterface, packet []byte) (bool, ErrMsg) {
+	commandLog.InfoF("ComPrepare: try to prepare: %s", string(packet))
+	prepared, msg := prepareStm(packet, *conn.CurrentDB())
+	if !msg.IsOk() {
+		return false, msg
+	}
+	id := conn.Session().addPreparedStm(prepared)
+	return false, conn.SendPrepareResult(id, len(prepared.Params), prepared.Schema())
+}
+
+func (c ComPrepare) Encode() []byte {
+	return []byte(string(c))
+}
+
+// ParamType is the type of a parameter value sent by ComExecute.
+type ParamType byte
+
+const (
+	ParamNull  ParamType = iota
+	ParamInt             // Encoded by storage.EncodeInt.
+	ParamFloat           // Encoded by storage.EncodeFloat.
+	ParamBool            // Encoded by storage.EncodeBool.
+	ParamText
+)
+
+var paramTypeMap = map[ParamType]storage.FieldTPName{
+	ParamNull:  storage.Text,
+	ParamInt:   storage.Int,
+	ParamFloat: storage.Float,
+	ParamBool:  storage.Bool,
+	ParamText:  storage.Text,
+}
+
+type ExecuteParam struct {
+	Tp    ParamType
+	Value []byte
+}
+
+// ComExecute executes a prepared statement with the parameters, the results are sent like
+// ComQuery. The packet looks like:
+// +--------------+-------------+------------+-----------+-------+-----+
+// + statement id + param count + param type + value len + value + ... +
+// +--------------+-------------+------------+-----------+-------+-----+
+type ComExecute struct {
+	ID     uint32
+	Params []ExecuteParam
+}
+
+func (c ComExecute) Do(conn ConnectionWrapperInterface, packet []byte) (bool, ErrMsg) {
+	err := c.decode(packet)
+	if err != nil {
+		return false, ErrMsg{errCode: ErrMsgFormat, Msg: err.Error()}
+	}
+	commandLog.InfoF("ComExecute: try to execute statement %d", c.ID)
+	prepared, ok := conn.Session().preparedStms[c.ID]
+	if !ok {
+		return false, makeErrMsg(ErrQuery, fmt.Sprintf("unknown prepared statement: %d", c.ID))
+	}
+	values, types := make([][]byte, len(c.Params)), make([]storage.FieldTP, len(c.Params))
+	for i, param := range c.Params {
+		values[i], types[i] = param.Value, storage.DefaultFieldTpMap[paramTypeMap[param.Tp]]
+	}
+	err = prepared.Bind(values, types)
+	if err != nil {
+		return false, makeErrMsg(ErrQuery, err.Error())
+	}
+	return false, executePreparedStm(conn, prepared)
+}
+
+func (c *ComExecute) decode(packet []byte) error {
+	if len(packet) < 8 {
+		return errors.New("wrong execute command format")
+	}
+	c.ID = BytesToInt4(packet[:4])
+	count, pos := int(BytesToInt4(packet[4:8])), 8
+	c.Params = nil
+	for i := 0; i < count; i++ {
+		if pos+5 > len(packet) {
+			return errors.New("wrong execute command format")
+		}
+		tp, valueLen := ParamType(packet[pos]), int(BytesToInt4(packet[pos+1:pos+5]))
+		pos += 5
+		if _, ok := paramTypeMap[tp]; !ok || pos+valueLen > len(packet) {
+			return errors.New("wrong execute command format")
+		}
+		param := ExecuteParam{Tp: tp}
+		if tp != ParamNull {
+			param.Value = packet[pos : pos+valueLen]
+		}
+		c.Params = append(c.Params, param)
+		pos += valueLen
+	}
+	return nil
+}
+
+func (c ComExecute) Encode() []byte {
+	buf := bytes.Buffer{}
+	buf.Write(int4ToBytes(c.ID))
+	buf.Write(int4ToBytes(uint32(len(c.Params))))
+	for _, param := range c.Params {
+		buf.WriteByte(byte(param.Tp))
+		buf.Write(int4ToBytes(uint32(len(param.Value))))
+		buf.Write(param.Value)
+	}
+	return buf.Bytes()
+}
+
+// ComClose closes a prepared statement, the packet is the statement id.
+type ComClose uint32
+
+func (c ComClose) Do(conn ConnectionWrapperInterface, packet []byte) (bool, ErrMsg) {
+	if len(packet) < 4 {
+		return false, ErrMsg{errCode: ErrMsgFormat, Msg: "wrong close command format"}
+	}
+	id := BytesToInt4(packet[:4])
+	commandLog.InfoF("ComClose: close statement %d", id)
+	session := conn.Session()
+	if _, ok := session.preparedStms[id]; !ok {
+		return false, makeErrMsg(ErrQuery, fmt.Sprintf("unknown prepared statement: %d", id))
+	}
+	delete(session.preparedStms, id)
+	return false, OkMsg
+}
+
+func (c ComClose) Encode() []byte {
+	return int4ToBytes(uint32(c))
+}
+
+// PrepareResult is the Prepare_OK_Packet received by client.
+type PrepareResult struct {
+	ID         uint32
+	ParamCount int
+	Schema     *storage.TableSchema // nil if the statement isn't a select.
+}
+
+// prepareStm parses and plans query, which must be one statement.
+func prepareStm(query []byte, currentDB string) (*plan.PreparedStm, ErrMsg) {
+	parser := parser.NewParser()
+	stm, err := parser.Parse(query)
+	if err != nil {
+		return nil, makeErrMsg(ErrSyntax, err.Error())
+	}
+	prepared, err := plan.Prepare(stm, parser.ParamCount, currentDB)
+	if err != nil {
+		return nil, makeErrMsg(ErrQuery, err.Error())
+	}
+	return prepared, OkMsg
+}
+
+// executePreparedStm executes prepared whose parameters are bound, and sends the results.
+func executePreparedStm(conn ConnectionWrapperInterface, prepared *plan.PreparedStm) ErrMsg {
+	ctx, cancel := conn.QueryContext()
+	defer cancel()
+	exec, err := plan.MakePreparedExecutor(ctx, prepared, conn.CurrentDB())
+	if err != nil {
+		return makeErrMsg(ErrQuery, err.Error())
+	}
+	return sendExecResults(conn, exec, prepared.Stm)
+}
+
+// handlePrepareStms handles the prepare, execute and deallocate prepare statements, the named
+// prepared statements are kept in the session.
+func handlePrepareStms(stm parser.Stm, conn ConnectionWrapperInterface) ErrMsg {
+	session := conn.Session()
+	switch stm := stm.(type) {
+	case *parser.PrepareStm:
+		// The statement in quotes usually has no semicolon.
+		query := bytes.TrimSpace(stm.Query)
+		if !bytes.HasSuffix(query, []byte(";")) {
+			query = append(append([]byte{}, query...), ';')
+		}
+		prepared, msg := prepareStm(query, session.CurrentDB)
+		if !msg.IsOk() {
+			return msg
+		}
+		session.setNamedStm(stm.Name, prepared)
+		return OkMsg
+	case *parser.ExecuteStm:
+		prepared, ok := session.namedStms[stm.Name]
+		if !ok {
+			return makeErrMsg(ErrQuery, fmt.Sprintf("unknown prepared statement: '%s'", stm.Name))
+		}
+		err := prepared.BindExprs(stm.Params)
+		if err != nil {
+			return makeErrMsg(ErrQuery, err.Error())
+		}
+		return executePreparedStm(conn, prepared)
+	case *parser.DeallocatePrepareStm:
+		if _, ok := session.namedStms[stm.Name]; !ok {
+			return makeErrMsg(ErrQuery, fmt.Sprintf("unknown prepared statement: '%s'", stm.Name))
+		}
+		delete(session.namedStms, stm.Name)
+		return OkMsg
+	}
+	return makeErrMsg(ErrQuery, "unsupported statement")
+}
@@ -161,9 +161,10 @@ func newConnectionParser(readTimeout, writeTimeout time.Duration, reuseCh chan *
 
 func (parser *ConnectionParser) parseConnection(connection net.Conn, fromUnixSocket bool) {
 	parser.Count++
-	// Todo, check whether use parser.Count as id is ok.
-	// Now, don't bother to do this.
-	parser.connWrapper.setConnection(uint32(parser.Count), connection, fromUnixSocket)
+	// The id must be unique among all connections so that kill query can find it.
+	id := nextConnectionID()
+	connectionParserLog.InfoF("connection %s gets id: %d.", connection.RemoteAddr(), id)
+	parser.connWrapper.setConnection(id, connection, fromUnixSocket)
 	// Parsing command until exit.
 	parser.connWrapper.parseCommand()
 	parser.reuseCh <- parser
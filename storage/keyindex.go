@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// A keyIndex is the hash index of the primary key or an unique index of a table, it maps the
+// encoded key values to the rows having them. Rows having null values on the key aren't indexed.
+// A key can have several rows since update doesn't check duplicates.
+type keyIndex struct {
+	cols []int
+	rows map[string][]int
+}
+
+// keyIndexes returns the hash indexes of the unique keys of table, the stale indexes are rebuilt
+// first. They are kept valid by insert, update and delete, and dropped by schema changes.
+func (table *TableInfo) keyIndexes() []*keyIndex {
+	if table.keys != nil {
+		return table.keys
+	}
+	keys := table.uniqueKeys()
+	table.keys = make([]*keyIndex, len(keys))
+	for i, cols := range keys {
+		index := &keyIndex{cols: cols, rows: map[string][]int{}}
+		for row := 0; row < table.RowCount(); row++ {
+			table.addToKey(index, table.GetRow(row), row)
+		}
+		table.keys[i] = index
+	}
+	return table.keys
+}
+
+// addToKey adds row to the index if it has no null values on the key.
+func (table *TableInfo) addToKey(index *keyIndex, values [][]byte, row int) {
+	if key, ok := table.rowKey(values, index.cols); ok {
+		index.rows[key] = append(index.rows[key], row)
+	}
+}
+
+func (index *keyIndex) remove(key string, row int) {
+	rows := index.rows[key]
+	for i, r := range rows {
+		if r == row {
+			rows = append(rows[:i], rows[i+1:]...)
+			break
+		}
+	}
+	if len(rows) == 0 {
+		delete(index.rows, key)
+		return
+	}
+	index.rows[key] = rows
+}
+
+func (index *keyIndex) hasColumn(col int) bool {
+	for _, c := range index.cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateKeys drops the key indexes, they are rebuilt when needed.
+func (table *TableInfo) invalidateKeys() {
+	table.keys = nil
+}
+
+// insertToKeys adds row to the key indexes, the rows after it are moved backward by one first.
+func (table *TableInfo) insertToKeys(row int) {
+	for _, index := range table.keys {
+		if row < table.RowCount()-1 {
+			index.shift(row, 1)
+		}
+		table.addToKey(index, table.GetRow(row), row)
+	}
+}
+
+// updateKeys moves row to its new key in the indexes having col, which is updated from oldValue.
+func (table *TableInfo) updateKeys(col, row int, oldValue []byte) {
+	for _, index := range table.keys {
+		if !index.hasColumn(col) {
+			continue
+		}
+		newRow := table.GetRow(row)
+		oldRow := table.GetRow(row)
+		oldRow[col] = oldValue
+		if key, ok := table.rowKey(oldRow, index.cols); ok {
+			index.remove(key, row)
+		}
+		table.addToKey(index, newRow, row)
+	}
+}
+
+// deleteFromKeys removes row having values from the key indexes, the rows after it are moved
+// forward by one.
+func (table *TableInfo) deleteFromKeys(row int, values [][]byte) {
+	for _, index := range table.keys {
+		if key, ok := table.rowKey(values, index.cols); ok {
+			index.remove(key, row)
+		}
+		if row < table.RowCount() {
+			index.shift(row, -1)
+		}
+	}
+}
+
+// shift moves the rows starting at row by delta.
+func (index *keyIndex) shift(row, delta int) {
+	for _, rows := range index.rows {
+		for i, r := range rows {
+			if r >= row {
+				rows[i] = r + delta
+			}
+		}
+	}
+}
+
+// rowKey encodes the values of cols in row, returns false if any value is null.
+func (table *TableInfo) rowKey(row [][]byte, cols []int) (string, bool) {
+	buf := bytes.Buffer{}
+	for _, col := range cols {
+		value := row[col]
+		if IsNullValue(value, table.Datas[col].Field.TP) {
+			return "", false
+		}
+		buf.WriteString(strconv.Itoa(len(value)))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	return buf.String(), true
+}
+
+// uniqueKeys returns the columns of the primary key and the unique indexes of table.
+func (table *TableInfo) uniqueKeys() (ret [][]int) {
+	for i, col := range table.Datas {
+		if col.Field.PrimaryKey {
+			ret = append(ret, []int{i})
+		}
+	}
+	for _, index := range table.Indexes {
+		if !index.Unique {
+			continue
+		}
+		cols := make([]int, len(index.Columns))
+		for i, name := range index.Columns {
+			cols[i], _ = table.GetColumnInfo(name)
+		}
+		ret = append(ret, cols)
+	}
+	return
+}
+
+// hasDuplicateRows returns true if two rows have the same values on cols. Rows having null
+// values are ignored like mysql.
+func (table *TableInfo) hasDuplicateRows(cols []int) bool {
+	keys := map[string]bool{}
+	for row := 0; row < table.RowCount(); row++ {
+		key, ok := table.rowKey(table.GetRow(row), cols)
+		if !ok {
+			continue
+		}
+		if keys[key] {
+			return true
+		}
+		keys[key] = true
+	}
+	return false
+}
+
+// DuplicateRows returns the row indexes in ascending order which have the same primary key or
+// unique key with the row made by cols and values. The columns not in cols get their default
+// values.
+func (table *TableInfo) DuplicateRows(cols []string, values [][]byte) (ret []int) {
+	newRow := table.MakeRow(cols, values)
+	found := map[int]bool{}
+	for _, index := range table.keyIndexes() {
+		key, ok := table.rowKey(newRow, index.cols)
+		if !ok {
+			continue
+		}
+		for _, row := range index.rows[key] {
+			if !found[row] {
+				found[row] = true
+				ret = append(ret, row)
+			}
+		}
+	}
+	sort.Ints(ret)
+	return
+}
+
+// CheckDuplicate returns an error if any of rows has the same primary key or unique key with
+// the rows of table or another row of rows.
+func (table *TableInfo) CheckDuplicate(cols []string, rows [][][]byte) error {
+	for _, index := range table.keyIndexes() {
+		keys := map[string]bool{}
+		for _, values := range rows {
+			k, ok := table.rowKey(table.MakeRow(cols, values), index.cols)
+			if !ok {
+				continue
+			}
+			if keys[k] || len(index.rows[k]) > 0 {
+				return errors.New(fmt.Sprintf("duplicate entry for key '%s'", table.keyName(index.cols)))
+			}
+			keys[k] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTableInfo_DuplicateRows(t *testing.T) {
+	idField := Field{Name: "id", TP: DefaultFieldTpMap[Int], PrimaryKey: true}
+	nameField := Field{Name: "name", TP: DefaultFieldTpMap[Text], AllowNull: true}
+	scoreField := Field{Name: "score", TP: DefaultFieldTpMap[Int], AllowNull: true}
+	table := &TableInfo{
+		TableSchema: &TableSchema{Columns: []Field{RowIndexField("db", "t"), idField, nameField, scoreField}},
+		Datas:       []*ColumnVector{{Field: RowIndexField("db", "t")}, {Field: idField}, {Field: nameField}, {Field: scoreField}},
+	}
+	cols := []string{"id", "name", "score"}
+	row := func(id int64, name string, score []byte) [][]byte {
+		return [][]byte{EncodeInt(id), []byte(name), score}
+	}
+	// id: 0, 1, ..., 4, name: n0, n1, ..., score: every second score is null.
+	for i := int64(0); i < 5; i++ {
+		score := EncodeInt(i % 2)
+		if i%2 == 0 {
+			score = nil
+		}
+		table.InsertData(cols, row(i, "n"+string(rune('0'+i)), score))
+	}
+	assert.Nil(t, table.AddIndex(&Index{Name: "name", Columns: []string{"name"}, Unique: true}))
+	assert.Equal(t, []int{1}, table.DuplicateRows(cols, row(1, "x", nil)))
+	assert.Equal(t, []int{1, 3}, table.DuplicateRows(cols, row(1, "n3", nil)))
+	assert.Nil(t, table.DuplicateRows(cols, row(5, "x", nil)))
+	assert.NotNil(t, table.CheckDuplicate(cols, [][][]byte{row(5, "n4", nil)}))
+	assert.NotNil(t, table.CheckDuplicate(cols, [][][]byte{row(5, "x", nil), row(6, "x", nil)}))
+	// Empty strings are not null.
+	assert.Nil(t, table.CheckDuplicate(cols, [][][]byte{row(5, "", nil)}))
+	table.InsertData(cols, row(5, "", nil))
+	assert.Equal(t, []int{5}, table.DuplicateRows(cols, row(6, "", nil)))
+
+	// The indexes are kept valid by update and delete.
+	assert.Nil(t, table.UpdateData("name", 1, []byte("m1")))
+	assert.Nil(t, table.DuplicateRows(cols, row(6, "n1", nil)))
+	assert.Equal(t, []int{1}, table.DuplicateRows(cols, row(6, "m1", nil)))
+	table.DeleteRow(0)
+	assert.Equal(t, []int{0}, table.DuplicateRows(cols, row(6, "m1", nil)))
+	assert.Equal(t, []int{3}, table.DuplicateRows(cols, row(4, "x", nil)))
+	assert.Nil(t, table.DuplicateRows(cols, row(0, "n0", nil)))
+
+	// And rebuilt after the keys are changed, null values are never duplicated.
+	assert.Nil(t, table.AddIndex(&Index{Name: "score", Columns: []string{"id", "score"}, Unique: true}))
+	assert.True(t, table.DropIndex("name"))
+	assert.Nil(t, table.DuplicateRows(cols, row(6, "m1", nil)))
+	assert.True(t, table.DropPrimaryKey())
+	assert.Equal(t, []int{0}, table.DuplicateRows(cols, row(1, "x", EncodeInt(1))))
+	assert.Nil(t, table.DuplicateRows(cols, row(1, "x", nil)))
+	table.Truncate()
+	assert.Nil(t, table.DuplicateRows(cols, row(1, "x", EncodeInt(1))))
+}
@@ -3,13 +3,18 @@ package storage
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
 )
 
+// Add returns null if any of the values is null, like the other arithmetic operations.
 func Add(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	if tp1.Name == Int {
 		intVal1 := DecodeInt(val1)
 		switch tp2.Name {
@@ -48,6 +53,9 @@ func Add(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 }
 
 func Minus(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	if tp1.Name == Int {
 		intVal1 := DecodeInt(val1)
 		switch tp2.Name {
@@ -86,6 +94,9 @@ func Minus(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 }
 
 func Mul(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	if tp1.Name == Int {
 		intVal1 := DecodeInt(val1)
 		switch tp2.Name {
@@ -125,6 +136,9 @@ func Mul(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 }
 
 func Divide(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	if tp1.Name == Int {
 		intVal1 := DecodeInt(val1)
 		switch tp2.Name {
@@ -163,6 +177,9 @@ func Divide(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 }
 
 func Mod(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	if tp1.Name != Int || tp2.Name != Int {
 		panic("% cannot be applied to non-integer type")
 	}
@@ -174,6 +191,9 @@ func Mod(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 }
 
 func Negative(tp FieldTP, value []byte) []byte {
+	if IsNullValue(value, tp) {
+		return nil
+	}
 	switch tp.Name {
 	case Int:
 		val := DecodeInt(value)
@@ -186,38 +206,138 @@ func Negative(tp FieldTP, value []byte) []byte {
 	}
 }
 
+// IsNullValue returns true if value of tp is null. Empty strings are stored as empty bytes too,
+// so they aren't taken as null.
+func IsNullValue(value []byte, tp FieldTP) bool {
+	if len(value) > 0 {
+		return false
+	}
+	switch tp.Name {
+	case Text, Char, VarChar, MediumText, Blob, MediumBlob:
+		return false
+	default:
+		return true
+	}
+}
+
+// hasNullValue returns true if any of the values is null, the comparison and the arithmetic
+// operation are null then.
+func hasNullValue(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) bool {
+	return IsNullValue(val1, tp1) || IsNullValue(val2, tp2)
+}
+
 // tp1 And tp2 must be equable type. Return a byte encoded by a bool.
 func Equal(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	g := compare(val1, tp1, val2, tp2) == 0
 	return EncodeBool(g)
 }
 
 func NotEqual(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	b := Equal(val1, tp1, val2, tp2)
 	r := DecodeBool(b)
 	return EncodeBool(!r)
 }
 
+// Is compares the null-ness of values if any of them is null, where empty strings are taken as
+// null since they are stored the same. Otherwise it's the same as Equal.
 func Is(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
-	return Equal(val1, tp1, val2, tp2)
+	if tp1.Name == Null || tp2.Name == Null {
+		return EncodeBool(len(val1) == 0 && len(val2) == 0)
+	}
+	// Is is never null.
+	return EncodeBool(DecodeBool(Equal(val1, tp1, val2, tp2)))
+}
+
+func IsNot(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	return Not(Is(val1, tp1, val2, tp2))
+}
+
+// Not returns null if value is null.
+func Not(value []byte) []byte {
+	if len(value) == 0 {
+		return nil
+	}
+	return EncodeBool(!DecodeBool(value))
+}
+
+// Like returns true if value matches pattern, where % matches any characters and _ matches one
+// character. The character after escape is matched literally, escape can be empty.
+func Like(value, pattern, escape []byte) []byte {
+	return EncodeBool(likeMatch([]rune(string(value)), []rune(string(pattern)), []rune(string(escape))))
+}
+
+func likeMatch(value, pattern, escape []rune) bool {
+	for len(pattern) > 0 {
+		c := pattern[0]
+		pattern = pattern[1:]
+		switch {
+		case len(escape) > 0 && c == escape[0] && len(pattern) > 0:
+			if len(value) == 0 || value[0] != pattern[0] {
+				return false
+			}
+			value, pattern = value[1:], pattern[1:]
+		case c == '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(value); i++ {
+				if likeMatch(value[i:], pattern, escape) {
+					return true
+				}
+			}
+			return false
+		case c == '_':
+			if len(value) == 0 {
+				return false
+			}
+			value = value[1:]
+		default:
+			if len(value) == 0 || value[0] != c {
+				return false
+			}
+			value = value[1:]
+		}
+	}
+	return len(value) == 0
 }
 
 func Great(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	g := compare(val1, tp1, val2, tp2) > 0
 	return EncodeBool(g)
 }
 
 func GreatEqual(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	g := compare(val1, tp1, val2, tp2) >= 0
 	return EncodeBool(g)
 }
 
 func Less(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	g := compare(val1, tp1, val2, tp2) < 0
 	return EncodeBool(g)
 }
 
 func LessEqual(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
+	if hasNullValue(val1, tp1, val2, tp2) {
+		return nil
+	}
 	g := compare(val1, tp1, val2, tp2) <= 0
 	return EncodeBool(g)
 }
@@ -238,15 +358,20 @@ func Min(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) []byte {
 	return val2
 }
 
-// Return 0 if val1 == val2. <0 if val1 < val2 And 1 otherwise.
+// Return 0 if val1 == val2. <0 if val1 < val2 And 1 otherwise. Null is ordered before any value
+// for sorting, but comparisons having null are false.
 func compare(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) int {
 	switch tp1.Name {
 	case Text, Char, VarChar, MediumText, Blob, MediumBlob, Date, DateTime, Time:
 		// we can compare them by bytes.
 		return bytes.Compare(val1, val2)
-	case Bool:
+	case Bool, Null:
 		return bytes.Compare(val1, val2)
 	case Int, Float:
+		// null is ordered before any number, like the empty bytes of other types.
+		if len(val1) == 0 || len(val2) == 0 {
+			return len(val1) - len(val2)
+		}
 		v1, v2 := float64(0), float64(0)
 		if tp1.Name == Int {
 			v1 = float64(DecodeInt(val1))
@@ -275,14 +400,26 @@ func compare(val1 []byte, tp1 FieldTP, val2 []byte, tp2 FieldTP) int {
 	}
 }
 
+// And is false if any value is false, otherwise it's null if any value is null.
 func And(val1, val2 []byte) []byte {
-	v1 := DecodeBool(val1) && DecodeBool(val2)
-	return EncodeBool(v1)
+	if (len(val1) > 0 && !DecodeBool(val1)) || (len(val2) > 0 && !DecodeBool(val2)) {
+		return EncodeBool(false)
+	}
+	if len(val1) == 0 || len(val2) == 0 {
+		return nil
+	}
+	return EncodeBool(true)
 }
 
+// Or is true if any value is true, otherwise it's null if any value is null.
 func Or(val1, val2 []byte) []byte {
-	v1 := DecodeBool(val1) || DecodeBool(val2)
-	return EncodeBool(v1)
+	if DecodeBool(val1) || DecodeBool(val2) {
+		return EncodeBool(true)
+	}
+	if len(val1) == 0 || len(val2) == 0 {
+		return nil
+	}
+	return EncodeBool(false)
 }
 
 func EncodeInt(val int64) (ret []byte) {
@@ -312,8 +449,9 @@ func DecodeFloat(value []byte) float64 {
 	return math.Float64frombits(v)
 }
 
+// DecodeBool returns false for null, so rows whose conditions are null aren't selected.
 func DecodeBool(value []byte) bool {
-	return value[0] == 1
+	return len(value) > 0 && value[0] == 1
 }
 
 func DecodeInt(value []byte) int64 {
@@ -323,6 +461,8 @@ func DecodeInt(value []byte) int64 {
 func Encode(value []byte) []byte {
 	tp := InferenceType(value)
 	switch tp.Name {
+	case Null:
+		return nil
 	case Int:
 		val, _ := strconv.ParseInt(string(value), 10, 64)
 		return EncodeInt(val)
@@ -339,6 +479,74 @@ func Encode(value []byte) []byte {
 	}
 }
 
+// ConvertValue converts value of type tp to the type of field to, it fails if value cannot be
+// converted or cannot be assigned to field. Null values stay null. Empty strings stay empty
+// strings if they are assigned to strings, since they are stored like nulls, they are taken as
+// null otherwise.
+func ConvertValue(value []byte, tp FieldTP, to Field) ([]byte, error) {
+	if IsNullValue(value, tp) || IsNullValue(value, to.TP) {
+		if !to.AllowNull {
+			return nil, errors.New(fmt.Sprintf("column '%s' cannot be null", to.Name))
+		}
+		return nil, nil
+	}
+	ret, ok := convertValue(value, Field{TP: tp}, to)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("cannot convert '%s' to %s for column '%s'", DecodeToString(value, tp),
+			to.TP.Name, to.Name))
+	}
+	return ret, to.CanAssign(ret)
+}
+
+func convertValue(value []byte, from Field, to Field) ([]byte, bool) {
+	switch {
+	case to.IsInteger():
+		switch {
+		case from.IsInteger():
+			return value, true
+		case from.IsFloat():
+			return EncodeInt(int64(math.Round(DecodeFloat(value)))), true
+		case from.IsBool():
+			if DecodeBool(value) {
+				return EncodeInt(1), true
+			}
+			return EncodeInt(0), true
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+		return EncodeInt(v), err == nil
+	case to.IsFloat():
+		switch {
+		case from.IsInteger():
+			return EncodeFloat(float64(DecodeInt(value))), true
+		case from.IsFloat():
+			return value, true
+		case from.IsBool():
+			if DecodeBool(value) {
+				return EncodeFloat(1), true
+			}
+			return EncodeFloat(0), true
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(value)), 64)
+		return EncodeFloat(v), err == nil
+	case to.IsBool():
+		switch {
+		case from.IsInteger():
+			return EncodeBool(DecodeInt(value) != 0), true
+		case from.IsFloat():
+			return EncodeBool(DecodeFloat(value) != 0), true
+		case from.IsBool():
+			return value, true
+		}
+		v, err := strconv.ParseBool(strings.TrimSpace(string(value)))
+		return EncodeBool(v), err == nil
+	default:
+		if from.IsFloat() {
+			return []byte(strconv.FormatFloat(DecodeFloat(value), 'f', -1, 64)), true
+		}
+		return []byte(DecodeToString(value, from.TP)), true
+	}
+}
+
 //func fmtFloat(value float64, tp FieldTP) string {
 //	if value < 0 {
 //		return fmtNegativeFloat(value, tp)
@@ -372,7 +580,7 @@ func Encode(value []byte) []byte {
 //}
 
 func DecodeToString(value []byte, tp FieldTP) string {
-	if len(value) == 0 {
+	if IsNullValue(value, tp) {
 		return NULL
 	}
 	switch tp.Name {
@@ -102,6 +102,43 @@ func TestCompare(t *testing.T) {
 	assert.True(t, compare(EncodeInt(int64(1)), DefaultFieldTpMap[Int], EncodeFloat(float64(0)), DefaultFieldTpMap[Float]) > 0)
 }
 
+func TestCompareNull(t *testing.T) {
+	intTP, textTP := DefaultFieldTpMap[Int], DefaultFieldTpMap[Text]
+	// Comparisons having null are null, which is false when decoded.
+	for _, op := range []func([]byte, FieldTP, []byte, FieldTP) []byte{Equal, NotEqual, Great, GreatEqual, Less, LessEqual} {
+		assert.Nil(t, op(nil, intTP, EncodeInt(5), intTP))
+		assert.Nil(t, op(EncodeInt(5), intTP, nil, DefaultFieldTpMap[Float]))
+	}
+	assert.False(t, DecodeBool(nil))
+	// Not, and, or carry nulls.
+	assert.Nil(t, Not(nil))
+	assert.Nil(t, And(nil, EncodeBool(true)))
+	assert.False(t, DecodeBool(And(nil, EncodeBool(false))))
+	assert.Nil(t, Or(EncodeBool(false), nil))
+	assert.True(t, DecodeBool(Or(nil, EncodeBool(true))))
+	assert.False(t, DecodeBool(Is(nil, intTP, EncodeInt(5), intTP)))
+	assert.True(t, DecodeBool(IsNot(nil, intTP, EncodeInt(5), intTP)))
+	// Arithmetics having null are null.
+	for _, op := range []func([]byte, FieldTP, []byte, FieldTP) []byte{Add, Minus, Mul, Divide, Mod} {
+		assert.Nil(t, op(nil, intTP, EncodeInt(5), intTP))
+		assert.Nil(t, op(EncodeInt(5), intTP, nil, intTP))
+	}
+	assert.Nil(t, Negative(intTP, nil))
+	// Empty strings are not null.
+	assert.True(t, DecodeBool(Less(nil, textTP, []byte("a"), textTP)))
+	// Null is ordered before any value.
+	assert.True(t, compare(nil, intTP, EncodeInt(-5), intTP) < 0)
+	assert.Equal(t, int64(-5), DecodeInt(Max(nil, intTP, EncodeInt(-5), intTP)))
+	// Is compares the null-ness with null.
+	nullTP := InferenceType(nil)
+	assert.Equal(t, Null, nullTP.Name)
+	assert.True(t, DecodeBool(Is(nil, intTP, nil, nullTP)))
+	assert.False(t, DecodeBool(Is(EncodeInt(0), intTP, nil, nullTP)))
+	// Empty strings are stored like nulls.
+	assert.False(t, DecodeBool(IsNot([]byte(""), textTP, nil, nullTP)))
+	assert.True(t, DecodeBool(Is(EncodeBool(true), DefaultFieldTpMap[Bool], EncodeBool(true), DefaultFieldTpMap[Bool])))
+}
+
 func TestMax(t *testing.T) {
 	assert.Equal(t, int64(1), DecodeInt(Max(EncodeInt(int64(1)), DefaultFieldTpMap[Int], EncodeFloat(float64(1)), DefaultFieldTpMap[Float])))
 	assert.Equal(t, 2.12, DecodeFloat(Max(EncodeInt(int64(1)), DefaultFieldTpMap[Int], EncodeFloat(2.12), DefaultFieldTpMap[Float])))
@@ -110,3 +147,54 @@ func TestMax(t *testing.T) {
 func TestAnd(t *testing.T) {
 	assert.False(t, DecodeBool(And(EncodeBool(true), EncodeBool(false))))
 }
+
+func TestLike(t *testing.T) {
+	like := func(value, pattern, escape string) bool {
+		return DecodeBool(Like([]byte(value), []byte(pattern), []byte(escape)))
+	}
+	assert.True(t, like("hello", "h%", ""))
+	assert.True(t, like("hello", "%l%o", ""))
+	assert.True(t, like("hello", "h_llo", ""))
+	assert.True(t, like("", "%%", ""))
+	assert.False(t, like("hello", "h_lo", ""))
+	assert.False(t, like("hello", "hello_", ""))
+	assert.True(t, like("10%", "10!%", "!"))
+	assert.False(t, like("100", "10!%", "!"))
+	assert.True(t, like("a_b", `a\_b`, `\`))
+	assert.False(t, like("axb", `a\_b`, `\`))
+	assert.False(t, DecodeBool(Not(EncodeBool(true))))
+}
+
+func TestConvertValue(t *testing.T) {
+	intField := Field{Name: "c", TP: DefaultFieldTpMap[Int]}
+	ret, err := ConvertValue(EncodeFloat(2.6), DefaultFieldTpMap[Float], intField)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), DecodeInt(ret))
+	ret, err = ConvertValue([]byte(" 12 "), DefaultFieldTpMap[Text], intField)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), DecodeInt(ret))
+	_, err = ConvertValue([]byte("a"), DefaultFieldTpMap[Text], intField)
+	assert.NotNil(t, err)
+	_, err = ConvertValue(nil, DefaultFieldTpMap[Text], intField)
+	assert.NotNil(t, err)
+	intField.AllowNull = true
+	ret, err = ConvertValue(nil, DefaultFieldTpMap[Text], intField)
+	assert.Nil(t, err)
+	assert.Nil(t, ret)
+
+	// Empty strings stay empty strings for strings.
+	ret, err = ConvertValue([]byte{}, DefaultFieldTpMap[Text], Field{TP: FieldTP{Name: VarChar, Range: [2]int{3}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(ret))
+	_, err = ConvertValue(nil, DefaultFieldTpMap[Null], Field{Name: "c", TP: DefaultFieldTpMap[Text]})
+	assert.NotNil(t, err)
+
+	ret, err = ConvertValue(EncodeInt(0), DefaultFieldTpMap[Int], Field{TP: DefaultFieldTpMap[Bool]})
+	assert.Nil(t, err)
+	assert.False(t, DecodeBool(ret))
+	ret, err = ConvertValue(EncodeFloat(1.5), DefaultFieldTpMap[Float], Field{TP: FieldTP{Name: VarChar, Range: [2]int{3}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.5", string(ret))
+	_, err = ConvertValue(EncodeFloat(1.25), DefaultFieldTpMap[Float], Field{TP: FieldTP{Name: VarChar, Range: [2]int{3}}})
+	assert.NotNil(t, err)
+}
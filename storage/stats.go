@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultHistogramBuckets is the maximum bucket count of an equi-depth histogram.
+var DefaultHistogramBuckets = 16
+
+// Bucket is a bucket of equi-depth histogram covering the values in [LowerBound, UpperBound].
+// Values equal to each other never span two buckets, so buckets might be unbalanced.
+type Bucket struct {
+	LowerBound []byte
+	UpperBound []byte
+	Count      int // The number of rows in this bucket.
+	Distinct   int // The number of distinct values in this bucket.
+}
+
+// ColumnStats is the statistics of a column collected by analyze table.
+type ColumnStats struct {
+	Field         Field
+	Min           []byte
+	Max           []byte
+	NullCount     int
+	DistinctCount int
+	Histogram     []Bucket
+}
+
+// TableStats is the statistics of a table collected by analyze table.
+type TableStats struct {
+	RowCount   int
+	Columns    []*ColumnStats
+	AnalyzedAt time.Time
+}
+
+// Analyze collects the statistics of table and saves them to table.Stats.
+func (table *TableInfo) Analyze() *TableStats {
+	stats := &TableStats{RowCount: table.RowCount(), AnalyzedAt: time.Now()}
+	// Skip the row index column.
+	for i := 1; i < len(table.Datas); i++ {
+		stats.Columns = append(stats.Columns, analyzeColumn(table.Datas[i]))
+	}
+	table.Stats = stats
+	return stats
+}
+
+func analyzeColumn(col *ColumnVector) *ColumnStats {
+	ret := &ColumnStats{Field: col.Field}
+	values := make([][]byte, 0, col.Size())
+	for _, value := range col.Values {
+		if IsNullValue(value, col.Field.TP) {
+			ret.NullCount++
+			continue
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return ret
+	}
+	tp := col.GetTP()
+	sort.Slice(values, func(i, j int) bool {
+		return compare(values[i], tp, values[j], tp) < 0
+	})
+	ret.Min, ret.Max = values[0], values[len(values)-1]
+	// The depth of each bucket.
+	depth := (len(values) + DefaultHistogramBuckets - 1) / DefaultHistogramBuckets
+	var bucket *Bucket
+	for i, value := range values {
+		newValue := i == 0 || compare(values[i-1], tp, value, tp) != 0
+		if newValue {
+			ret.DistinctCount++
+		}
+		// Only start a new bucket at a new value, so same values stay in one bucket.
+		if bucket == nil || (newValue && bucket.Count >= depth) {
+			ret.Histogram = append(ret.Histogram, Bucket{LowerBound: value})
+			bucket = &ret.Histogram[len(ret.Histogram)-1]
+		}
+		if newValue {
+			bucket.Distinct++
+		}
+		bucket.Count++
+		bucket.UpperBound = value
+	}
+	return ret
+}
+
+// GetColumnStats returns the statistics of column name, or nil if not found.
+func (stats *TableStats) GetColumnStats(name string) *ColumnStats {
+	for _, col := range stats.Columns {
+		if col.Field.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// HistogramString returns the histogram like: [lower, upper]:count:distinct ...
+func (col *ColumnStats) HistogramString() string {
+	buf := bytes.Buffer{}
+	for i, bucket := range col.Histogram {
+		if i != 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(fmt.Sprintf("[%s, %s]:%d:%d", DecodeToString(bucket.LowerBound, col.Field.TP),
+			DecodeToString(bucket.UpperBound, col.Field.TP), bucket.Count, bucket.Distinct))
+	}
+	return buf.String()
+}
+
+func isNumerical(tp FieldTP) bool {
+	return tp.Name == Int || tp.Name == Float
+}
+
+// EqualSelectivity estimates the fraction of rows of the analyzed table whose column value equals
+// value. ok is false if value cannot be compared with this column.
+func (col *ColumnStats) EqualSelectivity(value []byte, tp FieldTP, rowCount int) (sel float64, ok bool) {
+	if rowCount == 0 || isNumerical(col.Field.TP) != isNumerical(tp) {
+		return 0, false
+	}
+	for _, bucket := range col.Histogram {
+		if compare(bucket.UpperBound, col.Field.TP, value, tp) < 0 {
+			continue
+		}
+		if compare(bucket.LowerBound, col.Field.TP, value, tp) > 0 {
+			break
+		}
+		// Assume values are uniformly distributed in a bucket.
+		return float64(bucket.Count) / float64(bucket.Distinct) / float64(rowCount), true
+	}
+	return 0, true
+}
+
+// LessSelectivity estimates the fraction of rows of the analyzed table whose column value is less
+// than value. ok is false if value cannot be compared with this column.
+func (col *ColumnStats) LessSelectivity(value []byte, tp FieldTP, rowCount int) (sel float64, ok bool) {
+	if rowCount == 0 || isNumerical(col.Field.TP) != isNumerical(tp) {
+		return 0, false
+	}
+	rows := 0.0
+	for _, bucket := range col.Histogram {
+		if compare(bucket.UpperBound, col.Field.TP, value, tp) < 0 {
+			rows += float64(bucket.Count)
+			continue
+		}
+		// value falls into this bucket, assume half of the bucket is less than it.
+		if compare(bucket.LowerBound, col.Field.TP, value, tp) < 0 {
+			rows += float64(bucket.Count) / 2
+		}
+		break
+	}
+	return rows / float64(rowCount), true
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTableInfo_Analyze(t *testing.T) {
+	idField := Field{Name: "id", TP: DefaultFieldTpMap[Int]}
+	nameField := Field{Name: "name", TP: DefaultFieldTpMap[Text]}
+	scoreField := Field{Name: "score", TP: DefaultFieldTpMap[Int], AllowNull: true}
+	table := &TableInfo{
+		TableSchema: &TableSchema{Columns: []Field{RowIndexField("db", "t"), idField, nameField, scoreField}},
+		Datas:       []*ColumnVector{{Field: RowIndexField("db", "t")}, {Field: idField}, {Field: nameField}, {Field: scoreField}},
+	}
+	DefaultHistogramBuckets = 4
+	// id: 0, 1, ..., 19, name: a, b, a, b ... and every fifth name is an empty string,
+	// score: i and every fifth score is null.
+	for i := 0; i < 20; i++ {
+		name, score := []byte(string(rune('a'+i%2))), EncodeInt(int64(i))
+		if i%5 == 0 {
+			name, score = []byte{}, nil
+		}
+		table.InsertData([]string{"id", "name", "score"}, [][]byte{EncodeInt(int64(19 - i)), name, score})
+	}
+	stats := table.Analyze()
+	assert.Equal(t, stats, table.Stats)
+	assert.Equal(t, 20, stats.RowCount)
+
+	id := stats.GetColumnStats("id")
+	assert.Equal(t, int64(0), DecodeInt(id.Min))
+	assert.Equal(t, int64(19), DecodeInt(id.Max))
+	assert.Equal(t, 0, id.NullCount)
+	assert.Equal(t, 20, id.DistinctCount)
+	assert.Equal(t, 4, len(id.Histogram))
+	for _, bucket := range id.Histogram {
+		assert.Equal(t, 5, bucket.Count)
+	}
+	assert.Equal(t, int64(5), DecodeInt(id.Histogram[1].LowerBound))
+	assert.Equal(t, int64(9), DecodeInt(id.Histogram[1].UpperBound))
+
+	// Empty strings aren't null.
+	name := stats.GetColumnStats("name")
+	assert.Equal(t, "", string(name.Min))
+	assert.Equal(t, "b", string(name.Max))
+	assert.Equal(t, 0, name.NullCount)
+	assert.Equal(t, 3, name.DistinctCount)
+	// Same values never span two buckets.
+	assert.Equal(t, 2, len(name.Histogram))
+	assert.Equal(t, 12, name.Histogram[0].Count)
+	assert.Equal(t, "[, a]:12:2 [b, b]:8:1", name.HistogramString())
+
+	score := stats.GetColumnStats("score")
+	assert.Equal(t, int64(1), DecodeInt(score.Min))
+	assert.Equal(t, int64(19), DecodeInt(score.Max))
+	assert.Equal(t, 4, score.NullCount)
+	assert.Equal(t, 16, score.DistinctCount)
+	assert.Nil(t, stats.GetColumnStats("age"))
+	DefaultHistogramBuckets = 16
+}
+
+func TestColumnStats_Selectivity(t *testing.T) {
+	idField := Field{Name: "id", TP: DefaultFieldTpMap[Int]}
+	table := &TableInfo{
+		TableSchema: &TableSchema{Columns: []Field{RowIndexField("db", "t"), idField}},
+		Datas:       []*ColumnVector{{Field: RowIndexField("db", "t")}, {Field: idField}},
+	}
+	DefaultHistogramBuckets = 4
+	for i := 0; i < 20; i++ {
+		table.InsertData([]string{"id"}, [][]byte{EncodeInt(int64(i))})
+	}
+	id := table.Analyze().GetColumnStats("id")
+	DefaultHistogramBuckets = 16
+
+	sel, ok := id.EqualSelectivity(EncodeInt(3), DefaultFieldTpMap[Int], 20)
+	assert.True(t, ok)
+	assert.Equal(t, 0.05, sel)
+	sel, ok = id.EqualSelectivity(EncodeInt(30), DefaultFieldTpMap[Int], 20)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, sel)
+	_, ok = id.EqualSelectivity([]byte("a"), DefaultFieldTpMap[Text], 20)
+	assert.False(t, ok)
+
+	// [0, 4] and half of [5, 9].
+	sel, ok = id.LessSelectivity(EncodeInt(7), DefaultFieldTpMap[Int], 20)
+	assert.True(t, ok)
+	assert.Equal(t, 0.375, sel)
+	sel, _ = id.LessSelectivity(EncodeInt(100), DefaultFieldTpMap[Int], 20)
+	assert.Equal(t, 1.0, sel)
+	sel, _ = id.LessSelectivity(EncodeInt(0), DefaultFieldTpMap[Int], 20)
+	assert.Equal(t, 0.0, sel)
+}
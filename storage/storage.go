@@ -71,12 +71,40 @@ func (dbs *DbInfo) RemoveTable(tableName string) {
 	delete(dbs.Tables, tableName)
 }
 
+func (dbs *DbInfo) Describe() *RecordBatch {
+	ret := &RecordBatch{
+		Fields: []Field{
+			{Name: "Properties", TP: FieldTP{Name: Text}},
+			{Name: "Values", TP: FieldTP{Name: Text}},
+		},
+		Records: make([]*ColumnVector, 2),
+	}
+	ret.Records[0] = &ColumnVector{Field: ret.Fields[0]}
+	ret.Records[1] = &ColumnVector{Field: ret.Fields[1]}
+	ret.Records[0].Append([]byte("database"))
+	ret.Records[1].Append([]byte(dbs.Name))
+	ret.Records[0].Append([]byte("charset"))
+	ret.Records[1].Append([]byte(dbs.Charset))
+	ret.Records[0].Append([]byte("collate"))
+	ret.Records[1].Append([]byte(dbs.Collate))
+	return ret
+}
+
 type TableInfo struct {
 	TableSchema *TableSchema
 	Charset     string
 	Collate     string
 	Engine      string
 	Datas       []*ColumnVector
+	Stats       *TableStats // Collected by analyze table, nil if never analyzed.
+	Indexes     []*Index
+	// SchemaVersion is increased when the columns are changed.
+	SchemaVersion int
+	// The zone maps of rows, see RowGroups.
+	rowGroups    []*RowGroup
+	rowGroupSize int
+	// The hash indexes of the unique keys, see keyIndexes.
+	keys []*keyIndex
 }
 
 func createRecordBatchFromColumns(columns []Field) *RecordBatch {
@@ -113,6 +141,14 @@ func (table *TableInfo) FillRowInfo(ret *RecordBatch, row int) {
 	}
 }
 
+// RowCount returns the number of rows stored in table.
+func (table *TableInfo) RowCount() int {
+	if len(table.Datas) <= 1 {
+		return 0
+	}
+	return table.Datas[1].Size()
+}
+
 // Return the column index in table and it's field.
 func (table *TableInfo) GetColumnInfo(column string) (int, *Field) {
 	for i, col := range table.Datas {
@@ -148,31 +184,76 @@ func (table *TableInfo) UpdateData(colName string, row int, value []byte) error
 	if err != nil {
 		return err
 	}
+	oldValue := table.Datas[index].Values[row]
+	table.updateRowGroup(index, row, oldValue, value)
 	table.Datas[index].Values[row] = value
+	table.updateKeys(index, row, oldValue)
 	return nil
 }
 
 func (table *TableInfo) DeleteRow(row int) {
+	values := table.GetRow(row)
 	for i := 1; i < len(table.Datas); i++ {
 		table.Datas[i].Values = append(table.Datas[i].Values[:row], table.Datas[i].Values[row+1:]...)
 	}
+	table.invalidateRowGroups(row)
+	table.deleteFromKeys(row, values)
+}
+
+// InsertRow inserts values made by GetRow at row index row, the rows after it are moved backward.
+func (table *TableInfo) InsertRow(row int, values [][]byte) {
+	for i := 1; i < len(table.Datas); i++ {
+		col := table.Datas[i]
+		col.Values = append(col.Values, nil)
+		copy(col.Values[row+1:], col.Values[row:])
+		col.Values[row] = values[i]
+	}
+	table.invalidateRowGroups(row)
+	table.insertToKeys(row)
 }
 
 func (table *TableInfo) Truncate() {
 	for i := 0; i < len(table.Datas); i++ {
 		table.Datas[i].Values = nil
 	}
+	table.invalidateRowGroups(0)
+	table.invalidateKeys()
 }
 
+// InsertData appends a row to table, the columns not in cols get their default values.
 func (table *TableInfo) InsertData(cols []string, values [][]byte) {
-	for i, col := range cols {
-		for _, tableCol := range table.Datas {
+	row := table.MakeRow(cols, values)
+	// Skip the row index column.
+	for i, tableCol := range table.Datas[1:] {
+		tableCol.Append(row[i+1])
+	}
+	table.appendToRowGroups()
+	table.insertToKeys(table.RowCount() - 1)
+}
+
+// MakeRow returns a row of all columns of table, the columns not in cols get their default
+// values. The row index column is nil.
+func (table *TableInfo) MakeRow(cols []string, values [][]byte) [][]byte {
+	row := make([][]byte, len(table.Datas))
+	for j, tableCol := range table.Datas[1:] {
+		row[j+1] = tableCol.Field.DefaultValue
+		for i, col := range cols {
 			if tableCol.Field.Name == col {
-				tableCol.Append(values[i])
+				row[j+1] = values[i]
 				break
 			}
 		}
 	}
+	return row
+}
+
+// GetRow returns the values of all columns at row index row. The row index column is nil.
+func (table *TableInfo) GetRow(row int) [][]byte {
+	ret := make([][]byte, len(table.Datas))
+	for i := 1; i < len(table.Datas); i++ {
+		ret[i] = table.Datas[i].Values[row]
+	}
+	return ret
 }
 
 func (table *TableInfo) Describe() *RecordBatch {
@@ -198,6 +279,16 @@ func (table *TableInfo) Describe() *RecordBatch {
 		ret.Records[1].Append([]byte(fmt.Sprintf("%s(%d, %d), [%v, %v, %v], %s", col.TP.Name, col.TP.Range[0], col.TP.Range[1],
 			col.PrimaryKey, col.AutoIncrement, col.AllowNull, DecodeToString(col.DefaultValue, col.TP))))
 	}
+	for _, index := range table.Indexes {
+		ret.Records[0].Append([]byte(fmt.Sprintf("index: %s", index.Name)))
+		ret.Records[1].Append([]byte(fmt.Sprintf("(%s), unique: %v", strings.Join(index.Columns, ", "), index.Unique)))
+	}
+	ret.Records[0].Append([]byte("engine"))
+	ret.Records[1].Append([]byte(table.Engine))
+	ret.Records[0].Append([]byte("charset"))
+	ret.Records[1].Append([]byte(table.Charset))
+	ret.Records[0].Append([]byte("collate"))
+	ret.Records[1].Append([]byte(table.Collate))
 	return ret
 }
 
@@ -217,6 +308,188 @@ func (table *TableInfo) RenameTo(newSchemaName string, newTableName string) erro
 	return nil
 }
 
+// AddColumn appends field to table, the existing rows of this column are filled with value.
+func (table *TableInfo) AddColumn(field Field, value []byte) {
+	col := &ColumnVector{Field: field}
+	for i := 0; i < table.RowCount(); i++ {
+		col.Append(value)
+	}
+	table.TableSchema.AppendColumn(field)
+	table.Datas = append(table.Datas, col)
+	table.schemaChanged()
+}
+
+// DropColumn removes column name from table and from the indexes, indexes having no columns
+// left are removed too.
+func (table *TableInfo) DropColumn(name string) {
+	index, _ := table.GetColumnInfo(name)
+	table.TableSchema.Columns = append(table.TableSchema.Columns[:index], table.TableSchema.Columns[index+1:]...)
+	table.Datas = append(table.Datas[:index], table.Datas[index+1:]...)
+	indexes := table.Indexes[:0]
+	for _, idx := range table.Indexes {
+		idx.removeColumn(name)
+		if len(idx.Columns) > 0 {
+			indexes = append(indexes, idx)
+		}
+	}
+	table.Indexes = indexes
+	table.schemaChanged()
+}
+
+// ReplaceColumn replaces column name with field, values are the new data of this column.
+func (table *TableInfo) ReplaceColumn(name string, field Field, values [][]byte) {
+	index, _ := table.GetColumnInfo(name)
+	table.TableSchema.Columns[index] = field
+	table.Datas[index] = &ColumnVector{Field: field, Values: values}
+	for _, idx := range table.Indexes {
+		idx.renameColumn(name, field.Name)
+	}
+	table.schemaChanged()
+}
+
+// AddPrimaryKey makes column name the primary key of table, the column cannot have null or
+// duplicate values.
+func (table *TableInfo) AddPrimaryKey(name string) error {
+	if table.PrimaryKey() != nil {
+		return errors.New("multi primary key defined")
+	}
+	index, _ := table.GetColumnInfo(name)
+	if index <= 0 {
+		return errors.New(fmt.Sprintf("cannot find column '%s'", name))
+	}
+	err := CheckPrimaryKey(name, table.Datas[index].Values)
+	if err != nil {
+		return err
+	}
+	table.TableSchema.Columns[index].PrimaryKey = true
+	table.Datas[index].Field.PrimaryKey = true
+	table.invalidateKeys()
+	return nil
+}
+
+// CheckPrimaryKey returns an error if values of column name have null or duplicate values.
+func CheckPrimaryKey(name string, values [][]byte) error {
+	keys := map[string]bool{}
+	for _, value := range values {
+		if len(value) == 0 {
+			return errors.New(fmt.Sprintf("primary key column '%s' cannot be null", name))
+		}
+		if keys[string(value)] {
+			return errors.New(fmt.Sprintf("duplicate entry for primary key '%s'", name))
+		}
+		keys[string(value)] = true
+	}
+	return nil
+}
+
+// DropPrimaryKey removes the primary key of table, returns false if table has no primary key.
+func (table *TableInfo) DropPrimaryKey() bool {
+	for i := range table.Datas {
+		if table.Datas[i].Field.PrimaryKey {
+			table.TableSchema.Columns[i].PrimaryKey = false
+			table.Datas[i].Field.PrimaryKey = false
+			table.invalidateKeys()
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryKey returns the primary key column of table, or nil if table has no primary key.
+func (table *TableInfo) PrimaryKey() *Field {
+	for i := range table.Datas {
+		if table.Datas[i].Field.PrimaryKey {
+			return &table.Datas[i].Field
+		}
+	}
+	return nil
+}
+
+// schemaChanged drops the zone maps, key indexes and statistics which might be stale after a
+// schema change.
+func (table *TableInfo) schemaChanged() {
+	table.invalidateRowGroups(0)
+	table.invalidateKeys()
+	table.Stats = nil
+	table.SchemaVersion++
+}
+
+// Index is an index of table. Only the definition is kept, the unique index is checked when
+// it's added.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+func (index *Index) removeColumn(name string) {
+	for i, col := range index.Columns {
+		if col == name {
+			index.Columns = append(index.Columns[:i], index.Columns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (index *Index) renameColumn(name, newName string) {
+	for i, col := range index.Columns {
+		if col == name {
+			index.Columns[i] = newName
+		}
+	}
+}
+
+// GetIndex returns the index name of table, or nil if not found.
+func (table *TableInfo) GetIndex(name string) *Index {
+	for _, index := range table.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+// AddIndex adds index to table. The columns of index must exist, and an unique index cannot
+// be added if the table has duplicate values on the columns.
+func (table *TableInfo) AddIndex(index *Index) error {
+	if table.GetIndex(index.Name) != nil {
+		return errors.New(fmt.Sprintf("duplicate index name '%s'", index.Name))
+	}
+	cols := make([]int, len(index.Columns))
+	for i, name := range index.Columns {
+		cols[i], _ = table.GetColumnInfo(name)
+		if cols[i] <= 0 {
+			return errors.New(fmt.Sprintf("cannot find column '%s'", name))
+		}
+	}
+	if index.Unique && table.hasDuplicateRows(cols) {
+		return errors.New(fmt.Sprintf("duplicate entry for unique index '%s'", index.Name))
+	}
+	table.Indexes = append(table.Indexes, index)
+	table.invalidateKeys()
+	return nil
+}
+
+// DropIndex removes index name from table, returns false if not found.
+func (table *TableInfo) DropIndex(name string) bool {
+	for i, index := range table.Indexes {
+		if index.Name == name {
+			table.Indexes = append(table.Indexes[:i], table.Indexes[i+1:]...)
+			table.invalidateKeys()
+			return true
+		}
+	}
+	return false
+}
+
+func (table *TableInfo) keyName(cols []int) string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = table.Datas[col].Field.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 // A table format looks like this.
 // | rowIndex | cols ... | DefaultPrimaryKey (if cols doesn't have primary key column |
 // the rowIndex column has no content by default. But when the fetch data is called.
@@ -334,6 +607,19 @@ func (recordBatch *RecordBatch) ColumnCount() int {
 	return len(recordBatch.Records)
 }
 
+// MemorySize returns the bytes used by the values of recordBatch.
+func (recordBatch *RecordBatch) MemorySize() (size int) {
+	if recordBatch == nil {
+		return 0
+	}
+	for _, col := range recordBatch.Records {
+		for _, value := range col.Values {
+			size += len(value)
+		}
+	}
+	return size
+}
+
 type JoinType byte
 
 const (
@@ -566,6 +852,10 @@ func (f Field) IsFloat() bool {
 	return f.TP.Name == Float
 }
 
+func (f Field) IsNull() bool {
+	return f.TP.Name == Null
+}
+
 func (f Field) IsMultiple() bool {
 	return f.TP.Name == Multiple
 }
@@ -587,12 +877,26 @@ func (f Field) CanOp(another Field, opType OpType) (err error) {
 			return nil
 		}
 		return errors.New(fmt.Sprintf("%s cannot apply to non integer type", opType))
+	case NotOpType:
+		if !f.IsBool() {
+			err = errors.New("not cannot apply to non bool type")
+		}
+		return
 	case AndOpType, OrOpType:
 		if f.IsBool() && another.IsBool() {
 			return nil
 		}
 		return errors.New(fmt.Sprintf("%s cannot apply to non bool type", opType))
-	case EqualOpType, NotEqualOpType, IsOpType:
+	case LikeOpType:
+		if f.IsString() && another.IsString() {
+			return nil
+		}
+		return errors.New(fmt.Sprintf("%s cannot apply to non string type", opType))
+	case EqualOpType, NotEqualOpType, IsOpType, IsNotOpType, InOpType:
+		// Null can be compared with any type.
+		if f.IsNull() || another.IsNull() {
+			return nil
+		}
 		if f.IsNumerical() && another.IsNumerical() {
 			return nil
 		}
@@ -603,7 +907,10 @@ func (f Field) CanOp(another Field, opType OpType) (err error) {
 			return nil
 		}
 		return errors.New(fmt.Sprintf("type doesn't match on %s", opType))
-	case LessOpType, LessEqualOpType, GreatEqualOpType, GreatOpType:
+	case LessOpType, LessEqualOpType, GreatEqualOpType, GreatOpType, BetweenOpType:
+		if f.IsNull() || another.IsNull() {
+			return nil
+		}
 		if f.IsNumerical() && another.IsNumerical() {
 			return nil
 		}
@@ -667,7 +974,7 @@ func (f Field) CanAssign(val []byte) (err error) {
 //}
 
 func (f Field) CanIgnoreInInsert() bool {
-	return f.Name == DefaultRowKeyName || f.AllowNull
+	return f.Name == DefaultRowKeyName || f.AllowNull || len(f.DefaultValue) > 0
 }
 
 func (f Field) ColumnName() (name string) {
@@ -716,6 +1023,11 @@ const (
 	NotEqualOpType
 	IsOpType
 	NegativeOpType
+	IsNotOpType
+	InOpType
+	BetweenOpType
+	LikeOpType
+	NotOpType
 )
 
 func (tp OpType) String() string {
@@ -750,6 +1062,16 @@ func (tp OpType) String() string {
 		return "Is"
 	case NegativeOpType:
 		return "-"
+	case IsNotOpType:
+		return "Is not"
+	case InOpType:
+		return "in"
+	case BetweenOpType:
+		return "between"
+	case LikeOpType:
+		return "like"
+	case NotOpType:
+		return "not"
 	default:
 		panic("unknown op")
 	}
@@ -757,11 +1079,12 @@ func (tp OpType) String() string {
 
 func (tp OpType) Comparator() bool {
 	return tp == IsOpType || tp == EqualOpType || tp == NotEqualOpType || tp == GreatOpType ||
-		tp == GreatEqualOpType || tp == LessOpType || tp == LessEqualOpType
+		tp == GreatEqualOpType || tp == LessOpType || tp == LessEqualOpType || tp == IsNotOpType ||
+		tp == InOpType || tp == BetweenOpType || tp == LikeOpType
 }
 
 func (tp OpType) Logic() bool {
-	return tp == AndOpType || tp == OrOpType
+	return tp == AndOpType || tp == OrOpType || tp == NotOpType
 }
 
 var typeOpMap = map[string]FieldTPName{
@@ -814,6 +1137,9 @@ func (f Field) InferenceType(another Field, op OpType) FieldTP {
 }
 
 func InferenceType(data []byte) FieldTP {
+	if len(data) == 0 {
+		return DefaultFieldTpMap[Null]
+	}
 	if strings.ToUpper(string(data)) == "TRUE" || strings.ToUpper(string(data)) == "FALSE" {
 		return FieldTP{Name: Bool}
 	}
@@ -1078,6 +1404,7 @@ func (column *ColumnVector) Sort(others []*ColumnVector, asc []bool) *ColumnVect
 }
 
 // column must be a bool column
+// Bool returns false for null.
 func (column *ColumnVector) Bool(row int) bool {
 	return DecodeBool(column.Values[row])
 }
@@ -1110,10 +1437,15 @@ func (column *ColumnVector) ToString(row int) string {
 		return NULL
 	}
 	switch column.Field.TP.Name {
+	case Null:
+		return NULL
 	case Text, Char, VarChar, MediumText, Blob, MediumBlob, DateTime, Date, Time:
 		// we can compare them by bytes.
 		return string(column.Values[row])
 	case Bool:
+		if len(column.Values[row]) == 0 {
+			return NULL
+		}
 		if column.Bool(row) {
 			return "1"
 		}
@@ -1159,6 +1491,8 @@ const (
 	Text       FieldTPName = "text"
 	MediumText FieldTPName = "mediumText"
 	Multiple   FieldTPName = "*"
+	// Null is the type of the null literal.
+	Null FieldTPName = "null"
 )
 
 // Several no range fieldTP map.
@@ -1172,6 +1506,7 @@ var DefaultFieldTpMap = map[FieldTPName]FieldTP{
 	Text:       {Name: Text},
 	MediumText: {Name: MediumText},
 	Int:        {Name: Int},
+	Null:       {Name: Null},
 	Float:      {Name: Float, Range: [2]int{64, 64}},
 	Char:       {Name: Float, Range: [2]int{1 << 8}},
 	VarChar:    {Name: Float, Range: [2]int{1 << 16}},
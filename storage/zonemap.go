@@ -0,0 +1,188 @@
+package storage
+
+// RowGroupSize is the row count of a row group, only the last group of a table can be smaller.
+var RowGroupSize = 1024
+
+// A RowGroup is a range of table rows [Start, End) with its zone map, which is the min and max
+// value and the null count of every column in the range. Null values are ignored by min and max,
+// Min[i] is nil if all values of column i are null. The row index column is always empty.
+type RowGroup struct {
+	Start     int
+	End       int
+	Min       [][]byte
+	Max       [][]byte
+	NullCount []int
+	tps       []FieldTP
+}
+
+// RowGroups returns the row groups of table, the stale zone maps are rebuilt first.
+func (table *TableInfo) RowGroups() []*RowGroup {
+	if table.rowGroupSize != RowGroupSize {
+		table.rowGroups, table.rowGroupSize = nil, RowGroupSize
+	}
+	// Drop the last group if it doesn't cover all rows, so all groups but the last are full.
+	if n := len(table.rowGroups); n > 0 && table.rowGroups[n-1].End != table.RowCount() {
+		table.rowGroups = table.rowGroups[:n-1]
+	}
+	for start := len(table.rowGroups) * RowGroupSize; start < table.RowCount(); start += RowGroupSize {
+		group := table.newRowGroup(start)
+		for row := start; row < start+RowGroupSize && row < table.RowCount(); row++ {
+			table.addToRowGroup(group, row)
+		}
+		table.rowGroups = append(table.rowGroups, group)
+	}
+	return table.rowGroups
+}
+
+func (table *TableInfo) newRowGroup(start int) *RowGroup {
+	group := &RowGroup{
+		Start:     start,
+		End:       start,
+		Min:       make([][]byte, len(table.Datas)),
+		Max:       make([][]byte, len(table.Datas)),
+		NullCount: make([]int, len(table.Datas)),
+		tps:       make([]FieldTP, len(table.Datas)),
+	}
+	for i, col := range table.Datas {
+		group.tps[i] = col.Field.TP
+	}
+	return group
+}
+
+func (table *TableInfo) addToRowGroup(group *RowGroup, row int) {
+	// Skip the row index column.
+	for i := 1; i < len(table.Datas); i++ {
+		var value []byte
+		if row < table.Datas[i].Size() {
+			value = table.Datas[i].Values[row]
+		}
+		group.addValue(i, value)
+	}
+	group.End = row + 1
+}
+
+func (group *RowGroup) addValue(col int, value []byte) {
+	if len(value) == 0 {
+		group.NullCount[col]++
+		return
+	}
+	tp := group.tps[col]
+	if group.Min[col] == nil || compare(value, tp, group.Min[col], tp) < 0 {
+		group.Min[col] = value
+	}
+	if group.Max[col] == nil || compare(value, tp, group.Max[col], tp) > 0 {
+		group.Max[col] = value
+	}
+}
+
+// invalidateRowGroups drops the zone maps of rows starting at row, they are rebuilt when needed.
+func (table *TableInfo) invalidateRowGroups(row int) {
+	if group := row / RowGroupSize; group < len(table.rowGroups) {
+		table.rowGroups = table.rowGroups[:group]
+	}
+}
+
+// appendToRowGroups adds the last row of table to the zone maps if they cover all rows before it.
+func (table *TableInfo) appendToRowGroups() {
+	row, n := table.RowCount()-1, len(table.rowGroups)
+	if row == 0 {
+		table.rowGroups, table.rowGroupSize, n = nil, RowGroupSize, 0
+	}
+	if table.rowGroupSize != RowGroupSize || (n == 0 && row != 0) || (n > 0 && table.rowGroups[n-1].End != row) {
+		return
+	}
+	if n == 0 || row-table.rowGroups[n-1].Start >= RowGroupSize {
+		table.rowGroups = append(table.rowGroups, table.newRowGroup(row))
+	}
+	table.addToRowGroup(table.rowGroups[len(table.rowGroups)-1], row)
+}
+
+// updateRowGroup keeps the zone map of row valid after column col is updated from oldValue
+// to newValue. The min and max value are only widened, so they might be looser than the data.
+func (table *TableInfo) updateRowGroup(col, row int, oldValue, newValue []byte) {
+	group := row / RowGroupSize
+	if table.rowGroupSize != RowGroupSize || group >= len(table.rowGroups) || row >= table.rowGroups[group].End {
+		return
+	}
+	if len(oldValue) == 0 {
+		table.rowGroups[group].NullCount[col]--
+	}
+	table.rowGroups[group].addValue(col, newValue)
+}
+
+// MayMatch returns false if no row of the group can satisfy `column op value`, where column is
+// the index of the column in table. Only comparison ops can prune a group.
+func (group *RowGroup) MayMatch(column int, op OpType, value []byte, tp FieldTP) bool {
+	colTP := group.tps[column]
+	if isNumerical(colTP) != isNumerical(tp) {
+		return true
+	}
+	if group.NullCount[column] > 0 {
+		// Null values are compared as empty bytes for string columns.
+		if isNumerical(colTP) || satisfyCompare(op, compare(nil, colTP, value, tp)) {
+			return true
+		}
+	}
+	min, max := group.Min[column], group.Max[column]
+	if min == nil {
+		return false
+	}
+	switch op {
+	case EqualOpType, IsOpType:
+		return compare(min, colTP, value, tp) <= 0 && compare(max, colTP, value, tp) >= 0
+	case NotEqualOpType:
+		return compare(min, colTP, value, tp) != 0 || compare(max, colTP, value, tp) != 0
+	case LessOpType:
+		return compare(min, colTP, value, tp) < 0
+	case LessEqualOpType:
+		return compare(min, colTP, value, tp) <= 0
+	case GreatOpType:
+		return compare(max, colTP, value, tp) > 0
+	case GreatEqualOpType:
+		return compare(max, colTP, value, tp) >= 0
+	default:
+		return true
+	}
+}
+
+func satisfyCompare(op OpType, result int) bool {
+	switch op {
+	case EqualOpType, IsOpType:
+		return result == 0
+	case NotEqualOpType:
+		return result != 0
+	case LessOpType:
+		return result < 0
+	case LessEqualOpType:
+		return result <= 0
+	case GreatOpType:
+		return result > 0
+	case GreatEqualOpType:
+		return result >= 0
+	default:
+		return true
+	}
+}
+
+// FetchDataSkipping is like FetchData, but skips the row groups which skip returns true for.
+// It returns the data and the row index to fetch next, the data is nil if no rows left. The data
+// is empty if all the rows left are skipped, so skipping doesn't change results, like the one
+// row of select count(id) on no rows.
+func (table *TableInfo) FetchDataSkipping(rowIndex, batchSize int, skip func(group *RowGroup) bool) (*RecordBatch, int) {
+	groups := table.RowGroups()
+	if rowIndex >= table.RowCount() {
+		return nil, rowIndex
+	}
+	ret := createRecordBatchFromColumns(table.TableSchema.Columns)
+	for rowIndex < table.RowCount() && ret.RowCount() < batchSize {
+		group := groups[rowIndex/RowGroupSize]
+		if skip(group) {
+			rowIndex = group.End
+			continue
+		}
+		for ; rowIndex < group.End && ret.RowCount() < batchSize; rowIndex++ {
+			table.FillRowInfo(ret, rowIndex)
+		}
+	}
+	return ret, rowIndex
+}
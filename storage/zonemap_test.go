@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTableInfo_RowGroups(t *testing.T) {
+	idField := Field{Name: "id", TP: DefaultFieldTpMap[Int]}
+	nameField := Field{Name: "name", TP: DefaultFieldTpMap[Text]}
+	table := &TableInfo{
+		TableSchema: &TableSchema{Columns: []Field{RowIndexField("db", "t"), idField, nameField}},
+		Datas:       []*ColumnVector{{Field: RowIndexField("db", "t")}, {Field: idField}, {Field: nameField}},
+	}
+	RowGroupSize = 4
+	defer func() { RowGroupSize = 1024 }()
+	// id: 0, 1, ..., 9, name: every third name is null.
+	for i := 0; i < 10; i++ {
+		name := []byte("a")
+		if i%3 == 0 {
+			name = nil
+		}
+		table.InsertData([]string{"id", "name"}, [][]byte{EncodeInt(int64(i)), name})
+		// Zone maps are maintained when appending.
+		assert.Equal(t, i+1, table.rowGroups[len(table.rowGroups)-1].End)
+	}
+	groups := table.RowGroups()
+	assert.Equal(t, 3, len(groups))
+	assert.Equal(t, 8, groups[2].Start)
+	assert.Equal(t, 10, groups[2].End)
+	assert.Equal(t, int64(4), DecodeInt(groups[1].Min[1]))
+	assert.Equal(t, int64(7), DecodeInt(groups[1].Max[1]))
+	assert.Equal(t, 2, groups[0].NullCount[2])
+
+	intTP := DefaultFieldTpMap[Int]
+	assert.True(t, groups[1].MayMatch(1, EqualOpType, EncodeInt(5), intTP))
+	assert.False(t, groups[1].MayMatch(1, EqualOpType, EncodeInt(8), intTP))
+	assert.False(t, groups[1].MayMatch(1, GreatOpType, EncodeInt(7), intTP))
+	assert.True(t, groups[1].MayMatch(1, GreatEqualOpType, EncodeInt(7), intTP))
+	assert.False(t, groups[1].MayMatch(1, LessOpType, EncodeInt(4), intTP))
+	assert.True(t, groups[1].MayMatch(1, NotEqualOpType, EncodeInt(4), intTP))
+	// Cannot compare, so never skipped.
+	assert.True(t, groups[1].MayMatch(1, EqualOpType, []byte("a"), DefaultFieldTpMap[Text]))
+	// Null names are compared as empty strings.
+	assert.False(t, groups[1].MayMatch(2, GreatOpType, []byte("a"), DefaultFieldTpMap[Text]))
+	assert.True(t, groups[1].MayMatch(2, LessOpType, []byte("a"), DefaultFieldTpMap[Text]))
+
+	ret, next := table.FetchDataSkipping(0, 3, func(group *RowGroup) bool {
+		return !group.MayMatch(1, GreatOpType, EncodeInt(5), intTP)
+	})
+	assert.Equal(t, 3, ret.RowCount())
+	assert.Equal(t, int64(4), DecodeInt(ret.Records[1].Values[0]))
+	assert.Equal(t, 7, next)
+	// The data is empty rather than nil if the rows left are all skipped.
+	ret, next = table.FetchDataSkipping(next, 3, func(group *RowGroup) bool { return true })
+	assert.Equal(t, 0, ret.RowCount())
+	assert.Equal(t, 10, next)
+	ret, _ = table.FetchDataSkipping(next, 3, func(group *RowGroup) bool { return true })
+	assert.Nil(t, ret)
+
+	// Updates widen the zone map.
+	assert.Nil(t, table.UpdateData("id", 1, EncodeInt(100)))
+	assert.Equal(t, int64(100), DecodeInt(table.RowGroups()[0].Max[1]))
+	assert.Nil(t, table.UpdateData("name", 0, []byte("b")))
+	assert.Equal(t, 1, table.RowGroups()[0].NullCount[2])
+	// Deletes rebuild the zone maps of the following rows.
+	table.DeleteRow(4)
+	groups = table.RowGroups()
+	assert.Equal(t, 3, len(groups))
+	assert.Equal(t, int64(5), DecodeInt(groups[1].Min[1]))
+	assert.Equal(t, 9, groups[2].End)
+	table.Truncate()
+	assert.Equal(t, 0, len(table.RowGroups()))
+}